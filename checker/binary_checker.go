@@ -0,0 +1,290 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"preflight/pkg/command"
+
+	"github.com/pkg/errors"
+)
+
+// BinaryVersionTimeout limits the version command of each binary.
+var BinaryVersionTimeout = 10 * time.Second
+
+// BinarySearchPath are searched after PATH, since the sbin directories are often missing from PATH of non-login shells.
+var BinarySearchPath = []string{"/usr/local/sbin", "/usr/local/bin", "/usr/sbin", "/usr/bin", "/sbin", "/bin"}
+
+// DefaultBinaryVersionArgs and DefaultBinaryVersionRegexp are used if the binary does not declare its version command.
+var (
+	DefaultBinaryVersionArgs   = []string{"--version"}
+	DefaultBinaryVersionRegexp = `(\d+(?:\.\d+)+(?:-[0-9A-Za-z.]+)?)`
+)
+
+// knownBinaryVersions are the version commands of binaries which do not follow the defaults.
+var knownBinaryVersions = map[string]struct {
+	args   []string
+	regexp string
+}{
+	// socat version 1.7.3.2 on Apr  4 2018 08:46:01
+	"socat": {args: []string{"-V"}, regexp: `socat version (\d+(?:\.\d+)+)`},
+}
+
+var binarySpecRegexp = regexp.MustCompile(`^([^<>=!\s]+)\s*(.*)$`)
+
+// BinaryCheck checks the required binaries are installed with the required versions.
+type BinaryCheck struct {
+	Binaries []Binary `json:"binaries" yaml:"binaries"`
+}
+
+// Binary is a required command.
+type Binary struct {
+	// Name is the command looked up on PATH, like socat, or the absolute path like /usr/local/bin/helm.
+	Name string `json:"name" yaml:"name"`
+	// Version is the constraint like ">=1.8.4 <2", the version is not checked if it is empty.
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	// VersionArgs are the arguments to print the version, --version by default.
+	VersionArgs []string `json:"versionArgs,omitempty" yaml:"versionArgs,omitempty"`
+	// VersionRegexp extracts the version from the output by the first submatch, or the whole match if there is no submatch.
+	VersionRegexp string `json:"versionRegexp,omitempty" yaml:"versionRegexp,omitempty"`
+}
+
+// newBinaryCheck build BinaryCheck from the binaries and their optional version constraints separated by ";",
+// like "socat;conntrack;iptables>=1.8.4 <2;/usr/local/bin/helm>=3".
+func newBinaryCheck(arg string) (Interface, error) {
+	var b BinaryCheck
+	for _, r := range strings.Split(arg, ";") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		match := binarySpecRegexp.FindStringSubmatch(r)
+		if match == nil {
+			return nil, argError(b.Type(), arg, "invalid binary %q, expected like iptables>=1.8.4", r)
+		}
+		b.Binaries = append(b.Binaries, Binary{Name: match[1], Version: strings.TrimSpace(match[2])})
+	}
+	if err := b.ValidateArgs(); err != nil {
+		return nil, argError(b.Type(), arg, "%v", err)
+	}
+	return b, nil
+}
+
+func (b BinaryCheck) Type() string {
+	return strings.ToLower("Binary")
+}
+
+func (b BinaryCheck) PrettyName() string {
+	return fmt.Sprintf("%s:%s", b.Type(), strings.Join(b.requirements(), ";"))
+}
+
+func (BinaryCheck) Metadata() Metadata {
+	return Metadata{
+		Description: "Check the required binaries are installed with the required versions",
+		Level:       FatalLevel,
+		Explain:     "kubeadm and kube-proxy require socat, conntrack, ipset, ebtables and a recent iptables, the installation fails if they are missing or too old.",
+		Suggestion:  "Install or upgrade the packages of the binaries",
+	}
+}
+
+func (BinaryCheck) Schema() Schema {
+	return Schema{
+		Arg: &Parameter{
+			Name:        "binaries",
+			Type:        "string",
+			Example:     "socat;conntrack;ipset;ebtables;tar;iptables>=1.8.4 <2",
+			Description: "the binaries and their optional version constraints separated by ;",
+		},
+		Parameters: []Parameter{
+			{Name: "binaries", Type: "[]object", Example: "[{name: iptables, version: '>=1.8.4 <2'}, {name: /usr/local/bin/helm, version: '>=3', versionArgs: [version, --short], versionRegexp: 'v(\\d+\\.\\d+\\.\\d+)'}]",
+				Description: "the binaries by name or absolute path, the version is printed by versionArgs and extracted by versionRegexp"},
+		},
+	}
+}
+
+func (b BinaryCheck) ValidateArgs() error {
+	if len(b.Binaries) == 0 {
+		return errors.New("at least one binary is required")
+	}
+	for _, bin := range b.Binaries {
+		if bin.Name == "" {
+			return errors.New("empty binary name")
+		}
+		if strings.Contains(bin.Name, "/") && !path.IsAbs(bin.Name) {
+			return errors.Errorf("invalid binary %q, expected a name or absolute path", bin.Name)
+		}
+		if bin.Version != "" {
+			if _, err := parseVersionConstraint(bin.Version); err != nil {
+				return errors.Wrapf(err, "invalid version of %s", bin.Name)
+			}
+		}
+		if bin.VersionRegexp != "" {
+			if _, err := regexp.Compile(bin.VersionRegexp); err != nil {
+				return errors.Wrapf(err, "invalid version regexp of %s", bin.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// requirements return the binaries in the format of spec.
+func (b BinaryCheck) requirements() []string {
+	rs := make([]string, 0, len(b.Binaries))
+	for _, bin := range b.Binaries {
+		rs = append(rs, bin.Name+bin.Version)
+	}
+	return rs
+}
+
+func (b BinaryCheck) Validate() (bool, error) {
+	return ValidateReport(b.Evaluate(context.Background()))
+}
+
+func (b BinaryCheck) Evaluate(ctx context.Context) (Report, error) {
+	if err := b.ValidateArgs(); err != nil {
+		return Report{}, err
+	}
+
+	var details []Detail
+	var missing, mismatched []string
+	for _, bin := range b.Binaries {
+		d := Detail{Name: path.Base(bin.Name), Status: StatusPass, Expected: "installed"}
+		if bin.Version != "" {
+			d.Expected = bin.Version
+		}
+		found, ok := lookupBinary(bin.Name)
+		if !ok {
+			d.Status, d.Observed = StatusFail, "not found"
+			d.Message = fmt.Sprintf("%s is not found", bin.Name)
+			if !path.IsAbs(bin.Name) {
+				d.Message = fmt.Sprintf("%s is not found in %s", bin.Name, strings.Join(binarySearchPath(), ":"))
+			}
+			missing = append(missing, bin.Name)
+			details = append(details, d)
+			continue
+		}
+		d.Observed = found
+		if bin.Version == "" {
+			details = append(details, d)
+			continue
+		}
+
+		version, err := bin.version(ctx, found)
+		if err != nil {
+			if ctx.Err() != nil {
+				return Report{}, ctx.Err()
+			}
+			d.Status, d.Message = StatusError, err.Error()
+			details = append(details, d)
+			continue
+		}
+		d.Observed = fmt.Sprintf("%s %s", found, version)
+		constraint, _ := parseVersionConstraint(bin.Version)
+		if !constraint.match(version) {
+			d.Status = StatusFail
+			d.Message = fmt.Sprintf("version %s of %s does not match %s", version, found, bin.Version)
+			mismatched = append(mismatched, fmt.Sprintf("%s %s", d.Name, version))
+		}
+		details = append(details, d)
+	}
+
+	report := ReportDetails(details)
+	var observed []string
+	if len(missing) > 0 {
+		observed = append(observed, "missing "+strings.Join(missing, ","))
+	}
+	if len(mismatched) > 0 {
+		observed = append(observed, "version mismatched "+strings.Join(mismatched, ","))
+	}
+	if len(observed) == 0 {
+		observed = append(observed, "all installed")
+	}
+	report.Observed = strings.Join(observed, "; ")
+	report.Expected = strings.Join(b.requirements(), ";")
+	return report, nil
+}
+
+// version run the version command of binary and extract the version from its output.
+func (bin Binary) version(ctx context.Context, found string) (semVersion, error) {
+	args, pattern := DefaultBinaryVersionArgs, DefaultBinaryVersionRegexp
+	if known, ok := knownBinaryVersions[path.Base(bin.Name)]; ok {
+		args, pattern = known.args, known.regexp
+	}
+	if len(bin.VersionArgs) > 0 {
+		args = bin.VersionArgs
+	}
+	if bin.VersionRegexp != "" {
+		pattern = bin.VersionRegexp
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, BinaryVersionTimeout)
+	defer cancel()
+	lines, runErr := command.NewHostCmd(hostPath(found), args...).Silent().Context(ctx).RunAndCapture()
+	output := strings.Join(lines, "\n")
+	match := regexp.MustCompile(pattern).FindStringSubmatch(output)
+	if match == nil {
+		// some binaries print the version with non-zero exit code, so the error matters only if no version is found.
+		if runErr != nil {
+			return semVersion{}, errors.Wrapf(runErr, "failed to run %s %s: %s", found, strings.Join(args, " "), strings.TrimSpace(output))
+		}
+		return semVersion{}, errors.Errorf("version of %s is not found in the output of %s: %s", found, strings.Join(args, " "), strings.TrimSpace(output))
+	}
+	raw := match[0]
+	if len(match) > 1 {
+		raw = match[1]
+	}
+	v, err := parseVersion(raw)
+	if err != nil {
+		return semVersion{}, errors.Wrapf(err, "failed to parse version of %s", found)
+	}
+	return v, nil
+}
+
+// binarySearchPath return the directories of PATH followed by BinarySearchPath.
+func binarySearchPath() []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, dir := range append(strings.Split(os.Getenv("PATH"), ":"), BinarySearchPath...) {
+		if dir == "" || !path.IsAbs(dir) || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// lookupBinary return the path of executable file under HostRoot by the absolute path or the name in search path.
+func lookupBinary(name string) (string, bool) {
+	candidates := []string{name}
+	if !path.IsAbs(name) {
+		candidates = nil
+		for _, dir := range binarySearchPath() {
+			candidates = append(candidates, path.Join(dir, name))
+		}
+	}
+	for _, c := range candidates {
+		if info, err := os.Stat(hostPath(c)); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+			return c, true
+		}
+	}
+	return "", false
+}
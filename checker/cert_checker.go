@@ -0,0 +1,402 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultCertMinValidity is the minimum remaining validity of certificates, kubeadm renews the certificates
+// by upgrade, and warns the ones expiring in 30 days.
+const DefaultCertMinValidity = 30 * 24 * time.Hour
+
+// CertCheck checks the PEM certificates are valid for the required duration, match their keys, contain the
+// required SANs and are signed by the CA.
+type CertCheck struct {
+	// Paths are the certificate files or globs like /etc/kubernetes/pki/*.crt, the files without certificate
+	// matched by globs are skipped, like the keys.
+	Paths []string `json:"paths" yaml:"paths"`
+	// MinValidity is the minimum remaining validity like 30d or 720h, 30d by default.
+	MinValidity string `json:"minValidity,omitempty" yaml:"minValidity,omitempty"`
+	// SANs are the hostnames and IPs required in the certificates other than CAs.
+	SANs []string `json:"sans,omitempty" yaml:"sans,omitempty"`
+	// CA is the PEM file of CAs which sign the certificates, the chain is not verified if it is empty.
+	CA string `json:"ca,omitempty" yaml:"ca,omitempty"`
+}
+
+// newCertCheck build CertCheck from the paths separated by "|" and the options separated by ";",
+// like "/etc/kubernetes/pki/*.crt;valid>=30d;ca=/etc/kubernetes/pki/ca.crt;san=kubernetes.default|10.96.0.1".
+func newCertCheck(arg string) (Interface, error) {
+	var c CertCheck
+	for i, r := range strings.Split(arg, ";") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(r, "valid>="):
+			c.MinValidity = strings.TrimSpace(strings.TrimPrefix(r, "valid>="))
+		case strings.HasPrefix(r, "ca="):
+			c.CA = strings.TrimSpace(strings.TrimPrefix(r, "ca="))
+		case strings.HasPrefix(r, "san="):
+			for _, s := range strings.Split(strings.TrimPrefix(r, "san="), "|") {
+				c.SANs = append(c.SANs, strings.TrimSpace(s))
+			}
+		case i == 0:
+			for _, p := range strings.Split(r, "|") {
+				c.Paths = append(c.Paths, strings.TrimSpace(p))
+			}
+		default:
+			return nil, argError(c.Type(), arg, "unknown option %q, expected valid>=, ca= or san=", r)
+		}
+	}
+	if err := c.ValidateArgs(); err != nil {
+		return nil, argError(c.Type(), arg, "%v", err)
+	}
+	return c, nil
+}
+
+func (c CertCheck) Type() string {
+	return strings.ToLower("Cert")
+}
+
+func (c CertCheck) PrettyName() string {
+	return fmt.Sprintf("%s:%s", c.Type(), strings.Join(c.requirements(), ";"))
+}
+
+func (CertCheck) Metadata() Metadata {
+	return Metadata{
+		Description: "Check the certificates are valid, match their keys, contain the SANs and are signed by the CA",
+		Level:       FatalLevel,
+		Explain:     "the components fail to serve or authenticate with expired certificates, and the clients reject the servers whose certificates miss their addresses.",
+		Suggestion:  "Renew the certificates by kubeadm certs renew or the CA of application, with the required SANs",
+	}
+}
+
+func (CertCheck) Schema() Schema {
+	return Schema{
+		Arg: &Parameter{
+			Name:        "paths",
+			Type:        "string",
+			Example:     "/etc/kubernetes/pki/*.crt;valid>=30d;ca=/etc/kubernetes/pki/ca.crt",
+			Description: "the certificate files or globs separated by |, and the options valid>=, ca= and san= separated by ;",
+		},
+		Parameters: []Parameter{
+			{Name: "paths", Type: "[]string", Example: "[/etc/kubernetes/pki/apiserver.crt, /etc/kubernetes/pki/front-proxy-*.crt]", Description: "the certificate files or globs"},
+			{Name: "minValidity", Type: "string", Default: "30d", Example: "90d", Description: "the minimum remaining validity in days like 30d or duration like 720h"},
+			{Name: "sans", Type: "[]string", Example: "[kubernetes.default.svc, 10.96.0.1]", Description: "the hostnames and IPs required in the certificates other than CAs"},
+			{Name: "ca", Type: "string", Example: "/etc/kubernetes/pki/ca.crt", Description: "the PEM file of CAs which sign the certificates"},
+		},
+	}
+}
+
+func (c CertCheck) ValidateArgs() error {
+	if len(c.Paths) == 0 {
+		return errors.New("at least one path is required")
+	}
+	for _, p := range c.Paths {
+		if !path.IsAbs(p) {
+			return errors.Errorf("invalid path %q, expected absolute path", p)
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return errors.Errorf("invalid glob %q", p)
+		}
+	}
+	if _, err := c.minValidity(); err != nil {
+		return err
+	}
+	for _, s := range c.SANs {
+		if s == "" {
+			return errors.New("empty SAN")
+		}
+	}
+	if c.CA != "" && !path.IsAbs(c.CA) {
+		return errors.Errorf("invalid CA %q, expected absolute path", c.CA)
+	}
+	return nil
+}
+
+// minValidity parse the validity in days like 30d, or the duration like 720h.
+func (c CertCheck) minValidity() (time.Duration, error) {
+	if c.MinValidity == "" {
+		return DefaultCertMinValidity, nil
+	}
+	if days := strings.TrimSuffix(c.MinValidity, "d"); days != c.MinValidity {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	} else if d, err := time.ParseDuration(c.MinValidity); err == nil && d >= 0 {
+		return d, nil
+	}
+	return 0, errors.Errorf("invalid minimum validity %q, expected like 30d or 720h", c.MinValidity)
+}
+
+// requirements return the paths and options in the format of spec.
+func (c CertCheck) requirements() []string {
+	rs := []string{strings.Join(c.Paths, "|")}
+	if c.MinValidity != "" {
+		rs = append(rs, "valid>="+c.MinValidity)
+	}
+	if c.CA != "" {
+		rs = append(rs, "ca="+c.CA)
+	}
+	if len(c.SANs) > 0 {
+		rs = append(rs, "san="+strings.Join(c.SANs, "|"))
+	}
+	return rs
+}
+
+func (c CertCheck) Validate() (bool, error) {
+	return ValidateReport(c.Evaluate(context.Background()))
+}
+
+func (c CertCheck) Evaluate(ctx context.Context) (Report, error) {
+	if err := c.ValidateArgs(); err != nil {
+		return Report{}, err
+	}
+	minValidity, _ := c.minValidity()
+	var roots *x509.CertPool
+	if c.CA != "" {
+		cas, _, err := readCertFile(c.CA)
+		if err != nil {
+			return Report{}, errors.Wrap(err, "failed to read CA")
+		}
+		if len(cas) == 0 {
+			return Report{}, errors.Errorf("no certificate found in CA %s", c.CA)
+		}
+		roots = x509.NewCertPool()
+		for _, ca := range cas {
+			roots.AddCert(ca)
+		}
+	}
+
+	now := time.Now()
+	var details []Detail
+	var observed []string
+	seen := make(map[string]bool)
+	for _, p := range c.Paths {
+		files, err := globHostFiles(p)
+		if err != nil {
+			return Report{}, err
+		}
+		glob := strings.ContainsAny(p, "*?[")
+		if len(files) == 0 {
+			details = append(details, Detail{Name: p, Status: StatusFail, Observed: "not found", Expected: "certificate",
+				Message: fmt.Sprintf("no certificate file matches %s", p)})
+			continue
+		}
+		for _, file := range files {
+			if err := ctx.Err(); err != nil {
+				return Report{}, err
+			}
+			// the glob like /etc/kubernetes/pki/* matches the sub directories like etcd too.
+			if seen[file] || glob && isHostDir(file) {
+				continue
+			}
+			seen[file] = true
+			certs, rest, err := readCertFile(file)
+			if err != nil {
+				details = append(details, Detail{Name: file, Status: StatusFail, Observed: "unreadable", Expected: "certificate",
+					Message: fmt.Sprintf("failed to read %s: %v", file, err)})
+				continue
+			}
+			if len(certs) == 0 {
+				if !glob {
+					details = append(details, Detail{Name: file, Status: StatusFail, Observed: "no certificate", Expected: "certificate",
+						Message: fmt.Sprintf("no PEM certificate found in %s", file)})
+				}
+				continue
+			}
+			keyFile, keyPEM, keyErr := findCertKey(file, rest)
+			for i, cert := range certs {
+				name := file
+				if len(certs) > 1 {
+					name = fmt.Sprintf("%s[%d]", file, i)
+				}
+				d := c.certDetail(name, cert, certs, now, minValidity, roots)
+				// the key pairs with the first certificate of file, the others are the chain.
+				if i == 0 && keyErr != nil {
+					d.Status = StatusFail
+					d.Message = joinMessages(d.Message, keyErr.Error())
+				} else if i == 0 && keyFile != "" {
+					if _, err := tls.X509KeyPair(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), keyPEM); err != nil {
+						d.Status = StatusFail
+						d.Message = joinMessages(d.Message, fmt.Sprintf("key %s of %s: %v", keyFile, name, err))
+					} else {
+						d.Observed += ", key " + keyFile
+					}
+				}
+				details = append(details, d)
+				observed = append(observed, fmt.Sprintf("%s %s", path.Base(name), cert.NotAfter.UTC().Format("2006-01-02")))
+			}
+		}
+	}
+
+	report := ReportDetails(details)
+	report.Observed = strings.Join(observed, ", ")
+	report.Expected = strings.Join(c.requirements(), ";")
+	return report, nil
+}
+
+// certDetail check the validity, SANs and chain of the certificate, the certificates in the same file are
+// the intermediates.
+func (c CertCheck) certDetail(name string, cert *x509.Certificate, bundle []*x509.Certificate, now time.Time, minValidity time.Duration, roots *x509.CertPool) Detail {
+	left := cert.NotAfter.Sub(now)
+	d := Detail{
+		Name:     name,
+		Status:   StatusPass,
+		Observed: fmt.Sprintf("expires %s (%s left)", cert.NotAfter.UTC().Format(time.RFC3339), formatDays(left)),
+		Expected: fmt.Sprintf("valid>=%s", formatDays(minValidity)),
+	}
+	var messages []string
+	switch {
+	case now.Before(cert.NotBefore):
+		d.Observed = fmt.Sprintf("not valid before %s, expires %s", cert.NotBefore.UTC().Format(time.RFC3339), cert.NotAfter.UTC().Format(time.RFC3339))
+		messages = append(messages, fmt.Sprintf("%s is not valid until %s", name, cert.NotBefore.UTC().Format(time.RFC3339)))
+	case left <= 0:
+		d.Observed = fmt.Sprintf("expired at %s", cert.NotAfter.UTC().Format(time.RFC3339))
+		messages = append(messages, fmt.Sprintf("%s expired at %s", name, cert.NotAfter.UTC().Format(time.RFC3339)))
+	case left < minValidity:
+		messages = append(messages, fmt.Sprintf("%s expires in %s at %s, less than %s", name, formatDays(left), cert.NotAfter.UTC().Format(time.RFC3339), formatDays(minValidity)))
+	}
+
+	if len(c.SANs) > 0 && !cert.IsCA {
+		d.Expected += ",san=" + strings.Join(c.SANs, "|")
+		var missing []string
+		for _, san := range c.SANs {
+			if !certHasSAN(cert, san) {
+				missing = append(missing, san)
+			}
+		}
+		if len(missing) > 0 {
+			messages = append(messages, fmt.Sprintf("%s misses SANs %s", name, strings.Join(missing, ",")))
+		}
+	}
+
+	if roots != nil {
+		d.Expected += ",ca=" + c.CA
+		intermediates := x509.NewCertPool()
+		for _, b := range bundle {
+			if b != cert {
+				intermediates.AddCert(b)
+			}
+		}
+		opts := x509.VerifyOptions{Roots: roots, Intermediates: intermediates, CurrentTime: now, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+		// the chain is verified at the time the certificate is valid, since the validity is reported separately.
+		if now.Before(cert.NotBefore) {
+			opts.CurrentTime = cert.NotBefore
+		} else if now.After(cert.NotAfter) {
+			opts.CurrentTime = cert.NotAfter
+		}
+		if _, err := cert.Verify(opts); err != nil {
+			messages = append(messages, fmt.Sprintf("%s is not signed by %s: %v", name, c.CA, err))
+		}
+	}
+
+	if len(messages) > 0 {
+		d.Status = StatusFail
+		d.Message = strings.Join(messages, ", ")
+	}
+	return d
+}
+
+func joinMessages(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + ", " + b
+}
+
+// formatDays format the duration in days like 364d, or hours if it is less than a day.
+func formatDays(d time.Duration) string {
+	if d >= 24*time.Hour || d <= -24*time.Hour {
+		return fmt.Sprintf("%dd", int64(d/(24*time.Hour)))
+	}
+	return d.Truncate(time.Hour).String()
+}
+
+// certHasSAN return true if the IP or DNS name is in SANs, the wildcard name like *.example.com matches
+// a single label.
+func certHasSAN(cert *x509.Certificate, san string) bool {
+	if ip := net.ParseIP(san); ip != nil {
+		for _, i := range cert.IPAddresses {
+			if i.Equal(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return cert.VerifyHostname(san) == nil
+}
+
+// readCertFile return the certificates of PEM file under HostRoot and the PEM blocks of private key.
+func readCertFile(file string) ([]*x509.Certificate, []byte, error) {
+	data, err := os.ReadFile(hostPath(file))
+	if err != nil {
+		return nil, nil, err
+	}
+	var certs []*x509.Certificate
+	var keys []byte
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch {
+		case block.Type == "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "invalid certificate in %s", file)
+			}
+			certs = append(certs, cert)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			keys = append(keys, pem.EncodeToMemory(block)...)
+		}
+	}
+	return certs, keys, nil
+}
+
+// findCertKey return the key of certificate file, it is in the same file, or the sibling file like apiserver.key
+// of apiserver.crt, or server-key.pem of server.pem. The file is empty if no key is found.
+func findCertKey(file string, keys []byte) (string, []byte, error) {
+	if len(keys) > 0 {
+		return file, keys, nil
+	}
+	ext := path.Ext(file)
+	base := strings.TrimSuffix(file, ext)
+	for _, candidate := range []string{base + ".key", base + "-key.pem"} {
+		data, err := os.ReadFile(hostPath(candidate))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "failed to read %s", candidate)
+		}
+		return candidate, data, nil
+	}
+	return "", nil, nil
+}
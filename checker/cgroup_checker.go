@@ -0,0 +1,573 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"preflight/pkg/container"
+	"preflight/pkg/system"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// cgroup modes.
+const (
+	CgroupV1     = "v1"
+	CgroupV2     = "v2"
+	CgroupHybrid = "hybrid"
+)
+
+// DefaultCgroupControllers the controllers required by kubelet and container runtimes.
+var DefaultCgroupControllers = []string{"cpu", "memory", "pids", "hugetlb", "io"}
+
+// CgroupSystemdVersionForV2 is the systemd version recommended for cgroup v2, the older ones
+// do not support delegation of cpuset controller.
+const CgroupSystemdVersionForV2 = 244
+
+// CgroupCheck checks the cgroup mode, controllers, systemd version and the cgroup driver of
+// kubelet, containerd and docker.
+type CgroupCheck struct {
+	// Mode the allowed cgroup modes separated by |, like v2 or v1|hybrid, empty means any mode.
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	// Controllers required to be available and delegated, DefaultCgroupControllers is used if empty.
+	Controllers []string `json:"controllers,omitempty" yaml:"controllers,omitempty"`
+	// Driver the required cgroup driver, systemd or cgroupfs. if empty, the driver is required
+	// to be the same as the init system, and to be consistent among the configured components.
+	Driver string `json:"driver,omitempty" yaml:"driver,omitempty"`
+	// MinSystemdVersion the minimum version of systemd, 0 means not checked.
+	MinSystemdVersion int `json:"minSystemdVersion,omitempty" yaml:"minSystemdVersion,omitempty"`
+}
+
+var (
+	cgroupModeRegexp       = regexp.MustCompile(`^(v1|v2|hybrid)(\|(v1|v2|hybrid))*$`)
+	cgroupControllerRegexp = regexp.MustCompile(`^[a-z_]+$`)
+	systemdVersionRegexp   = regexp.MustCompile(`(?:^systemd |libsystemd-shared-)(\d+)`)
+	systemdCgroupRegexp    = regexp.MustCompile(`^SystemdCgroup\s*=\s*(true|false)\b`)
+)
+
+// newCgroupCheck build CgroupCheck from the requirements separated by ";",
+// like "mode=v2;controllers=cpu|memory|pids;driver=systemd;systemd>=244", empty arg checks the default controllers and driver.
+func newCgroupCheck(arg string) (Interface, error) {
+	var c CgroupCheck
+	for _, r := range strings.Split(arg, ";") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		i := strings.IndexAny(r, ">=")
+		if i <= 0 {
+			return nil, argError(c.Type(), arg, "invalid requirement %q, expected like mode=v2", r)
+		}
+		key, rest := strings.TrimSpace(r[:i]), r[i:]
+		switch {
+		case key == "mode" && rest[0] == '=':
+			c.Mode = strings.TrimSpace(rest[1:])
+		case key == "controllers" && rest[0] == '=':
+			for _, controller := range strings.Split(rest[1:], "|") {
+				c.Controllers = append(c.Controllers, strings.TrimSpace(controller))
+			}
+		case key == "driver" && rest[0] == '=':
+			c.Driver = strings.TrimSpace(rest[1:])
+		case key == "systemd" && strings.HasPrefix(rest, ">="):
+			version, err := strconv.Atoi(strings.TrimSpace(rest[2:]))
+			if err != nil || version <= 0 {
+				return nil, argError(c.Type(), arg, "invalid systemd version %q, expected like systemd>=244", r)
+			}
+			c.MinSystemdVersion = version
+		default:
+			return nil, argError(c.Type(), arg, "unknown requirement %q, expected one of mode=,controllers=,driver=,systemd>=", r)
+		}
+	}
+	if err := c.ValidateArgs(); err != nil {
+		return nil, argError(c.Type(), arg, "%v", err)
+	}
+	return c, nil
+}
+
+func (c CgroupCheck) Type() string {
+	return strings.ToLower("Cgroup")
+}
+
+func (c CgroupCheck) PrettyName() string {
+	if rs := c.requirements(); len(rs) > 0 {
+		return fmt.Sprintf("%s:%s", c.Type(), strings.Join(rs, ";"))
+	}
+	return c.Type()
+}
+
+func (CgroupCheck) Metadata() Metadata {
+	return Metadata{
+		Description: "Check the cgroup mode, controllers, systemd version and cgroup driver of kubelet and container runtimes",
+		Level:       FatalLevel,
+		Explain:     "kubelet fails to start or pods are killed unexpectedly if the required controllers are missing, or kubelet and container runtime use different cgroup drivers.",
+		Suggestion:  "Enable the controllers by kernel cmdline, and set the same cgroup driver by SystemdCgroup of containerd, native.cgroupdriver of docker and cgroupDriver of kubelet",
+	}
+}
+
+func (CgroupCheck) Schema() Schema {
+	return Schema{
+		Arg: &Parameter{
+			Name:        "requirements",
+			Type:        "string",
+			Example:     "mode=v2;controllers=cpu|memory|pids;driver=systemd;systemd>=244",
+			Description: "the requirements separated by ;, keys are mode,controllers,driver and systemd, empty checks the default controllers and driver",
+		},
+		Parameters: []Parameter{
+			{Name: "mode", Type: "string", Example: "v2", Description: "the allowed cgroup modes separated by |, one of v1,v2 and hybrid"},
+			{Name: "controllers", Type: "[]string", Default: strings.Join(DefaultCgroupControllers, ","), Example: "[cpu, memory, pids]", Description: "the controllers required to be available and delegated"},
+			{Name: "driver", Type: "string", Default: "the init system", Example: container.CgroupDriverSystemd, Description: "the required cgroup driver of kubelet, containerd and docker, systemd or cgroupfs"},
+			{Name: "minSystemdVersion", Type: "int", Example: "244", Description: "the minimum version of systemd"},
+		},
+	}
+}
+
+func (c CgroupCheck) ValidateArgs() error {
+	if c.Mode != "" && !cgroupModeRegexp.MatchString(c.Mode) {
+		return errors.Errorf("invalid mode %q, expected v1,v2 or hybrid separated by |", c.Mode)
+	}
+	for _, controller := range c.Controllers {
+		if !cgroupControllerRegexp.MatchString(controller) {
+			return errors.Errorf("invalid controller %q", controller)
+		}
+	}
+	if c.Driver != "" && c.Driver != container.CgroupDriverSystemd && c.Driver != container.CgroupDriverCgroupfs {
+		return errors.Errorf("invalid driver %q, expected %s or %s", c.Driver, container.CgroupDriverSystemd, container.CgroupDriverCgroupfs)
+	}
+	if c.MinSystemdVersion < 0 {
+		return errors.Errorf("invalid systemd version %d", c.MinSystemdVersion)
+	}
+	return nil
+}
+
+// requirements return the requirements in the format of spec.
+func (c CgroupCheck) requirements() []string {
+	var rs []string
+	if c.Mode != "" {
+		rs = append(rs, "mode="+c.Mode)
+	}
+	if len(c.Controllers) > 0 {
+		rs = append(rs, "controllers="+strings.Join(c.Controllers, "|"))
+	}
+	if c.Driver != "" {
+		rs = append(rs, "driver="+c.Driver)
+	}
+	if c.MinSystemdVersion > 0 {
+		rs = append(rs, fmt.Sprintf("systemd>=%d", c.MinSystemdVersion))
+	}
+	return rs
+}
+
+func (c CgroupCheck) controllers() []string {
+	if len(c.Controllers) == 0 {
+		return DefaultCgroupControllers
+	}
+	return c.Controllers
+}
+
+func (c CgroupCheck) Validate() (bool, error) {
+	return ValidateReport(c.Evaluate(context.Background()))
+}
+
+func (c CgroupCheck) Evaluate(ctx context.Context) (Report, error) {
+	if err := c.ValidateArgs(); err != nil {
+		return Report{}, err
+	}
+
+	mounts, err := cgroupMounts()
+	if err != nil {
+		return Report{}, err
+	}
+	mode, err := cgroupMode(mounts)
+	if err != nil {
+		return Report{}, err
+	}
+	details := []Detail{{Name: "cgroup mode", Status: StatusPass, Observed: mode}}
+	if c.Mode != "" {
+		details[0] = oneOfDetail("cgroup mode", mode, c.Mode)
+	}
+
+	controllers, err := c.controllerDetails(mode, mounts)
+	if err != nil {
+		return Report{}, err
+	}
+	details = append(details, controllers...)
+
+	systemd := isSystemdBooted()
+	version := 0
+	if systemd {
+		if version, err = systemdVersion(ctx); err != nil {
+			return Report{}, err
+		}
+	}
+	details = append(details, c.systemdDetail(mode, systemd, version))
+
+	drivers, err := c.driverDetails(mode, systemd)
+	if err != nil {
+		return Report{}, err
+	}
+	details = append(details, drivers...)
+
+	report := ReportDetails(details)
+	report.Observed = mode
+	if systemd {
+		report.Observed += fmt.Sprintf(", systemd %d", version)
+	}
+	report.Expected = strings.Join(c.requirements(), ";")
+	return report, nil
+}
+
+// cgroupMounts return the cgroup mounts under /sys/fs/cgroup.
+func cgroupMounts() ([]system.Mount, error) {
+	f, err := os.Open(hostPath(system.MountInfoPath))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read mountinfo")
+	}
+	defer f.Close()
+	all, err := system.ParseMountInfo(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var mounts []system.Mount
+	for _, m := range all {
+		if (m.FsType == "cgroup" || m.FsType == "cgroup2") &&
+			(m.MountPoint == "/sys/fs/cgroup" || strings.HasPrefix(m.MountPoint, "/sys/fs/cgroup/")) {
+			mounts = append(mounts, m)
+		}
+	}
+	return mounts, nil
+}
+
+// cgroupMode detect the cgroup mode by the mounts, or the files of /sys/fs/cgroup if none is mounted, such as in a container.
+func cgroupMode(mounts []system.Mount) (string, error) {
+	var v1, unified bool
+	for _, m := range mounts {
+		switch {
+		case m.FsType == "cgroup2" && m.MountPoint == "/sys/fs/cgroup":
+			return CgroupV2, nil
+		case m.FsType == "cgroup2":
+			unified = true
+		default:
+			v1 = true
+		}
+	}
+	switch {
+	case v1 && unified:
+		return CgroupHybrid, nil
+	case v1:
+		return CgroupV1, nil
+	}
+
+	switch {
+	case fileExists("/sys/fs/cgroup/cgroup.controllers"):
+		return CgroupV2, nil
+	case fileExists("/sys/fs/cgroup/unified/cgroup.controllers"):
+		return CgroupHybrid, nil
+	case fileExists("/proc/cgroups"):
+		return CgroupV1, nil
+	}
+	return "", errors.New("cgroup is not mounted under /sys/fs/cgroup")
+}
+
+// controllerDetails check the controllers are available and delegated. for cgroup v2 the controllers
+// are delegated if enabled in cgroup.subtree_control of root, and for cgroup v1 they are delegated if mounted.
+func (c CgroupCheck) controllerDetails(mode string, mounts []system.Mount) ([]Detail, error) {
+	var available, delegated map[string]bool
+	var err error
+	if mode == CgroupV2 {
+		available, delegated, err = cgroupV2Controllers()
+	} else {
+		available, delegated, err = cgroupV1Controllers(mounts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var details []Detail
+	for _, controller := range c.controllers() {
+		name := controller
+		if mode != CgroupV2 && controller == "io" {
+			// io controller of cgroup v2 is blkio in cgroup v1.
+			name = "blkio"
+		}
+		d := Detail{Name: "controller " + controller, Status: StatusPass, Observed: "delegated", Expected: "delegated"}
+		switch {
+		case !available[name]:
+			d.Status, d.Observed = StatusFail, "unavailable"
+			d.Message = fmt.Sprintf("cgroup controller %s is not available", name)
+		case !delegated[name] && mode == CgroupV2:
+			d.Status, d.Observed = StatusFail, "available"
+			d.Message = fmt.Sprintf("cgroup controller %s is not enabled in cgroup.subtree_control", name)
+		case !delegated[name]:
+			d.Status, d.Observed = StatusFail, "available"
+			d.Message = fmt.Sprintf("cgroup controller %s is not mounted", name)
+		}
+		details = append(details, d)
+	}
+	return details, nil
+}
+
+func cgroupV2Controllers() (available, delegated map[string]bool, err error) {
+	content, err := readHostFile("/sys/fs/cgroup/cgroup.controllers")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read cgroup controllers")
+	}
+	available = fieldSet(content)
+	content, err = readHostFile("/sys/fs/cgroup/cgroup.subtree_control")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, errors.Wrap(err, "failed to read cgroup subtree control")
+	}
+	return available, fieldSet(content), nil
+}
+
+func cgroupV1Controllers(mounts []system.Mount) (available, delegated map[string]bool, err error) {
+	available = make(map[string]bool)
+	// #subsys_name    hierarchy       num_cgroups     enabled
+	// cpuset  0       175     1
+	err = scanHostFile("/proc/cgroups", func(line string) {
+		fields := strings.Fields(line)
+		if len(fields) >= 4 && !strings.HasPrefix(fields[0], "#") && fields[3] == "1" {
+			available[fields[0]] = true
+		}
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read cgroup controllers")
+	}
+
+	delegated = make(map[string]bool)
+	for _, m := range mounts {
+		if m.FsType != "cgroup" {
+			continue
+		}
+		// rw,cpu,cpuacct
+		for _, o := range strings.Split(m.SuperOptions, ",") {
+			delegated[o] = true
+		}
+	}
+	return available, delegated, nil
+}
+
+func fieldSet(content string) map[string]bool {
+	set := make(map[string]bool)
+	for _, f := range strings.Fields(content) {
+		set[f] = true
+	}
+	return set
+}
+
+func (c CgroupCheck) systemdDetail(mode string, systemd bool, version int) Detail {
+	d := Detail{Name: "systemd", Status: StatusPass}
+	if c.MinSystemdVersion > 0 {
+		d.Expected = fmt.Sprintf(">=%d", c.MinSystemdVersion)
+	}
+	switch {
+	case !systemd && c.MinSystemdVersion > 0:
+		d.Status, d.Observed = StatusFail, "not booted"
+		d.Message = "systemd is required, but the host is not booted with systemd"
+	case !systemd:
+		d.Status, d.Observed = StatusSkip, "not booted"
+	case version == 0:
+		d.Status, d.Observed = StatusWarn, "unknown"
+		d.Message = "failed to detect the version of systemd"
+	case version < c.MinSystemdVersion:
+		d.Status, d.Observed = StatusFail, strconv.Itoa(version)
+		d.Message = fmt.Sprintf("systemd version %d is less than the required %d", version, c.MinSystemdVersion)
+	case mode == CgroupV2 && version < CgroupSystemdVersionForV2:
+		d.Status, d.Observed = StatusWarn, strconv.Itoa(version)
+		d.Message = fmt.Sprintf("systemd %d is older than %d recommended for cgroup v2", version, CgroupSystemdVersionForV2)
+	default:
+		d.Observed = strconv.Itoa(version)
+	}
+	return d
+}
+
+// isSystemdBooted return true if the host is booted with systemd, the same as sd_booted(3).
+func isSystemdBooted() bool {
+	info, err := os.Stat(hostPath("/run/systemd/system"))
+	return err == nil && info.IsDir()
+}
+
+// systemdVersion return the version of systemd by its shared library, or systemctl --version
+// if the library is not found, 0 means unknown.
+func systemdVersion(ctx context.Context) (int, error) {
+	for _, dir := range []string{"/usr/lib/systemd", "/usr/lib64/systemd", "/lib/systemd"} {
+		libs, err := filepath.Glob(filepath.Join(hostPath(dir), "libsystemd-shared-*.so"))
+		if err != nil {
+			return 0, err
+		}
+		for _, lib := range libs {
+			if match := systemdVersionRegexp.FindStringSubmatch(filepath.Base(lib)); match != nil {
+				return strconv.Atoi(match[1])
+			}
+		}
+	}
+	if HostRoot != "/" {
+		// the systemctl of the host could not run in the container.
+		return 0, nil
+	}
+
+	// systemd 249 (249.11-0ubuntu3.12)
+	out, err := exec.CommandContext(ctx, "systemctl", "--version").Output()
+	if err != nil {
+		return 0, nil
+	}
+	if match := systemdVersionRegexp.FindSubmatch(bytes.TrimSpace(out)); match != nil {
+		return strconv.Atoi(string(match[1]))
+	}
+	return 0, nil
+}
+
+// cgroupDriver is the cgroup driver configured for a component.
+type cgroupDriver struct {
+	component string
+	driver    string
+}
+
+// driverDetails check the cgroup drivers of kubelet, containerd and docker, the components not installed are ignored.
+func (c CgroupCheck) driverDetails(mode string, systemd bool) ([]Detail, error) {
+	drivers, err := cgroupDrivers(mode, systemd)
+	if err != nil {
+		return nil, err
+	}
+	if len(drivers) == 0 {
+		return []Detail{{Name: "cgroup driver", Status: StatusSkip, Message: "none of kubelet, containerd and docker is configured"}}, nil
+	}
+
+	expected, strict := c.Driver, c.Driver != ""
+	if !strict {
+		expected = container.CgroupDriverCgroupfs
+		if systemd {
+			expected = container.CgroupDriverSystemd
+		}
+	}
+
+	var details []Detail
+	var observed []string
+	consistent := true
+	for _, d := range drivers {
+		detail := Detail{Name: d.component + " cgroup driver", Status: StatusPass, Observed: d.driver, Expected: expected}
+		switch {
+		case d.driver == expected:
+		case strict:
+			detail.Status = StatusFail
+			detail.Message = fmt.Sprintf("cgroup driver of %s is %s, but required %s", d.component, d.driver, expected)
+		case systemd:
+			detail.Status = StatusWarn
+			detail.Message = fmt.Sprintf("cgroup driver of %s is %s, but %s is recommended since the host is booted with systemd", d.component, d.driver, expected)
+		default:
+			detail.Status = StatusWarn
+			detail.Message = fmt.Sprintf("cgroup driver of %s is %s, but the host is not booted with systemd", d.component, d.driver)
+		}
+		details = append(details, detail)
+		observed = append(observed, d.component+"="+d.driver)
+		consistent = consistent && d.driver == drivers[0].driver
+	}
+	if !strict && !consistent {
+		details = append(details, Detail{Name: "cgroup driver consistency", Status: StatusFail,
+			Observed: strings.Join(observed, ","), Expected: "the same driver",
+			Message: fmt.Sprintf("cgroup drivers mismatch: %s", strings.Join(observed, ","))})
+	}
+	return details, nil
+}
+
+// cgroupDrivers return the configured cgroup drivers of kubelet, containerd and docker.
+func cgroupDrivers(mode string, systemd bool) ([]cgroupDriver, error) {
+	var drivers []cgroupDriver
+
+	// cgroupDriver: systemd
+	data, err := os.ReadFile(hostPath("/var/lib/kubelet/config.yaml"))
+	if err == nil {
+		var config struct {
+			CgroupDriver string `yaml:"cgroupDriver"`
+		}
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return nil, errors.Wrap(err, "failed to parse kubelet config")
+		}
+		if config.CgroupDriver == "" {
+			config.CgroupDriver = container.CgroupDriverCgroupfs
+		}
+		drivers = append(drivers, cgroupDriver{component: "kubelet", driver: config.CgroupDriver})
+	} else if !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to read kubelet config")
+	}
+
+	// [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc.options]
+	//   SystemdCgroup = true
+	containerd := ""
+	err = scanHostFile("/etc/containerd/config.toml", func(line string) {
+		if match := systemdCgroupRegexp.FindStringSubmatch(line); match != nil && containerd != container.CgroupDriverSystemd {
+			containerd = container.CgroupDriverCgroupfs
+			if match[1] == "true" {
+				containerd = container.CgroupDriverSystemd
+			}
+		}
+	})
+	if err == nil {
+		if containerd == "" {
+			containerd = container.CgroupDriverCgroupfs
+		}
+		drivers = append(drivers, cgroupDriver{component: "containerd", driver: containerd})
+	} else if !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to read containerd config")
+	}
+
+	docker, err := dockerCgroupDriver(mode, systemd)
+	if err != nil {
+		return nil, err
+	}
+	if docker != "" {
+		drivers = append(drivers, cgroupDriver{component: "docker", driver: docker})
+	}
+	return drivers, nil
+}
+
+// dockerCgroupDriver return the cgroup driver of docker by native.cgroupdriver of exec-opts, or the default one
+// if dockerd is installed, docker uses systemd by default on cgroup v2 with systemd, or cgroupfs otherwise.
+func dockerCgroupDriver(mode string, systemd bool) (string, error) {
+	// {"exec-opts": ["native.cgroupdriver=systemd"]}
+	data, err := os.ReadFile(hostPath("/etc/docker/daemon.json"))
+	if err == nil {
+		var config struct {
+			ExecOpts []string `json:"exec-opts"`
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return "", errors.Wrap(err, "failed to parse docker daemon.json")
+		}
+		for _, opt := range config.ExecOpts {
+			kv := strings.SplitN(opt, "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "native.cgroupdriver" {
+				return strings.TrimSpace(kv[1]), nil
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return "", errors.Wrap(err, "failed to read docker daemon.json")
+	} else if !fileExists("/usr/bin/dockerd") && !fileExists("/usr/local/bin/dockerd") {
+		return "", nil
+	}
+
+	if mode == CgroupV2 && systemd {
+		return container.CgroupDriverSystemd, nil
+	}
+	return container.CgroupDriverCgroupfs, nil
+}
@@ -14,6 +14,11 @@
 
 package checker
 
+import (
+	"context"
+	"sync"
+)
+
 // Interface validates the state of the system or network.
 type Interface interface {
 	// Validate the asset value for the checker.
@@ -27,15 +32,127 @@ type Interface interface {
 	Metadata() Metadata
 }
 
-//Metadata contains useful information regarding the check
+// ContextValidator is implemented by checkers which could stop validating as soon as ctx is done,
+// such as the ones access network or remote hosts.
+type ContextValidator interface {
+	// ValidateContext is the same as Validate, but returns ctx.Err() if ctx is done before validate finished.
+	ValidateContext(ctx context.Context) (bool, error)
+}
+
+// ValidateContext validate the checker with ctx. if the checker does not implement ContextValidator,
+// Validate will run in background and its result will be dropped once ctx is done, see WithBackground.
+func ValidateContext(ctx context.Context, c Interface) (bool, error) {
+	if cv, ok := c.(ContextValidator); ok {
+		return cv.ValidateContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	type validateResult struct {
+		passed bool
+		err    error
+	}
+	ch := make(chan validateResult, 1)
+	goBackground(ctx, func() {
+		passed, err := c.Validate()
+		ch <- validateResult{passed: passed, err: err}
+	})
+
+	select {
+	case r := <-ch:
+		return r.passed, r.err
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+type backgroundKey struct{}
+
+// WithBackground return a ctx which tracks the checkers still running in background after ctx is done,
+// wait returns once all of them returned, it is used to keep the exclusive lock until the checker really finished.
+func WithBackground(ctx context.Context) (_ context.Context, wait func()) {
+	wg := &sync.WaitGroup{}
+	return context.WithValue(ctx, backgroundKey{}, wg), wg.Wait
+}
+
+// goBackground run f in a goroutine tracked by the WithBackground of ctx if any.
+func goBackground(ctx context.Context, f func()) {
+	wg, ok := ctx.Value(backgroundKey{}).(*sync.WaitGroup)
+	if !ok {
+		go f()
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		f()
+	}()
+}
+
+// Exclusive is implemented by checkers which could not run with other checkers at the same time,
+// such as the ones bind ports.
+type Exclusive interface {
+	Exclusive() bool
+}
+
+// IsExclusive return true if the checker requires to run exclusively.
+func IsExclusive(c Interface) bool {
+	e, ok := c.(Exclusive)
+	return ok && e.Exclusive()
+}
+
+// Identified is implemented by checkers which have an ID other than their PrettyName.
+type Identified interface {
+	ID() string
+}
+
+// ID return the identity of checker which is referred by the dependencies of other checkers,
+// it is the PrettyName of checker by default.
+func ID(c Interface) string {
+	if i, ok := c.(Identified); ok && i.ID() != "" {
+		return i.ID()
+	}
+	return c.PrettyName()
+}
+
+// ArgsValidator is implemented by checkers whose args could be invalid, such as the ones decoded from plan file.
+type ArgsValidator interface {
+	// ValidateArgs return error if the args of checker are invalid.
+	ValidateArgs() error
+}
+
+// ValidateArgs return error if the args of checker are invalid.
+func ValidateArgs(c Interface) error {
+	if v, ok := c.(ArgsValidator); ok {
+		return v.ValidateArgs()
+	}
+	return nil
+}
+
+// Dependent is implemented by checkers which only make sense if other checkers passed.
+type Dependent interface {
+	// Dependencies return the IDs of checkers this checker depends on.
+	Dependencies() []string
+}
+
+// Dependencies return the IDs of checkers which the checker depends on.
+func Dependencies(c Interface) []string {
+	if d, ok := c.(Dependent); ok {
+		return d.Dependencies()
+	}
+	return nil
+}
+
+// Metadata contains useful information regarding the check
 type Metadata struct {
 	// short description for checker.
-	Description string `json:"description,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
 	// indicate the check level,it is useful to show if Validate failed.
 	// like info,warn,fatal,panic.
-	Level string `json:"level,omitempty"`
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
 	// long message for show checker,used to get help message，generally it shows why this check failed.
-	Explain string `json:"explain,omitempty"`
+	Explain string `json:"explain,omitempty" yaml:"explain,omitempty"`
 	// show Suggestion if Validate failed.
-	Suggestion string `json:"suggestion,omitempty"`
+	Suggestion string `json:"suggestion,omitempty" yaml:"suggestion,omitempty"`
 }
@@ -48,7 +48,6 @@ func (s *Disk) Scan(value interface{}) error {
 	default:
 		return fmt.Errorf("cannot sql.Scanner.Scan() Disk from: %#v", v)
 	}
-	return nil
 }
 
 // DiskSlice disk slice
@@ -71,5 +70,4 @@ func (s *DiskSlice) Scan(value interface{}) error {
 	default:
 		return fmt.Errorf("cannot sql.Scanner.Scan() DiskSlice from: %#v", v)
 	}
-	return nil
 }
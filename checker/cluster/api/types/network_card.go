@@ -34,7 +34,6 @@ func (s *NetWorkCard) Scan(value interface{}) error {
 	default:
 		return fmt.Errorf("cannot sql.Scanner.Scan() NetWorkCard from: %#v", v)
 	}
-	return nil
 }
 
 type NetWorkCardSlice []*NetWorkCard
@@ -56,5 +55,4 @@ func (s *NetWorkCardSlice) Scan(value interface{}) error {
 	default:
 		return fmt.Errorf("cannot sql.Scanner.Scan() NetWorkCardSlice from: %#v", v)
 	}
-	return nil
 }
@@ -27,5 +27,4 @@ func (s *PortSlice) Scan(value interface{}) error {
 	default:
 		return fmt.Errorf("cannot sql.Scanner.Scan() PortSlice from: %#v", v)
 	}
-	return nil
 }
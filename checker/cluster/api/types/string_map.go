@@ -26,5 +26,4 @@ func (sm *StringMap) Scan(value interface{}) error {
 	default:
 		return fmt.Errorf("cannot sql.Scanner.Scan() StringMap from: %#v", v)
 	}
-	return nil
 }
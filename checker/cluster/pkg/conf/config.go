@@ -2,13 +2,10 @@ package conf
 
 import (
 	"fmt"
-	"preflight/pkg/sshcmd/sshutil"
 )
 
 var ScriptDir string
 
-var SshConfig = sshutil.SSH{}
-
 // OS contains the OS name and the kernel version
 type OS struct {
 	OSName        string `json:"osName,omitempty" yaml:"osName,omitempty"`
@@ -11,7 +11,6 @@ import (
 	"os"
 	"path/filepath"
 	"preflight/checker/cluster/api/types"
-	"preflight/checker/cluster/pkg/conf"
 	"preflight/checker/cluster/pkg/parse"
 	"preflight/pkg/logger"
 	"preflight/pkg/sshcmd/sshutil"
@@ -20,11 +19,11 @@ import (
 	"strings"
 )
 
-func ParseClusterInfo(brief *types.ClusterInfoBrief) (clusterInfoDetailed *types.ClusterInfoDetailed, instanceInfoExtends map[string]*types.InstanceInfoExtended, err error) {
+func ParseClusterInfo(brief *types.ClusterInfoBrief, ssh sshutil.SSH) (clusterInfoDetailed *types.ClusterInfoDetailed, instanceInfoExtends map[string]*types.InstanceInfoExtended, err error) {
 	detailed := &types.ClusterInfoDetailed{}
 	instanceInfoExtends = make(map[string]*types.InstanceInfoExtended, len(brief.Hosts))
 
-	err = parseInstances(brief.Hosts, conf.SshConfig, detailed, instanceInfoExtends)
+	err = parseInstances(brief.Hosts, ssh, detailed, instanceInfoExtends)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -87,7 +86,7 @@ func parseInstances(hosts []string, sshConfig sshutil.SSH, info *types.ClusterIn
 				info.InstanceInfos = append(info.InstanceInfos, *instanceInfo)
 				instanceInfoExtends[IPFormat(host)] = instanceInfoExtend
 			} else { // command error
-
+				return errors.Errorf("failed to execute command %s:%s, output is nil", host, remoteScriptPath)
 			}
 		} else {
 			return errors.Errorf("[%s]script %s is not found", host, remoteScriptPath)
@@ -11,14 +11,40 @@ import (
 	"time"
 )
 
-func ValidateAll(brief *types.ClusterInfoBrief) (bool, error) {
-	clusterInfoDetailed, instanceInfoExtends, err := ParseClusterInfo(brief)
+// HostResult is the result of validating a host of the cluster, Failures are the requirements not met by it.
+type HostResult struct {
+	Host string
+	// Observed is the OS and hardware resource of the host.
+	Observed string
+	Failures []string
+}
+
+// Inspect the instances of the hosts through ssh, it is replaced by tests which could not reach the hosts.
+var Inspect = ParseClusterInfo
+
+// RemoteTime return the unix time of the host through ssh, it is replaced by tests which could not reach the hosts.
+var RemoteTime = func(ssh sshutil.SSH, host string) (int64, error) {
+	output := ssh.Cmd(host, "date +%s")
+	if output == nil {
+		return 0, fmt.Errorf("get remote time of %s failed, output is nil", host)
+	}
+	remoteTime, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
 	if err != nil {
-		return false, fmt.Errorf("parse cluster info failed")
+		return 0, fmt.Errorf("get remote time of %s failed, error:%s", host, err.Error())
+	}
+	return remoteTime, nil
+}
+
+// ValidateAll inspect the hosts through ssh and validate each of them, the error is returned only if the hosts
+// could not be inspected, the requirements not met are the failures of each host.
+func ValidateAll(brief *types.ClusterInfoBrief, ssh sshutil.SSH) ([]HostResult, error) {
+	clusterInfoDetailed, instanceInfoExtends, err := Inspect(brief, ssh)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse cluster info failed")
 	}
 
 	return ClusterValidator{
-		Ssh:                      conf.SshConfig,
+		Ssh:                      ssh,
 		SupportedOS:              conf.SupportedOS,
 		HardwareResourceRequired: conf.HardwareResourceRequired,
 	}.Validate(clusterInfoDetailed, instanceInfoExtends)
@@ -30,20 +56,35 @@ type ClusterValidator struct {
 	HardwareResourceRequired conf.HardwareResource
 }
 
-func (c ClusterValidator) Validate(detailed *types.ClusterInfoDetailed, instanceInfoExtends map[string]*types.InstanceInfoExtended) (bool, error) {
+// Validate return the result of each instance in order, the requirements of cluster scope like the unique hostname
+// and time sync are reported on the hosts breaking them.
+func (c ClusterValidator) Validate(detailed *types.ClusterInfoDetailed, instanceInfoExtends map[string]*types.InstanceInfoExtended) ([]HostResult, error) {
+	results := make([]HostResult, len(detailed.InstanceInfos))
+	index := make(map[string]int, len(detailed.InstanceInfos))
+	for i, instance := range detailed.InstanceInfos {
+		results[i] = HostResult{
+			Host:     instance.PrivateIP,
+			Observed: fmt.Sprintf("%s %s, kernel %s, %s, %d cores, %dGB memory", instance.OS, instance.OSVersion, instance.Kernel, instance.Arch, instance.CPU, instance.Memory),
+		}
+		index[instance.PrivateIP] = i
+	}
+
 	// check clusterScopeInfo
-	if err := c.validateHostName(detailed); err != nil {
-		return false, errors.Errorf("validate hostname failed, error:%v", err)
+	for host, failure := range c.validateHostName(detailed) {
+		results[index[host]].Failures = append(results[index[host]].Failures, failure)
 	}
 
 	// check time sync
-	_, err := c.IsTimeSyncSvcOK(detailed, instanceInfoExtends)
+	failures, err := c.timeSyncFailures(detailed, instanceInfoExtends)
 	if err != nil {
-		return false, errors.Errorf("validate time sync failed, error:%v", err)
+		return nil, err
+	}
+	for host, failure := range failures {
+		results[index[host]].Failures = append(results[index[host]].Failures, failure)
 	}
 
 	// check all instance
-	for _, instance := range detailed.InstanceInfos {
+	for i, instance := range detailed.InstanceInfos {
 		os := conf.OS{
 			OSName:        instance.OS,
 			OSVersion:     instance.OSVersion,
@@ -52,37 +93,43 @@ func (c ClusterValidator) Validate(detailed *types.ClusterInfoDetailed, instance
 		}
 
 		if err := c.validateOS(&os); err != nil {
-			return false, errors.Errorf("instance: %s validate OS failed, error: %v", instance.PrivateIP, err)
+			results[i].Failures = append(results[i].Failures, fmt.Sprintf("validate OS failed, error: %v", err))
 		}
 
 		if err := c.validateInstanceResource(instance.CPU, instance.Memory, instance.SystemDisk, instance.DataDisk); err != nil {
-			return false, errors.Errorf("instance: %s validateInstanceResource failed, error: %v", instance.PrivateIP, err)
+			results[i].Failures = append(results[i].Failures, fmt.Sprintf("validateInstanceResource failed, error: %v", err))
 		}
 	}
 
-	return true, nil
+	return results, nil
 }
 
-func (c ClusterValidator) validateHostName(detailed *types.ClusterInfoDetailed) error {
+// validateHostName return the failures of the hosts whose hostname is duplicate with a previous host.
+func (c ClusterValidator) validateHostName(detailed *types.ClusterInfoDetailed) map[string]string {
+	failures := make(map[string]string)
 	hostNameMap := make(map[string]string, len(detailed.InstanceInfos))
 	for _, instance := range detailed.InstanceInfos {
 		if preIP, found := hostNameMap[instance.HostName]; found {
-			return errors.Errorf("hostname of %s is duplicate with host %s", instance.PrivateIP, preIP)
+			failures[instance.PrivateIP] = fmt.Sprintf("hostname %s is duplicate with host %s", instance.HostName, preIP)
+			continue
 		}
 		hostNameMap[instance.HostName] = instance.PrivateIP
 	}
-	return nil
+	return failures
 }
 
-func (c ClusterValidator) IsTimeSyncSvcOK(detailed *types.ClusterInfoDetailed, instanceInfoExtends map[string]*types.InstanceInfoExtended) (bool, error) {
+// timeSyncFailures return the failures of the hosts without the time sync service or out of sync, the error is
+// returned if the time of host could not be queried.
+func (c ClusterValidator) timeSyncFailures(detailed *types.ClusterInfoDetailed, instanceInfoExtends map[string]*types.InstanceInfoExtended) (map[string]string, error) {
+	failures := make(map[string]string)
 	var hasTimeSvcHosts []string
 	var notHasTimeSvcHosts []string
 	for _, instance := range detailed.InstanceInfos {
 		host := instance.PrivateIP
 
 		instanceInfoExtend := instanceInfoExtends[host]
-		if instanceInfoExtend.TimeSyncStatus.Ntpd == "active" && instanceInfoExtend.TimeSyncStatus.Chronyd == "active" {
-			return false, fmt.Errorf("host %s active ntpd.service and chronyd.service both, please disable one of them")
+		if instanceInfoExtend == nil {
+			return nil, fmt.Errorf("time sync status of %s is not found", host)
 		}
 		timeSvc := ""
 		if instanceInfoExtend.TimeSyncStatus.Ntpd == "active" {
@@ -91,37 +138,34 @@ func (c ClusterValidator) IsTimeSyncSvcOK(detailed *types.ClusterInfoDetailed, i
 		if instanceInfoExtend.TimeSyncStatus.Chronyd == "active" {
 			timeSvc = "chrony"
 		}
-		if timeSvc != "" {
-			hasTimeSvcHosts = append(hasTimeSvcHosts, host)
-			//Check time is sync
-			output := c.Ssh.Cmd(host, "date +%s")
-			if output != nil {
-				remoteTime, err := strconv.Atoi(strings.Replace(strings.Replace(string(output), "\r", "", -1), "\n", "", -1))
-				if err != nil {
-					return false, fmt.Errorf("get remote time of %s failed, error:%s", host, err.Error())
-				}
-
-				localTime := time.Now().Unix()
-				timediff := int64(remoteTime) - localTime
-				if (timediff > 5) || (-5 > timediff) {
-					return false, fmt.Errorf("host %s has config %s, but its time diff between master0 greater than 5s", host, timeSvc)
-				}
-			} else { // command error
-				return false, fmt.Errorf("get remote time of %s failed, output is nil", host)
-			}
-		} else {
+		if timeSvc == "" {
 			notHasTimeSvcHosts = append(notHasTimeSvcHosts, host)
+			continue
+		}
+		hasTimeSvcHosts = append(hasTimeSvcHosts, host)
+		if instanceInfoExtend.TimeSyncStatus.Ntpd == "active" && instanceInfoExtend.TimeSyncStatus.Chronyd == "active" {
+			failures[host] = "ntpd.service and chronyd.service are both active, please disable one of them"
+			continue
+		}
+		//Check time is sync
+		remoteTime, err := RemoteTime(c.Ssh, host)
+		if err != nil {
+			return nil, err
+		}
+		timediff := remoteTime - time.Now().Unix()
+		if (timediff > 5) || (-5 > timediff) {
+			failures[host] = fmt.Sprintf("%s is configured, but the time diff with master0 is greater than 5s", timeSvc)
 		}
 	}
 
-	if len(hasTimeSvcHosts) == 0 {
-		return false, fmt.Errorf("all hosts has no time sync service")
-	} else if len(notHasTimeSvcHosts) == 0 {
-		return true, nil
-	} else {
-		return false, fmt.Errorf("some hosts[%s] config time sync service, but some hosts[%s] not, please check",
-			strings.Join(hasTimeSvcHosts, ","), strings.Join(notHasTimeSvcHosts, ","))
+	for _, host := range notHasTimeSvcHosts {
+		if len(hasTimeSvcHosts) == 0 {
+			failures[host] = "no host has time sync service"
+		} else {
+			failures[host] = fmt.Sprintf("no time sync service, but hosts[%s] have, please check", strings.Join(hasTimeSvcHosts, ","))
+		}
 	}
+	return failures, nil
 }
 
 func (c ClusterValidator) validateOS(os *conf.OS) error {
@@ -15,16 +15,22 @@
 package checker
 
 import (
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
 	"preflight/checker/cluster/api/types"
-	"preflight/checker/cluster/pkg/conf"
 	"preflight/checker/cluster/pkg/run"
+	"preflight/pkg/sshcmd/sshutil"
 	"strings"
+	"time"
 )
 
 type ClusterCheck struct {
-	AuthInfo types.ClusterInfoBrief
+	AuthInfo types.ClusterInfoBrief `json:"authInfo" yaml:"authInfo"`
+}
+
+func newClusterCheck(arg string) (Interface, error) {
+	return nil, errors.Errorf("checker %s requires the cluster inventory, it could only be declared in plan file", ClusterCheck{}.Type())
 }
 
 func (m ClusterCheck) Type() string {
@@ -44,28 +50,94 @@ func (ClusterCheck) Metadata() Metadata {
 	}
 }
 
+func (ClusterCheck) Schema() Schema {
+	return Schema{
+		Parameters: []Parameter{
+			{
+				Name:        "authInfo",
+				Type:        "object",
+				Default:     "cluster of plan file",
+				Example:     "{sshUser: root, sshPassword: changeme, hosts: [172.16.0.198]}",
+				Description: "the ssh user, password and hosts of the cluster",
+			},
+		},
+	}
+}
+
 func (m ClusterCheck) Validate() (bool, error) {
-	err := parseConfigs(m.AuthInfo)
+	return ValidateReport(m.Evaluate(context.Background()))
+}
+
+func (m ClusterCheck) ValidateContext(ctx context.Context) (bool, error) {
+	return ValidateReport(m.Evaluate(ctx))
+}
+
+// Exclusive the scripts and the detailed cluster info are written to the shared files while validating.
+func (ClusterCheck) Exclusive() bool {
+	return true
+}
+
+// Evaluate validate the cluster, the ssh connect timeout is limited by the deadline of ctx.
+func (m ClusterCheck) Evaluate(ctx context.Context) (Report, error) {
+	ssh, err := sshConfig(m.AuthInfo)
 	if err != nil {
-		return false, err
+		return Report{}, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := time.Until(deadline)
+		ssh.Timeout = &timeout
 	}
-	ok, err := run.ValidateAll(&m.AuthInfo)
-	if !ok {
-		return false, errors.Errorf("failed to Validate ClusterInfo %v", err)
+
+	type validateResult struct {
+		hosts []run.HostResult
+		err   error
+	}
+	ch := make(chan validateResult, 1)
+	goBackground(ctx, func() {
+		hosts, err := run.ValidateAll(&m.AuthInfo, ssh)
+		ch <- validateResult{hosts: hosts, err: err}
+	})
+
+	select {
+	case r := <-ch:
+		// the failures to inspect the hosts through ssh are errors, while the requirements not met fail the hosts.
+		if r.err != nil {
+			return Report{}, errors.Wrap(r.err, "failed to inspect the cluster")
+		}
+		if len(r.hosts) == 0 {
+			return Report{}, errors.New("no host of the cluster is inspected")
+		}
+		// a detail per host, so that the checker is reported on each host of the cluster.
+		details := make([]Detail, 0, len(r.hosts))
+		met := 0
+		for _, h := range r.hosts {
+			d := Detail{Name: h.Host, Status: StatusPass, Host: h.Host, Observed: h.Observed, Expected: "supported OS and hardware resource"}
+			if len(h.Failures) > 0 {
+				d.Status, d.Message = StatusFail, fmt.Sprintf("%s: %s", h.Host, strings.Join(h.Failures, ", "))
+			} else {
+				met++
+			}
+			details = append(details, d)
+		}
+		report := ReportDetails(details)
+		report.Observed = fmt.Sprintf("%d of %d host(s) met the requirements", met, len(details))
+		report.Expected = "supported OS and hardware resource"
+		return report, nil
+	case <-ctx.Done():
+		return Report{}, ctx.Err()
 	}
-	return true, nil
 }
 
-func parseConfigs(briefInfo types.ClusterInfoBrief) error {
+// sshConfig return the ssh config of the cluster, it is passed down instead of set to the global config,
+// so that the checkers of different clusters do not interfere with each other.
+func sshConfig(briefInfo types.ClusterInfoBrief) (sshutil.SSH, error) {
 	if briefInfo.SshUser == "" {
 		briefInfo.SshUser = "root"
 	}
-	conf.SshConfig.User = briefInfo.SshUser
-
-	conf.SshConfig.Password = briefInfo.SshPassword
+	ssh := sshutil.SSH{User: briefInfo.SshUser, Password: briefInfo.SshPassword}
 	env := make(map[string]string)
 	if len(briefInfo.Hosts) == 0 {
-		return fmt.Errorf("hosts must be config in cluster-info-brief.yaml")
+		return ssh, fmt.Errorf("hosts must be config in the cluster inventory of plan file")
 	} else {
 		var hostsWithoutPort []string
 		for _, host := range briefInfo.Hosts {
@@ -75,7 +147,7 @@ func parseConfigs(briefInfo types.ClusterInfoBrief) error {
 		env["SSHPort"] = "22"
 	}
 
-	conf.SshConfig.Env = env
+	ssh.Env = env
 
-	return nil
+	return ssh, nil
 }
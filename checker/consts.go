@@ -20,3 +20,32 @@ const (
 	WarnLevel  = "warn"
 	InfoLevel  = "info"
 )
+
+// Levels all the supported check levels, ordered from the highest to the lowest.
+var Levels = []string{PanicLevel, FatalLevel, WarnLevel, InfoLevel}
+
+// IsValidLevel return true if the given level is one of Levels.
+func IsValidLevel(level string) bool {
+	for _, l := range Levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// Severity return the severity of level, the higher the more serious, unknown level is regarded as fatal.
+func Severity(level string) int {
+	switch level {
+	case PanicLevel:
+		return 4
+	case FatalLevel:
+		return 3
+	case WarnLevel:
+		return 2
+	case InfoLevel:
+		return 1
+	default:
+		return 3
+	}
+}
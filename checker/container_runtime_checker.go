@@ -0,0 +1,311 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"preflight/pkg/container"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ContainerRuntimeQueryTimeout limits the query of each container runtime, since a stale socket may never respond.
+var ContainerRuntimeQueryTimeout = 10 * time.Second
+
+// ContainerRuntimeCheck finds docker, containerd and CRI-O by their unix sockets, checks the version of
+// the required runtime, and reports the storage driver, cgroup driver and root dir of each active runtime.
+// containerd and CRI-O are queried by ctr and crictl.
+type ContainerRuntimeCheck struct {
+	// Runtime the required runtimes separated by |, like containerd or docker|containerd, empty means any runtime.
+	Runtime string `json:"runtime,omitempty" yaml:"runtime,omitempty"`
+	// Version the version constraint of the required runtime, like >=1.6.0 <2.
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	// CgroupDriver the required cgroup driver of the required runtime, systemd or cgroupfs.
+	CgroupDriver string `json:"cgroupDriver,omitempty" yaml:"cgroupDriver,omitempty"`
+	// Sockets the unix sockets of runtimes on host, like {containerd: /run/k3s/containerd/containerd.sock},
+	// the default sockets are used for the runtimes not specified.
+	Sockets map[string]string `json:"sockets,omitempty" yaml:"sockets,omitempty"`
+}
+
+// newContainerRuntimeCheck build ContainerRuntimeCheck from the required runtime with version constraint and
+// options separated by ";", like "containerd>=1.6.0 <2;cgroup-driver=systemd", empty arg reports any runtime found.
+func newContainerRuntimeCheck(arg string) (Interface, error) {
+	var c ContainerRuntimeCheck
+	for i, r := range strings.Split(arg, ";") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(r, "cgroup-driver="):
+			c.CgroupDriver = strings.TrimSpace(strings.TrimPrefix(r, "cgroup-driver="))
+		case i == 0:
+			if j := strings.IndexAny(r, "<>=!"); j >= 0 {
+				c.Runtime, c.Version = strings.TrimSpace(r[:j]), strings.TrimSpace(r[j:])
+			} else {
+				c.Runtime = r
+			}
+		default:
+			return nil, argError(c.Type(), arg, "unknown option %q, expected cgroup-driver=", r)
+		}
+	}
+	if err := c.ValidateArgs(); err != nil {
+		return nil, argError(c.Type(), arg, "%v", err)
+	}
+	return c, nil
+}
+
+func (c ContainerRuntimeCheck) Type() string {
+	return strings.ToLower("ContainerRuntime")
+}
+
+func (c ContainerRuntimeCheck) PrettyName() string {
+	if rs := c.requirements(); len(rs) > 0 {
+		return fmt.Sprintf("%s:%s", c.Type(), strings.Join(rs, ";"))
+	}
+	return c.Type()
+}
+
+func (ContainerRuntimeCheck) Metadata() Metadata {
+	return Metadata{
+		Description: "Check the container runtime is active with the required version and cgroup driver",
+		Level:       FatalLevel,
+		Explain:     "kubelet could not run pods without a container runtime, and it refuses to choose one if more than one runtime is active.",
+		Suggestion:  "Install the required container runtime, and stop the ones not used, or specify the socket of runtime for kubelet",
+	}
+}
+
+func (ContainerRuntimeCheck) Schema() Schema {
+	return Schema{
+		Arg: &Parameter{
+			Name:        "runtime",
+			Type:        "string",
+			Example:     "containerd>=1.6.0 <2;cgroup-driver=systemd",
+			Description: "the required runtimes separated by |, with an optional version constraint, and options separated by ;",
+		},
+		Parameters: []Parameter{
+			{Name: "runtime", Type: "string", Example: "containerd", Description: "the required runtimes separated by |, one of " + strings.Join(container.Runtimes, ",")},
+			{Name: "version", Type: "string", Example: "'>=1.6.0 <2'", Description: "the version constraint of the required runtime, alternatives are separated by ||"},
+			{Name: "cgroupDriver", Type: "string", Example: container.CgroupDriverSystemd, Description: "the required cgroup driver, systemd or cgroupfs"},
+			{Name: "sockets", Type: "map[string]string", Example: "{containerd: /run/k3s/containerd/containerd.sock}", Description: "the unix sockets of runtimes on host"},
+		},
+	}
+}
+
+func (c ContainerRuntimeCheck) ValidateArgs() error {
+	for _, r := range c.runtimes() {
+		if !isContainerRuntime(r) {
+			return errors.Errorf("unknown runtime %q, expected one of %s", r, strings.Join(container.Runtimes, ","))
+		}
+	}
+	if c.Version != "" {
+		if len(c.runtimes()) != 1 {
+			return errors.New("version constraint requires exactly one runtime")
+		}
+		if _, err := parseVersionConstraint(c.Version); err != nil {
+			return err
+		}
+	}
+	if c.CgroupDriver != "" && c.CgroupDriver != container.CgroupDriverSystemd && c.CgroupDriver != container.CgroupDriverCgroupfs {
+		return errors.Errorf("invalid cgroup driver %q, expected %s or %s", c.CgroupDriver, container.CgroupDriverSystemd, container.CgroupDriverCgroupfs)
+	}
+	for r := range c.Sockets {
+		if !isContainerRuntime(r) {
+			return errors.Errorf("unknown runtime %q of sockets, expected one of %s", r, strings.Join(container.Runtimes, ","))
+		}
+	}
+	return nil
+}
+
+func isContainerRuntime(name string) bool {
+	for _, r := range container.Runtimes {
+		if name == r {
+			return true
+		}
+	}
+	return false
+}
+
+// runtimes return the required runtimes.
+func (c ContainerRuntimeCheck) runtimes() []string {
+	var runtimes []string
+	for _, r := range strings.Split(c.Runtime, "|") {
+		if r = strings.TrimSpace(r); r != "" {
+			runtimes = append(runtimes, r)
+		}
+	}
+	return runtimes
+}
+
+// required return true if the runtime is required, all runtimes are required if none is specified.
+func (c ContainerRuntimeCheck) required(runtime string) bool {
+	runtimes := c.runtimes()
+	for _, r := range runtimes {
+		if r == runtime {
+			return true
+		}
+	}
+	return len(runtimes) == 0
+}
+
+// requirements return the requirements in the format of spec.
+func (c ContainerRuntimeCheck) requirements() []string {
+	var rs []string
+	if c.Runtime != "" || c.Version != "" {
+		rs = append(rs, c.Runtime+c.Version)
+	}
+	if c.CgroupDriver != "" {
+		rs = append(rs, "cgroup-driver="+c.CgroupDriver)
+	}
+	return rs
+}
+
+func (c ContainerRuntimeCheck) socket(runtime string) string {
+	if s, ok := c.Sockets[runtime]; ok && s != "" {
+		return hostPath(s)
+	}
+	return hostPath(container.DefaultSockets[runtime])
+}
+
+func (c ContainerRuntimeCheck) Validate() (bool, error) {
+	return ValidateReport(c.Evaluate(context.Background()))
+}
+
+func (c ContainerRuntimeCheck) ValidateContext(ctx context.Context) (bool, error) {
+	return ValidateReport(c.Evaluate(ctx))
+}
+
+func (c ContainerRuntimeCheck) Evaluate(ctx context.Context) (Report, error) {
+	if err := c.ValidateArgs(); err != nil {
+		return Report{}, err
+	}
+
+	var details []Detail
+	var active []container.Info
+	for _, runtime := range container.Runtimes {
+		socket := c.socket(runtime)
+		if _, err := os.Stat(socket); err != nil {
+			continue
+		}
+		info, err := c.query(ctx, runtime, socket)
+		if ctx.Err() != nil {
+			return Report{}, ctx.Err()
+		}
+		if err != nil {
+			d := Detail{Name: runtime, Status: StatusWarn, Observed: "not responding",
+				Message: fmt.Sprintf("%s socket %s exists, but failed to query: %v", runtime, socket, err)}
+			if c.required(runtime) {
+				d.Status = StatusFail
+			}
+			details = append(details, d)
+			continue
+		}
+		active = append(active, info)
+	}
+
+	docker := false
+	for _, info := range active {
+		docker = docker || info.Name == container.Docker
+	}
+	var found, independent, observed []string
+	for _, info := range active {
+		details = append(details, c.runtimeDetails(info)...)
+		observed = append(observed, strings.TrimSpace(info.Name+" "+info.Version))
+		if c.required(info.Name) {
+			found = append(found, info.Name)
+		}
+		// the containerd without cri plugin is only used by docker.
+		if info.Name != container.Containerd || info.CRI || !docker {
+			independent = append(independent, info.Name)
+		}
+	}
+
+	if len(found) == 0 {
+		expected := c.Runtime
+		if expected == "" {
+			expected = strings.Join(container.Runtimes, "|")
+		}
+		details = append(details, Detail{Name: "container runtime", Status: StatusFail, Observed: "none", Expected: expected,
+			Message: fmt.Sprintf("none of the container runtimes %s is active", expected)})
+	}
+	if len(independent) > 1 {
+		details = append(details, Detail{Name: "active runtimes", Status: StatusWarn, Observed: strings.Join(independent, ","), Expected: "one runtime",
+			Message: fmt.Sprintf("more than one container runtime is active: %s", strings.Join(independent, ","))})
+	}
+
+	report := ReportDetails(details)
+	report.Observed = strings.Join(observed, ", ")
+	report.Expected = strings.Join(c.requirements(), ";")
+	return report, nil
+}
+
+func (c ContainerRuntimeCheck) query(ctx context.Context, runtime, socket string) (container.Info, error) {
+	ctx, cancel := context.WithTimeout(ctx, ContainerRuntimeQueryTimeout)
+	defer cancel()
+	return container.GetInfo(ctx, runtime, socket)
+}
+
+// runtimeDetails check the version and cgroup driver of the required runtime, and report the others.
+func (c ContainerRuntimeCheck) runtimeDetails(info container.Info) []Detail {
+	required := c.required(info.Name)
+	version := Detail{Name: info.Name, Status: StatusPass, Observed: info.Version}
+	if required && c.Version != "" {
+		version.Expected = c.Version
+		constraint, _ := parseVersionConstraint(c.Version)
+		v, err := parseVersion(info.Version)
+		switch {
+		case err != nil:
+			version.Status = StatusFail
+			version.Message = fmt.Sprintf("failed to parse %s version %q", info.Name, info.Version)
+		case !constraint.match(v):
+			version.Status = StatusFail
+			version.Message = fmt.Sprintf("%s version %s does not match %s", info.Name, info.Version, c.Version)
+		}
+	}
+	details := []Detail{version}
+
+	if info.Name == container.Containerd && !info.CRI {
+		d := Detail{Name: "containerd cri plugin", Status: StatusSkip, Observed: "disabled", Message: "the cri plugin of containerd is disabled"}
+		if c.Runtime != "" && required {
+			d.Status = StatusFail
+			d.Message = "containerd is required, but its cri plugin is disabled"
+		}
+		return append(details, d)
+	}
+
+	for _, item := range []struct{ name, value string }{
+		{"storage driver", info.StorageDriver},
+		{"root dir", info.RootDir},
+	} {
+		if item.value != "" {
+			details = append(details, Detail{Name: info.Name + " " + item.name, Status: StatusPass, Observed: item.value})
+		}
+	}
+	driver := Detail{Name: info.Name + " cgroup driver", Status: StatusPass, Observed: info.CgroupDriver}
+	if required && c.CgroupDriver != "" {
+		driver.Expected = c.CgroupDriver
+		if info.CgroupDriver != c.CgroupDriver {
+			driver.Status = StatusFail
+			driver.Message = fmt.Sprintf("cgroup driver of %s is %q, but required %s", info.Name, info.CgroupDriver, c.CgroupDriver)
+		}
+	}
+	if driver.Observed != "" || driver.Expected != "" {
+		details = append(details, driver)
+	}
+	return details
+}
@@ -15,16 +15,24 @@
 package checker
 
 import (
+	"context"
 	"fmt"
 	"runtime"
+	"strconv"
 	"strings"
-
-	"github.com/pkg/errors"
 )
 
 // NumCPUCheck checks if current number of CPUs is not less than required
 type NumCPUCheck struct {
-	NumCPU int
+	NumCPU int `json:"numCPU" yaml:"numCPU"`
+}
+
+func newNumCPUCheck(arg string) (Interface, error) {
+	numCPU, err := strconv.Atoi(arg)
+	if err != nil || numCPU <= 0 {
+		return nil, argError(NumCPUCheck{}.Type(), arg, "expected a positive number of CPUs")
+	}
+	return NumCPUCheck{NumCPU: numCPU}, nil
 }
 
 func (c NumCPUCheck) Type() string {
@@ -44,11 +52,27 @@ func (NumCPUCheck) Metadata() Metadata {
 	}
 }
 
+func (NumCPUCheck) Schema() Schema {
+	numCPU := Parameter{
+		Name:        "numCPU",
+		Type:        "int",
+		Unit:        "cores",
+		Example:     "2",
+		Description: "the minimum number of CPUs available",
+	}
+	return Schema{Arg: &numCPU, Parameters: []Parameter{numCPU}}
+}
+
 func (c NumCPUCheck) Validate() (bool, error) {
+	return ValidateReport(c.Evaluate(context.Background()))
+}
+
+func (c NumCPUCheck) Evaluate(ctx context.Context) (Report, error) {
 	numCPU := runtime.NumCPU()
+	observed, expected := strconv.Itoa(numCPU), fmt.Sprintf(">=%d", c.NumCPU)
 	if numCPU < c.NumCPU {
-		return false, errors.Errorf(
-			"the number of available CPUs %d is less than the required %d", numCPU, c.NumCPU)
+		return Failed(observed, expected,
+			"the number of available CPUs %d is less than the required %d", numCPU, c.NumCPU), nil
 	}
-	return true, nil
+	return Passed(observed, expected), nil
 }
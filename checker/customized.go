@@ -0,0 +1,74 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import "context"
+
+// Customized wraps a checker with the settings declared for it in a plan file.
+type Customized struct {
+	Interface
+	// Level overrides the level of the wrapped checker if not empty.
+	Level string
+	// CheckerID overrides the ID of the wrapped checker if not empty.
+	CheckerID string
+	// DependsOn the IDs of checkers which should pass before running the wrapped checker.
+	DependsOn []string
+}
+
+func (c Customized) Metadata() Metadata {
+	m := c.Interface.Metadata()
+	if c.Level != "" {
+		m.Level = c.Level
+	}
+	return m
+}
+
+func (c Customized) ValidateContext(ctx context.Context) (bool, error) {
+	return ValidateContext(ctx, c.Interface)
+}
+
+func (c Customized) Evaluate(ctx context.Context) (Report, error) {
+	return Evaluate(ctx, c.Interface)
+}
+
+func (c Customized) ValidateArgs() error {
+	return ValidateArgs(c.Interface)
+}
+
+func (c Customized) Schema() Schema {
+	return GetSchema(c.Interface)
+}
+
+func (c Customized) Exclusive() bool {
+	return IsExclusive(c.Interface)
+}
+
+func (c Customized) ID() string {
+	if c.CheckerID != "" {
+		return c.CheckerID
+	}
+	return ID(c.Interface)
+}
+
+func (c Customized) Dependencies() []string {
+	var deps []string
+	deps = append(deps, Dependencies(c.Interface)...)
+	return append(deps, c.DependsOn...)
+}
+
+// Unwrap return the wrapped checker.
+func (c Customized) Unwrap() Interface {
+	return c.Interface
+}
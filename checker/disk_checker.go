@@ -0,0 +1,276 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"fmt"
+	"preflight/pkg/system"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DiskCheck checks the space and inodes of the file system which backs the path,
+// the empty threshold is not checked.
+type DiskCheck struct {
+	// Path is the directory required, its nearest existing parent is checked if it not exists yet.
+	Path string `json:"path" yaml:"path"`
+	// MinTotal the minimum total size, like 100Gi.
+	MinTotal string `json:"minTotal,omitempty" yaml:"minTotal,omitempty"`
+	// MinFree the minimum free size like 50Gi, or the minimum free percent like 10%.
+	MinFree string `json:"minFree,omitempty" yaml:"minFree,omitempty"`
+	// MaxUsedPercent the maximum used percent, like 80%.
+	MaxUsedPercent string `json:"maxUsedPercent,omitempty" yaml:"maxUsedPercent,omitempty"`
+	// MinFreeInodes the minimum free inodes like 100000, or the minimum free percent like 10%.
+	MinFreeInodes string `json:"minFreeInodes,omitempty" yaml:"minFreeInodes,omitempty"`
+	// MaxInodesUsedPercent the maximum used percent of inodes, like 90%.
+	MaxInodesUsedPercent string `json:"maxInodesUsedPercent,omitempty" yaml:"maxInodesUsedPercent,omitempty"`
+}
+
+// metrics of disk spec, the empty one means free space.
+var diskSpecMetrics = map[string]struct {
+	op    string
+	field func(d *DiskCheck) *string
+}{
+	"":            {">=", func(d *DiskCheck) *string { return &d.MinFree }},
+	"free":        {">=", func(d *DiskCheck) *string { return &d.MinFree }},
+	"total":       {">=", func(d *DiskCheck) *string { return &d.MinTotal }},
+	"used":        {"<=", func(d *DiskCheck) *string { return &d.MaxUsedPercent }},
+	"inodes":      {">=", func(d *DiskCheck) *string { return &d.MinFreeInodes }},
+	"inodes-used": {"<=", func(d *DiskCheck) *string { return &d.MaxInodesUsedPercent }},
+}
+
+// newDiskCheck build DiskCheck from arg like "/var/lib/containerd>=50Gi;inodes>=10%", the thresholds are
+// separated by ";", the first one follows the path and the metric of it could be omitted to mean free space.
+func newDiskCheck(arg string) (Interface, error) {
+	i := strings.IndexAny(arg, "<>=")
+	if i <= 0 {
+		return nil, argError(DiskCheck{}.Type(), arg, "expected path with thresholds, like /var/lib/containerd>=50Gi")
+	}
+	d := DiskCheck{Path: strings.TrimSpace(arg[:i])}
+	for _, threshold := range strings.Split(arg[i:], ";") {
+		threshold = strings.TrimSpace(threshold)
+		j := strings.IndexAny(threshold, "<>=")
+		if j < 0 {
+			return nil, argError(d.Type(), arg, "invalid threshold %q, expected like inodes>=10%%", threshold)
+		}
+		name, rest := strings.TrimSpace(threshold[:j]), threshold[j:]
+		metric, ok := diskSpecMetrics[name]
+		if !ok {
+			return nil, argError(d.Type(), arg, "unknown metric %q, expected one of free,total,used,inodes,inodes-used", name)
+		}
+		if !strings.HasPrefix(rest, metric.op) {
+			return nil, argError(d.Type(), arg, "metric %q only supports %s", name, metric.op)
+		}
+		*metric.field(&d) = strings.TrimSpace(strings.TrimPrefix(rest, metric.op))
+	}
+	if err := d.ValidateArgs(); err != nil {
+		return nil, argError(d.Type(), arg, "%v", err)
+	}
+	return d, nil
+}
+
+func (d DiskCheck) Type() string {
+	return strings.ToLower("Disk")
+}
+
+func (d DiskCheck) PrettyName() string {
+	return fmt.Sprintf("%s:%s", d.Type(), d.Path)
+}
+
+func (DiskCheck) Metadata() Metadata {
+	return Metadata{
+		Description: "Check the space and inodes of the file system which backs the path",
+		Level:       FatalLevel,
+		Explain:     "the program may fail to write data, or the node may be evicted by disk pressure, if the file system is nearly full.",
+		Suggestion:  "Maybe you should clean up the disk, or mount a larger disk on the path",
+	}
+}
+
+func (DiskCheck) Schema() Schema {
+	return Schema{
+		Arg: &Parameter{
+			Name:        "path",
+			Type:        "string",
+			Example:     "/var/lib/containerd>=50Gi;inodes>=10%",
+			Description: "the path followed by thresholds separated by ;, metrics are free(default),total,used,inodes,inodes-used",
+		},
+		Parameters: []Parameter{
+			{Name: "path", Type: "string", Example: "/var/lib/containerd", Description: "the path required, its nearest existing parent is checked if it not exists"},
+			{Name: "minTotal", Type: "size", Unit: "bytes", Example: "100Gi", Description: "the minimum total size"},
+			{Name: "minFree", Type: "size or percent", Unit: "bytes", Example: "50Gi", Description: "the minimum free size or percent"},
+			{Name: "maxUsedPercent", Type: "percent", Example: "80%", Description: "the maximum used percent"},
+			{Name: "minFreeInodes", Type: "int or percent", Example: "10%", Description: "the minimum free inodes or percent"},
+			{Name: "maxInodesUsedPercent", Type: "percent", Example: "90%", Description: "the maximum used percent of inodes"},
+		},
+	}
+}
+
+func (d DiskCheck) ValidateArgs() error {
+	if d.Path == "" {
+		return errors.New("path is required")
+	}
+	if d.MinTotal == "" && d.MinFree == "" && d.MaxUsedPercent == "" && d.MinFreeInodes == "" && d.MaxInodesUsedPercent == "" {
+		return errors.New("at least one threshold is required")
+	}
+	if d.MinTotal != "" {
+		if _, err := parseSize(d.MinTotal); err != nil {
+			return err
+		}
+	}
+	for _, s := range []string{d.MaxUsedPercent, d.MaxInodesUsedPercent} {
+		if s == "" {
+			continue
+		}
+		if _, err := parsePercent(s); err != nil {
+			return err
+		}
+	}
+	if d.MinFree != "" {
+		if _, _, err := parseDiskAmount(d.MinFree, parseSize); err != nil {
+			return err
+		}
+	}
+	if d.MinFreeInodes != "" {
+		if _, _, err := parseDiskAmount(d.MinFreeInodes, parseCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d DiskCheck) Validate() (bool, error) {
+	return ValidateReport(d.Evaluate(context.Background()))
+}
+
+func (d DiskCheck) Evaluate(ctx context.Context) (Report, error) {
+	if err := d.ValidateArgs(); err != nil {
+		return Report{}, err
+	}
+	usage, err := system.GetDiskUsage(d.Path)
+	if err != nil {
+		return Report{}, errors.Wrapf(err, "failed to get disk usage of %s", d.Path)
+	}
+
+	var details []Detail
+	if d.MinTotal != "" {
+		required, _ := parseSize(d.MinTotal)
+		details = append(details, d.atLeast("total", usage, formatSize(usage.Total), d.MinTotal, usage.Total >= required))
+	}
+	if d.MinFree != "" {
+		required, percent, _ := parseDiskAmount(d.MinFree, parseSize)
+		if percent {
+			free := 100 - usage.UsedPercent
+			details = append(details, d.atLeast("free", usage, formatPercent(free), d.MinFree, free >= required))
+		} else {
+			details = append(details, d.atLeast("free", usage, formatSize(usage.Free), d.MinFree, float64(usage.Free) >= required))
+		}
+	}
+	if d.MaxUsedPercent != "" {
+		limit, _ := parsePercent(d.MaxUsedPercent)
+		details = append(details, d.atMost("used", usage, formatPercent(usage.UsedPercent), d.MaxUsedPercent, usage.UsedPercent <= limit))
+	}
+	if d.MinFreeInodes != "" || d.MaxInodesUsedPercent != "" {
+		details = append(details, d.inodeDetails(usage)...)
+	}
+
+	report := ReportDetails(details)
+	report.Observed = fmt.Sprintf("%s mounted on %s", usage.Device, usage.MountPoint)
+	report.Expected = strings.Join(d.thresholds(), ";")
+	return report, nil
+}
+
+func (d DiskCheck) inodeDetails(usage system.DiskUsage) []Detail {
+	// some file systems like btrfs allocate inodes dynamically, they report no inodes.
+	if usage.InodesTotal == 0 {
+		return []Detail{{
+			Name:    "inodes",
+			Status:  StatusWarn,
+			Message: fmt.Sprintf("%s of %s does not report inodes, the inode thresholds are not checked", usage.FsType, usage.MountPoint),
+		}}
+	}
+
+	var details []Detail
+	if d.MinFreeInodes != "" {
+		required, percent, _ := parseDiskAmount(d.MinFreeInodes, parseCount)
+		if percent {
+			free := 100 - usage.InodesUsedPercent
+			details = append(details, d.atLeast("inodes free", usage, formatPercent(free), d.MinFreeInodes, free >= required))
+		} else {
+			details = append(details, d.atLeast("inodes free", usage, strconv.FormatUint(usage.InodesFree, 10), d.MinFreeInodes, float64(usage.InodesFree) >= required))
+		}
+	}
+	if d.MaxInodesUsedPercent != "" {
+		limit, _ := parsePercent(d.MaxInodesUsedPercent)
+		details = append(details, d.atMost("inodes used", usage, formatPercent(usage.InodesUsedPercent), d.MaxInodesUsedPercent, usage.InodesUsedPercent <= limit))
+	}
+	return details
+}
+
+func (d DiskCheck) atLeast(name string, usage system.DiskUsage, observed, required string, ok bool) Detail {
+	return d.detail(name, usage, observed, ">="+required, ok, "less than")
+}
+
+func (d DiskCheck) atMost(name string, usage system.DiskUsage, observed, limit string, ok bool) Detail {
+	return d.detail(name, usage, observed, "<="+limit, ok, "more than")
+}
+
+func (d DiskCheck) detail(name string, usage system.DiskUsage, observed, expected string, ok bool, compare string) Detail {
+	detail := Detail{Name: name, Status: StatusPass, Observed: observed, Expected: expected}
+	if !ok {
+		detail.Status = StatusFail
+		detail.Message = fmt.Sprintf("%s of %s (%s mounted on %s) is %s, %s the required %s",
+			name, d.Path, usage.Device, usage.MountPoint, observed, compare, expected[2:])
+	}
+	return detail
+}
+
+// thresholds return the thresholds in the format of spec.
+func (d DiskCheck) thresholds() []string {
+	var ts []string
+	for _, t := range []struct{ name, op, value string }{
+		{"total", ">=", d.MinTotal},
+		{"free", ">=", d.MinFree},
+		{"used", "<=", d.MaxUsedPercent},
+		{"inodes", ">=", d.MinFreeInodes},
+		{"inodes-used", "<=", d.MaxInodesUsedPercent},
+	} {
+		if t.value != "" {
+			ts = append(ts, t.name+t.op+t.value)
+		}
+	}
+	return ts
+}
+
+// parseDiskAmount parse the amount which is a percent like 10%, or an absolute value parsed by parse.
+func parseDiskAmount(s string, parse func(string) (uint64, error)) (float64, bool, error) {
+	if strings.HasSuffix(strings.TrimSpace(s), "%") {
+		v, err := parsePercent(s)
+		return v, true, err
+	}
+	v, err := parse(s)
+	return float64(v), false, err
+}
+
+// parseCount parse the count like 100000.
+func parseCount(s string) (uint64, error) {
+	v, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid count %q, expected a non-negative integer", s)
+	}
+	return v, nil
+}
@@ -0,0 +1,490 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"preflight/pkg/dns"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultResolvConf is the resolver configuration of glibc and kubelet, see resolv.conf(5).
+const DefaultResolvConf = "/etc/resolv.conf"
+
+// resolvedUpstreamConf is the resolv.conf of systemd-resolved listing the upstream nameservers instead of its stub.
+const resolvedUpstreamConf = "/run/systemd/resolve/resolv.conf"
+
+// DefaultDNSTimeout limits the resolution of each name by each nameserver.
+const DefaultDNSTimeout = 5 * time.Second
+
+// DefaultMaxNdots is the ndots of pods, a higher ndots on host multiplies the queries of names through search domains.
+const DefaultMaxNdots = 5
+
+// limits of glibc resolver, the nameservers and search domains beyond them are ignored.
+const (
+	maxNameservers   = 3
+	maxSearchDomains = 6
+)
+
+// DNSCheck checks the resolver configuration and the resolution of names.
+type DNSCheck struct {
+	// Names are resolved by each nameserver, with the expected answers if any.
+	Names []DNSName `json:"names,omitempty" yaml:"names,omitempty"`
+	// Hostname resolves the hostname of host too.
+	Hostname bool `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	// ResolvConf is the resolver configuration, /etc/resolv.conf by default.
+	ResolvConf string `json:"resolvConf,omitempty" yaml:"resolvConf,omitempty"`
+	// Nameservers like 10.0.0.2 or 10.0.0.2:53 resolve the names instead of the ones of ResolvConf.
+	Nameservers []string `json:"nameservers,omitempty" yaml:"nameservers,omitempty"`
+	// Timeout of each name by each nameserver, like 2s, 5s by default.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// MaxNdots is the maximum ndots of options, 5 by default.
+	MaxNdots int `json:"maxNdots,omitempty" yaml:"maxNdots,omitempty"`
+}
+
+// DNSName is a name to resolve, it passes if all of the answers are resolved.
+type DNSName struct {
+	Name    string   `json:"name" yaml:"name"`
+	Answers []string `json:"answers,omitempty" yaml:"answers,omitempty"`
+}
+
+// newDNSCheck build DNSCheck from the names with optional answers and the options separated by ";",
+// like "kubernetes.io;registry.local=10.0.0.5|10.0.0.6;hostname;nameserver=10.0.0.2;timeout=2s".
+func newDNSCheck(arg string) (Interface, error) {
+	var d DNSCheck
+	for _, r := range strings.Split(arg, ";") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		switch {
+		case r == "hostname":
+			d.Hostname = true
+		case strings.HasPrefix(r, "nameserver="):
+			for _, ns := range strings.Split(strings.TrimPrefix(r, "nameserver="), "|") {
+				d.Nameservers = append(d.Nameservers, strings.TrimSpace(ns))
+			}
+		case strings.HasPrefix(r, "timeout="):
+			d.Timeout = strings.TrimSpace(strings.TrimPrefix(r, "timeout="))
+		case strings.HasPrefix(r, "ndots<="):
+			n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(r, "ndots<=")))
+			if err != nil {
+				return nil, argError(d.Type(), arg, "invalid ndots %q", r)
+			}
+			d.MaxNdots = n
+		default:
+			parts := strings.SplitN(r, "=", 2)
+			name := DNSName{Name: strings.TrimSpace(parts[0])}
+			if len(parts) == 2 {
+				for _, a := range strings.Split(parts[1], "|") {
+					name.Answers = append(name.Answers, strings.TrimSpace(a))
+				}
+			}
+			d.Names = append(d.Names, name)
+		}
+	}
+	if err := d.ValidateArgs(); err != nil {
+		return nil, argError(d.Type(), arg, "%v", err)
+	}
+	return d, nil
+}
+
+func (d DNSCheck) Type() string {
+	return strings.ToLower("DNS")
+}
+
+func (d DNSCheck) PrettyName() string {
+	if rs := d.requirements(); len(rs) > 0 {
+		return fmt.Sprintf("%s:%s", d.Type(), strings.Join(rs, ";"))
+	}
+	return d.Type()
+}
+
+func (DNSCheck) Metadata() Metadata {
+	return Metadata{
+		Description: "Check the resolver configuration and the resolution of names",
+		Level:       FatalLevel,
+		Explain:     "CoreDNS loops if it forwards to a loopback nameserver like the stub of systemd-resolved, and glibc ignores the nameservers and search domains beyond its limits.",
+		Suggestion:  "Fix the nameservers, search domains and options of resolv.conf, or point kubelet --resolv-conf to " + resolvedUpstreamConf,
+	}
+}
+
+func (DNSCheck) Schema() Schema {
+	return Schema{
+		Arg: &Parameter{
+			Name:        "names",
+			Type:        "string",
+			Example:     "kubernetes.io;registry.local=10.0.0.5|10.0.0.6;hostname;timeout=2s",
+			Description: "the names with optional answers separated by |, hostname, and the options nameserver=, timeout= and ndots<= separated by ;",
+		},
+		Parameters: []Parameter{
+			{Name: "names", Type: "[]object", Example: "[{name: kubernetes.io}, {name: registry.local, answers: [10.0.0.5]}]", Description: "the names resolved by each nameserver, and all of the answers are expected"},
+			{Name: "hostname", Type: "bool", Default: "false", Example: "true", Description: "resolve the hostname of host too"},
+			{Name: "resolvConf", Type: "string", Default: DefaultResolvConf, Example: "/run/systemd/resolve/resolv.conf", Description: "the resolver configuration"},
+			{Name: "nameservers", Type: "[]string", Example: "[10.0.0.2]", Description: "the nameservers resolve the names instead of the ones of resolvConf"},
+			{Name: "timeout", Type: "string", Default: DefaultDNSTimeout.String(), Example: "2s", Description: "the timeout of each name by each nameserver"},
+			{Name: "maxNdots", Type: "int", Default: strconv.Itoa(DefaultMaxNdots), Example: "2", Description: "the maximum ndots of options"},
+		},
+	}
+}
+
+func (d DNSCheck) ValidateArgs() error {
+	for _, n := range d.Names {
+		if strings.Trim(n.Name, ".") == "" {
+			return errors.New("empty name")
+		}
+		for _, a := range n.Answers {
+			if net.ParseIP(a) == nil {
+				return errors.Errorf("invalid answer %q of %s, expected IP address", a, n.Name)
+			}
+		}
+	}
+	for _, ns := range d.Nameservers {
+		host := ns
+		if h, _, err := net.SplitHostPort(ns); err == nil {
+			host = h
+		}
+		if net.ParseIP(host) == nil {
+			return errors.Errorf("invalid nameserver %q", ns)
+		}
+	}
+	if d.Timeout != "" {
+		if t, err := time.ParseDuration(d.Timeout); err != nil || t <= 0 {
+			return errors.Errorf("invalid timeout %q", d.Timeout)
+		}
+	}
+	if d.MaxNdots < 0 {
+		return errors.Errorf("invalid maximum ndots %d", d.MaxNdots)
+	}
+	return nil
+}
+
+// requirements return the names and options in the format of spec.
+func (d DNSCheck) requirements() []string {
+	var rs []string
+	for _, n := range d.Names {
+		if len(n.Answers) > 0 {
+			rs = append(rs, n.Name+"="+strings.Join(n.Answers, "|"))
+		} else {
+			rs = append(rs, n.Name)
+		}
+	}
+	if d.Hostname {
+		rs = append(rs, "hostname")
+	}
+	if len(d.Nameservers) > 0 {
+		rs = append(rs, "nameserver="+strings.Join(d.Nameservers, "|"))
+	}
+	if d.Timeout != "" {
+		rs = append(rs, "timeout="+d.Timeout)
+	}
+	if d.MaxNdots > 0 {
+		rs = append(rs, fmt.Sprintf("ndots<=%d", d.MaxNdots))
+	}
+	return rs
+}
+
+func (d DNSCheck) Validate() (bool, error) {
+	return ValidateReport(d.Evaluate(context.Background()))
+}
+
+func (d DNSCheck) Evaluate(ctx context.Context) (Report, error) {
+	if err := d.ValidateArgs(); err != nil {
+		return Report{}, err
+	}
+	resolvConf := d.ResolvConf
+	if resolvConf == "" {
+		resolvConf = DefaultResolvConf
+	}
+	conf, err := parseResolvConf(resolvConf)
+	if err != nil {
+		return Report{}, err
+	}
+	maxNdots := d.MaxNdots
+	if maxNdots == 0 {
+		maxNdots = DefaultMaxNdots
+	}
+
+	details := []Detail{
+		nameserversDetail(conf.nameservers, resolvConf),
+		{Name: "search domains", Status: StatusPass, Observed: strings.Join(conf.search, " "), Expected: fmt.Sprintf("<=%d", maxSearchDomains)},
+		{Name: "ndots", Status: StatusPass, Observed: strconv.Itoa(conf.ndots), Expected: fmt.Sprintf("<=%d", maxNdots)},
+	}
+	if len(conf.search) == 0 {
+		details[1].Observed = "none"
+	}
+	if len(conf.search) > maxSearchDomains {
+		details[1].Status = StatusWarn
+		details[1].Message = fmt.Sprintf("%d search domains of %s exceed %d, the ones beyond are ignored by glibc", len(conf.search), resolvConf, maxSearchDomains)
+	}
+	if conf.ndots > maxNdots {
+		details[2].Status = StatusWarn
+		details[2].Message = fmt.Sprintf("ndots %d of %s exceeds %d, the names with fewer dots are queried through all search domains first", conf.ndots, resolvConf, maxNdots)
+	}
+
+	names := append([]DNSName{}, d.Names...)
+	if d.Hostname {
+		hostname, err := hostHostname()
+		if err != nil {
+			return Report{}, err
+		}
+		names = append(names, DNSName{Name: hostname})
+	}
+	nameservers := d.Nameservers
+	if len(nameservers) == 0 {
+		nameservers = conf.nameservers
+		if len(nameservers) > maxNameservers {
+			nameservers = nameservers[:maxNameservers]
+		}
+	}
+	timeout := DefaultDNSTimeout
+	if d.Timeout != "" {
+		timeout, _ = time.ParseDuration(d.Timeout)
+	}
+	hosts, err := parseHosts()
+	if err != nil {
+		return Report{}, err
+	}
+	for i, n := range names {
+		detail, err := resolveDetail(ctx, n, nameservers, conf, hosts, timeout)
+		if err != nil {
+			return Report{}, err
+		}
+		if d.Hostname && i == len(names)-1 {
+			detail.Name = "hostname " + n.Name
+		}
+		details = append(details, detail)
+	}
+
+	report := ReportDetails(details)
+	report.Observed = fmt.Sprintf("nameserver %s; search %s; ndots %d", details[0].Observed, details[1].Observed, conf.ndots)
+	report.Expected = strings.Join(d.requirements(), ";")
+	return report, nil
+}
+
+func nameserversDetail(nameservers []string, resolvConf string) Detail {
+	d := Detail{Name: "nameservers", Status: StatusPass, Observed: strings.Join(nameservers, " "), Expected: fmt.Sprintf("1-%d non-loopback", maxNameservers)}
+	var messages []string
+	if len(nameservers) == 0 {
+		d.Status, d.Observed = StatusFail, "none"
+		messages = append(messages, fmt.Sprintf("no nameserver in %s", resolvConf))
+	}
+	if len(nameservers) > maxNameservers {
+		d.Status = StatusWarn
+		messages = append(messages, fmt.Sprintf("%d nameservers of %s exceed %d, the ones beyond are ignored by glibc", len(nameservers), resolvConf, maxNameservers))
+	}
+	for _, ns := range nameservers {
+		if ip := net.ParseIP(ns); ip != nil && ip.IsLoopback() {
+			// the pods forwarding to it through CoreDNS loop, unless kubelet --resolv-conf points to the upstream ones.
+			d.Status = StatusWarn
+			messages = append(messages, fmt.Sprintf("nameserver %s of %s is loopback like the stub of systemd-resolved, point kubelet --resolv-conf to %s of the upstream nameservers, or CoreDNS forwarding to it loops",
+				ns, resolvConf, resolvedUpstreamConf))
+		}
+	}
+	d.Message = strings.Join(messages, ", ")
+	return d
+}
+
+// resolveDetail resolve the name by /etc/hosts, or else by each nameserver.
+func resolveDetail(ctx context.Context, name DNSName, nameservers []string, conf resolvConf, hosts map[string][]string, timeout time.Duration) (Detail, error) {
+	d := Detail{Name: name.Name, Status: StatusPass, Expected: "resolvable"}
+	if len(name.Answers) > 0 {
+		d.Expected = strings.Join(name.Answers, "|")
+	}
+	if addrs, ok := hosts[strings.ToLower(strings.TrimSuffix(name.Name, "."))]; ok {
+		d.Observed = strings.Join(addrs, " ") + " by /etc/hosts"
+		if missing := missingAnswers(name.Answers, addrs); len(missing) > 0 {
+			d.Status = StatusFail
+			d.Message = fmt.Sprintf("%s is resolved to %s by /etc/hosts, missing %s", name.Name, strings.Join(addrs, " "), strings.Join(missing, " "))
+		}
+		return d, nil
+	}
+	if len(nameservers) == 0 {
+		d.Status, d.Observed = StatusFail, "no nameserver"
+		d.Message = fmt.Sprintf("%s could not be resolved without nameserver", name.Name)
+		return d, nil
+	}
+
+	var observed, messages []string
+	for _, ns := range nameservers {
+		addrs, err := resolveName(ctx, ns, name.Name, conf, timeout)
+		if ctx.Err() != nil {
+			return Detail{}, ctx.Err()
+		}
+		if err != nil {
+			observed = append(observed, fmt.Sprintf("%s by %s", err.Error(), ns))
+			messages = append(messages, fmt.Sprintf("nameserver %s failed to resolve %s: %v", ns, name.Name, err))
+			continue
+		}
+		observed = append(observed, fmt.Sprintf("%s by %s", strings.Join(addrs, " "), ns))
+		if missing := missingAnswers(name.Answers, addrs); len(missing) > 0 {
+			messages = append(messages, fmt.Sprintf("nameserver %s resolved %s to %s, missing %s", ns, name.Name, strings.Join(addrs, " "), strings.Join(missing, " ")))
+		}
+	}
+	d.Observed = strings.Join(observed, ", ")
+	if len(messages) > 0 {
+		d.Status = StatusFail
+		d.Message = strings.Join(messages, ", ")
+	}
+	return d, nil
+}
+
+// missingAnswers return the expected answers which are not resolved.
+func missingAnswers(expected, addrs []string) []string {
+	var missing []string
+	for _, e := range expected {
+		found := false
+		for _, a := range addrs {
+			found = found || net.ParseIP(a).Equal(net.ParseIP(e))
+		}
+		if !found {
+			missing = append(missing, e)
+		}
+	}
+	return missing
+}
+
+// resolveName query the A and AAAA records of the name through the search domains as glibc.
+func resolveName(ctx context.Context, nameserver, name string, conf resolvConf, timeout time.Duration) ([]string, error) {
+	var candidates []string
+	if strings.HasSuffix(name, ".") {
+		candidates = []string{name}
+	} else {
+		for _, s := range conf.search {
+			candidates = append(candidates, name+"."+strings.TrimSuffix(s, ".")+".")
+		}
+		if strings.Count(name, ".") >= conf.ndots {
+			candidates = append([]string{name + "."}, candidates...)
+		} else {
+			candidates = append(candidates, name+".")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := errors.New(dns.RcodeName(dns.RcodeNameError))
+	for _, c := range candidates {
+		var addrs []string
+		rcode := dns.RcodeSuccess
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			m, queryErr := dns.Query(ctx, nameserver, c, qtype)
+			if queryErr != nil {
+				// the deadline of connection may expire slightly before the context.
+				var netErr net.Error
+				if ctx.Err() == context.DeadlineExceeded || (errors.As(queryErr, &netErr) && netErr.Timeout()) {
+					return nil, errors.Errorf("timed out after %s", timeout)
+				}
+				return nil, queryErr
+			}
+			if m.Rcode != dns.RcodeSuccess {
+				rcode = m.Rcode
+				break
+			}
+			for _, a := range m.Answers {
+				if a.IP != nil {
+					addrs = append(addrs, a.IP.String())
+				}
+			}
+		}
+		if len(addrs) > 0 {
+			return addrs, nil
+		}
+		// glibc tries the next candidate on the errors other than NXDOMAIN, but reports them if all fail.
+		if rcode != dns.RcodeSuccess && rcode != dns.RcodeNameError {
+			err = errors.New(dns.RcodeName(rcode))
+		}
+	}
+	return nil, err
+}
+
+type resolvConf struct {
+	nameservers []string
+	search      []string
+	ndots       int
+}
+
+// parseResolvConf parse the nameservers, the last search or domain, and the ndots of options.
+func parseResolvConf(file string) (resolvConf, error) {
+	conf := resolvConf{ndots: 1}
+	err := scanHostFile(file, func(line string) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || line[0] == '#' || line[0] == ';' {
+			return
+		}
+		switch fields[0] {
+		case "nameserver":
+			conf.nameservers = append(conf.nameservers, fields[1])
+		case "search":
+			conf.search = fields[1:]
+		case "domain":
+			conf.search = fields[1:2]
+		case "options":
+			for _, o := range fields[1:] {
+				if n, err := strconv.Atoi(strings.TrimPrefix(o, "ndots:")); err == nil && strings.HasPrefix(o, "ndots:") {
+					// the ndots is capped to 15 by glibc.
+					if conf.ndots = n; n > 15 {
+						conf.ndots = 15
+					}
+				}
+			}
+		}
+	})
+	if err != nil {
+		return resolvConf{}, errors.Wrapf(err, "failed to read %s", file)
+	}
+	return conf, nil
+}
+
+// parseHosts return the addresses of the lowercase names in /etc/hosts.
+func parseHosts() (map[string][]string, error) {
+	hosts := make(map[string][]string)
+	err := scanHostFile("/etc/hosts", func(line string) {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || net.ParseIP(fields[0]) == nil {
+			return
+		}
+		for _, name := range fields[1:] {
+			name = strings.ToLower(strings.TrimSuffix(name, "."))
+			hosts[name] = append(hosts[name], fields[0])
+		}
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to read /etc/hosts")
+	}
+	return hosts, nil
+}
+
+// hostHostname return the hostname of /etc/hostname, or the one of kernel in /proc/sys/kernel/hostname if it is
+// absent, both are read under HostRoot so that the hostname of host instead of the container is returned.
+func hostHostname() (string, error) {
+	if hostname, err := readHostFile("/etc/hostname"); err == nil && hostname != "" {
+		return hostname, nil
+	}
+	hostname, err := readHostFile("/proc/sys/kernel/hostname")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read the hostname")
+	}
+	return hostname, nil
+}
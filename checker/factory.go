@@ -15,6 +15,10 @@
 package checker
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/pkg/errors"
 )
 
@@ -25,25 +29,81 @@ var fileExistingCheck Interface = &FileExistingCheck{}
 var portInuseCheck Interface = &PortCheck{}
 var osCheck Interface = &OsCheck{}
 var clusterCheck Interface = &ClusterCheck{}
+var diskCheck Interface = &DiskCheck{}
+var kernelModuleCheck Interface = &KernelModuleCheck{}
+var sysctlCheck Interface = &SysctlCheck{}
+var memConfigCheck Interface = &MemConfigCheck{}
+var cgroupCheck Interface = &CgroupCheck{}
+var containerRuntimeCheck Interface = &ContainerRuntimeCheck{}
+var lsmCheck Interface = &LSMCheck{}
+var firewallCheck Interface = &FirewallCheck{}
+var ulimitCheck Interface = &UlimitCheck{}
+var systemdUnitCheck Interface = &SystemdUnitCheck{}
+var binaryCheck Interface = &BinaryCheck{}
+var dnsCheck Interface = &DNSCheck{}
+var httpCheck Interface = &HTTPCheck{}
+var certCheck Interface = &CertCheck{}
 
 var nameToChecksMap = map[string]Interface{
-	memNumCheck.Type():       memNumCheck,
-	cpuNumCheck.Type():       cpuNumCheck,
-	fileExistingCheck.Type(): fileExistingCheck,
-	portInuseCheck.Type():    portInuseCheck,
-	osCheck.Type():           osCheck,
-	clusterCheck.Type():      clusterCheck,
+	memNumCheck.Type():           memNumCheck,
+	cpuNumCheck.Type():           cpuNumCheck,
+	fileExistingCheck.Type():     fileExistingCheck,
+	portInuseCheck.Type():        portInuseCheck,
+	osCheck.Type():               osCheck,
+	clusterCheck.Type():          clusterCheck,
+	diskCheck.Type():             diskCheck,
+	kernelModuleCheck.Type():     kernelModuleCheck,
+	sysctlCheck.Type():           sysctlCheck,
+	memConfigCheck.Type():        memConfigCheck,
+	cgroupCheck.Type():           cgroupCheck,
+	containerRuntimeCheck.Type(): containerRuntimeCheck,
+	lsmCheck.Type():              lsmCheck,
+	firewallCheck.Type():         firewallCheck,
+	ulimitCheck.Type():           ulimitCheck,
+	systemdUnitCheck.Type():      systemdUnitCheck,
+	binaryCheck.Type():           binaryCheck,
+	dnsCheck.Type():              dnsCheck,
+	httpCheck.Type():             httpCheck,
+	certCheck.Type():             certCheck,
+}
+
+// Constructor build a checker from the argument of checker spec, such as "6443" of "port:6443".
+type Constructor func(arg string) (Interface, error)
+
+var constructors = map[string]Constructor{
+	memNumCheck.Type():           newMemCheck,
+	cpuNumCheck.Type():           newNumCPUCheck,
+	fileExistingCheck.Type():     newFileExistingCheck,
+	portInuseCheck.Type():        newPortCheck,
+	osCheck.Type():               newOsCheck,
+	clusterCheck.Type():          newClusterCheck,
+	diskCheck.Type():             newDiskCheck,
+	kernelModuleCheck.Type():     newKernelModuleCheck,
+	sysctlCheck.Type():           newSysctlCheck,
+	memConfigCheck.Type():        newMemConfigCheck,
+	cgroupCheck.Type():           newCgroupCheck,
+	containerRuntimeCheck.Type(): newContainerRuntimeCheck,
+	lsmCheck.Type():              newLSMCheck,
+	firewallCheck.Type():         newFirewallCheck,
+	ulimitCheck.Type():           newUlimitCheck,
+	systemdUnitCheck.Type():      newSystemdUnitCheck,
+	binaryCheck.Type():           newBinaryCheck,
+	dnsCheck.Type():              newDNSCheck,
+	httpCheck.Type():             newHTTPCheck,
+	certCheck.Type():             newCertCheck,
 }
 
 func GetAllCheckers() map[string]Interface {
 	return nameToChecksMap
 }
 
+// GetAllCheckerTypes return the types of all build-in checkers in order.
 func GetAllCheckerTypes() []string {
-	all := make([]string, len(nameToChecksMap))
+	all := make([]string, 0, len(nameToChecksMap))
 	for k := range nameToChecksMap {
 		all = append(all, k)
 	}
+	sort.Strings(all)
 	return all
 }
 
@@ -53,3 +113,30 @@ func GetCheckersByType(checkType string) (Interface, error) {
 	}
 	return nil, errors.Errorf("checker %s not found", checkType)
 }
+
+// NewChecker build checker of checkType with the given argument.
+func NewChecker(checkType, arg string) (Interface, error) {
+	constructor, exists := constructors[strings.ToLower(checkType)]
+	if !exists {
+		return nil, errors.Errorf("checker %s not found", checkType)
+	}
+	return constructor(strings.TrimSpace(arg))
+}
+
+// ParseSpec build checker from checker spec, the format is ${type}:${arg}, like "memory:2048" or "port:6443",
+// the argument could be omitted if the checker does not require it.
+func ParseSpec(spec string) (Interface, error) {
+	checkType, arg := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		checkType, arg = spec[:i], spec[i+1:]
+	}
+	if checkType == "" {
+		return nil, errors.Errorf("invalid checker spec %q, expected ${type}:${arg}", spec)
+	}
+	return NewChecker(checkType, arg)
+}
+
+// argError report the invalid argument of checker.
+func argError(checkType, arg, format string, args ...interface{}) error {
+	return errors.Errorf("invalid argument %q of checker %s: %s", arg, checkType, fmt.Sprintf(format, args...))
+}
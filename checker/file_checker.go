@@ -15,6 +15,7 @@
 package checker
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -23,7 +24,14 @@ import (
 )
 
 type FileExistingCheck struct {
-	Path string
+	Path string `json:"path" yaml:"path"`
+}
+
+func newFileExistingCheck(arg string) (Interface, error) {
+	if arg == "" {
+		return nil, argError(FileExistingCheck{}.Type(), arg, "expected a file path")
+	}
+	return FileExistingCheck{Path: arg}, nil
 }
 
 func (f FileExistingCheck) Type() string {
@@ -43,10 +51,27 @@ func (FileExistingCheck) Metadata() Metadata {
 	}
 }
 
+func (FileExistingCheck) Schema() Schema {
+	path := Parameter{
+		Name:        "path",
+		Type:        "string",
+		Example:     "/etc/kubernetes/admin.conf",
+		Description: "the path of file or directory required to exist",
+	}
+	return Schema{Arg: &path, Parameters: []Parameter{path}}
+}
+
 // Validate if the given file already exists.
 func (f FileExistingCheck) Validate() (bool, error) {
+	return ValidateReport(f.Evaluate(context.Background()))
+}
+
+func (f FileExistingCheck) Evaluate(ctx context.Context) (Report, error) {
 	if _, err := os.Stat(f.Path); err != nil {
-		return false, errors.Errorf("%s doesn't exist", f.Path)
+		if os.IsNotExist(err) {
+			return Failed("not exist", "exist", "%s doesn't exist", f.Path), nil
+		}
+		return Report{}, errors.Wrapf(err, "failed to stat %s", f.Path)
 	}
-	return true, nil
+	return Passed("exist", "exist"), nil
 }
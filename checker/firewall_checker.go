@@ -0,0 +1,338 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"preflight/pkg/firewall"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultFirewallSource is any IPv4 address.
+const DefaultFirewallSource = "0.0.0.0/0"
+
+// FirewallCheck checks whether the inbound connections to the ports are accepted by the INPUT rules of
+// iptables and nftables, the rules managed by firewalld are included.
+type FirewallCheck struct {
+	// Ports like 6443/tcp or 8472/udp, the protocol is tcp if omitted.
+	Ports []string `json:"ports" yaml:"ports"`
+	// Sources the CIDRs of source addresses, a rule matches only if it matches all addresses of the CIDR.
+	Sources []string `json:"sources,omitempty" yaml:"sources,omitempty"`
+	// Interface the inbound interface, empty means any interface other than lo, the rules matching specific
+	// interfaces are reported as possibly matching then.
+	Interface string `json:"interface,omitempty" yaml:"interface,omitempty"`
+	// IptablesSave, Ip6tablesSave and NftRuleset are the files of captured output of iptables-save, ip6tables-save
+	// and nft -j list ruleset. the commands are run only if none of them is specified.
+	IptablesSave  string `json:"iptablesSave,omitempty" yaml:"iptablesSave,omitempty"`
+	Ip6tablesSave string `json:"ip6tablesSave,omitempty" yaml:"ip6tablesSave,omitempty"`
+	NftRuleset    string `json:"nftRuleset,omitempty" yaml:"nftRuleset,omitempty"`
+}
+
+// newFirewallCheck build FirewallCheck from the ports separated by "|" and the options separated by ";",
+// like "6443/tcp|8472/udp;source=10.0.0.0/8;interface=eth0".
+func newFirewallCheck(arg string) (Interface, error) {
+	var f FirewallCheck
+	for i, r := range strings.Split(arg, ";") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		switch {
+		case i == 0:
+			for _, p := range strings.Split(r, "|") {
+				f.Ports = append(f.Ports, strings.TrimSpace(p))
+			}
+		case strings.HasPrefix(r, "source="):
+			for _, s := range strings.Split(strings.TrimPrefix(r, "source="), "|") {
+				f.Sources = append(f.Sources, strings.TrimSpace(s))
+			}
+		case strings.HasPrefix(r, "interface="):
+			f.Interface = strings.TrimSpace(strings.TrimPrefix(r, "interface="))
+		default:
+			return nil, argError(f.Type(), arg, "unknown option %q, expected source= or interface=", r)
+		}
+	}
+	if err := f.ValidateArgs(); err != nil {
+		return nil, argError(f.Type(), arg, "%v", err)
+	}
+	return f, nil
+}
+
+func (f FirewallCheck) Type() string {
+	return strings.ToLower("Firewall")
+}
+
+func (f FirewallCheck) PrettyName() string {
+	return fmt.Sprintf("%s:%s", f.Type(), strings.Join(f.requirements(), ";"))
+}
+
+func (FirewallCheck) Metadata() Metadata {
+	return Metadata{
+		Description: "Check the inbound connections to the ports are accepted by iptables and nftables",
+		Level:       FatalLevel,
+		Explain:     "the port listened may still be unreachable from other hosts if firewalld, iptables or nftables drops the inbound traffic.",
+		Suggestion:  "Open the ports by firewall-cmd --add-port, or insert an ACCEPT rule before the blocking rule",
+	}
+}
+
+func (FirewallCheck) Schema() Schema {
+	return Schema{
+		Arg: &Parameter{
+			Name:        "ports",
+			Type:        "string",
+			Example:     "6443/tcp|8472/udp;source=10.0.0.0/8",
+			Description: "the ports separated by |, and the options source and interface separated by ;",
+		},
+		Parameters: []Parameter{
+			{Name: "ports", Type: "[]string", Example: "[6443/tcp, 8472/udp]", Description: "the ports with protocol, tcp if omitted"},
+			{Name: "sources", Type: "[]string", Default: DefaultFirewallSource, Example: "[10.0.0.0/8]", Description: "the CIDRs of source addresses"},
+			{Name: "interface", Type: "string", Example: "eth0", Description: "the inbound interface, empty means any interface other than lo"},
+			{Name: "iptablesSave", Type: "string", Example: "/tmp/iptables-save.txt", Description: "the file of captured output of iptables-save"},
+			{Name: "ip6tablesSave", Type: "string", Example: "/tmp/ip6tables-save.txt", Description: "the file of captured output of ip6tables-save"},
+			{Name: "nftRuleset", Type: "string", Example: "/tmp/nft-ruleset.json", Description: "the file of captured output of nft -j list ruleset"},
+		},
+	}
+}
+
+func (f FirewallCheck) ValidateArgs() error {
+	if len(f.Ports) == 0 {
+		return errors.New("at least one port is required")
+	}
+	for _, p := range f.Ports {
+		if _, _, err := parseFirewallPort(p); err != nil {
+			return err
+		}
+	}
+	for _, s := range f.Sources {
+		if _, err := firewall.ParseNet(s); err != nil {
+			return errors.Errorf("invalid source %q, expected CIDR like 10.0.0.0/8", s)
+		}
+	}
+	return nil
+}
+
+// parseFirewallPort parse the port like 6443/tcp.
+func parseFirewallPort(s string) (int, string, error) {
+	protocol := "tcp"
+	if i := strings.Index(s, "/"); i >= 0 {
+		s, protocol = s[:i], strings.ToLower(s[i+1:])
+	}
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 || (protocol != "tcp" && protocol != "udp") {
+		return 0, "", errors.Errorf("invalid port %q, expected like 6443/tcp or 8472/udp", s)
+	}
+	return port, protocol, nil
+}
+
+// requirements return the requirements in the format of spec.
+func (f FirewallCheck) requirements() []string {
+	rs := []string{strings.Join(f.Ports, "|")}
+	if len(f.Sources) > 0 {
+		rs = append(rs, "source="+strings.Join(f.Sources, "|"))
+	}
+	if f.Interface != "" {
+		rs = append(rs, "interface="+f.Interface)
+	}
+	return rs
+}
+
+func (f FirewallCheck) Validate() (bool, error) {
+	return ValidateReport(f.Evaluate(context.Background()))
+}
+
+func (f FirewallCheck) Evaluate(ctx context.Context) (Report, error) {
+	if err := f.ValidateArgs(); err != nil {
+		return Report{}, err
+	}
+	sources := f.Sources
+	if len(sources) == 0 {
+		sources = []string{DefaultFirewallSource}
+	}
+	var packets []firewall.Packet
+	ipv6 := false
+	for _, s := range sources {
+		source, _ := firewall.ParseNet(s)
+		for _, p := range f.Ports {
+			port, protocol, _ := parseFirewallPort(p)
+			packets = append(packets, firewall.Packet{Protocol: protocol, Port: port, Source: source, Interface: f.Interface})
+		}
+		ipv6 = ipv6 || source.IP.To4() == nil
+	}
+
+	rulesets, err := f.rulesets(ctx, ipv6)
+	if err != nil {
+		return Report{}, err
+	}
+	if len(rulesets) == 0 {
+		report := ReportDetails([]Detail{{Name: "firewall", Status: StatusSkip, Message: "neither iptables nor nftables is found"}})
+		report.Observed, report.Expected = "no firewall", "accepted"
+		return report, nil
+	}
+
+	var details []Detail
+	accepted := 0
+	for _, p := range packets {
+		name := fmt.Sprintf("%d/%s", p.Port, p.Protocol)
+		if len(f.Sources) > 0 {
+			name += " from " + p.Source.String()
+		}
+		d := Detail{Name: name, Status: StatusPass, Observed: firewallActions[firewall.ActionAccept], Expected: firewallActions[firewall.ActionAccept]}
+		var skipped, possible []string
+		for _, r := range rulesets {
+			if r.ipv6 != nil && *r.ipv6 != p.IPv6() {
+				continue
+			}
+			v := r.ruleset.Evaluate(p)
+			for _, rule := range v.Skipped {
+				skipped = append(skipped, r.name+" "+rule)
+			}
+			for _, rule := range v.Possible {
+				possible = append(possible, r.name+" "+rule)
+			}
+			if !v.Accepted {
+				d.Status, d.Observed = StatusFail, firewallActions[v.Action]
+				if d.Observed == "" {
+					d.Observed = v.Action
+				}
+				d.Message = fmt.Sprintf("%s is %s by %s %s", name, d.Observed, r.name, v.Rule)
+				break
+			}
+		}
+		if d.Status == StatusPass {
+			accepted++
+		}
+		// the skipped and possibly matching rules might drop the packet accepted, or accept the packet dropped.
+		if len(skipped) > 0 || len(possible) > 0 {
+			if d.Status == StatusPass {
+				d.Status = StatusWarn
+				d.Message = fmt.Sprintf("%s is accepted", name)
+			}
+		}
+		if len(skipped) > 0 {
+			d.Message += fmt.Sprintf(", skipped %d rule(s) with unsupported matches: %s", len(skipped), strings.Join(skipped, "; "))
+		}
+		if len(possible) > 0 {
+			d.Message += fmt.Sprintf(", %d rule(s) possibly matching since the interface is not specified: %s", len(possible), strings.Join(possible, "; "))
+		}
+		details = append(details, d)
+	}
+
+	report := ReportDetails(details)
+	report.Observed = fmt.Sprintf("%d of %d accepted", accepted, len(details))
+	report.Expected = "accepted"
+	return report, nil
+}
+
+var firewallActions = map[string]string{
+	firewall.ActionAccept: "accepted",
+	firewall.ActionDrop:   "dropped",
+	firewall.ActionReject: "rejected",
+}
+
+type namedRuleset struct {
+	name string
+	// ipv6 is the family of packets evaluated by ruleset, nil means both families.
+	ipv6    *bool
+	ruleset firewall.Ruleset
+}
+
+// rulesets return the rulesets from the captured files, or the output of commands if no file is specified,
+// the commands not installed are ignored.
+func (f FirewallCheck) rulesets(ctx context.Context, ipv6 bool) ([]namedRuleset, error) {
+	captured := f.IptablesSave != "" || f.Ip6tablesSave != "" || f.NftRuleset != ""
+	var rulesets []namedRuleset
+
+	var nftFamilies []string
+	for _, ipt := range []struct {
+		name string
+		file string
+		ipv6 bool
+	}{
+		{"iptables", f.IptablesSave, false},
+		{"ip6tables", f.Ip6tablesSave, true},
+	} {
+		if ipt.ipv6 && !ipv6 {
+			continue
+		}
+		data, found, err := loadRuleset(ctx, captured, ipt.file, ipt.name+"-save")
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		t, err := firewall.ParseIptablesSave(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if t.NFTables() {
+			nftFamilies = append(nftFamilies, map[bool]string{false: "ip", true: "ip6"}[ipt.ipv6])
+		}
+		family := ipt.ipv6
+		rulesets = append(rulesets, namedRuleset{name: ipt.name, ipv6: &family, ruleset: t})
+	}
+
+	data, found, err := loadRuleset(ctx, captured, f.NftRuleset, "nft", "-j", "list", "ruleset")
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		t, err := firewall.ParseNftables(data)
+		if err != nil {
+			return nil, err
+		}
+		// the filter tables of iptables-nft are evaluated as iptables.
+		for _, family := range nftFamilies {
+			t.IgnoreTable(family, "filter")
+		}
+		rulesets = append(rulesets, namedRuleset{name: "nftables", ruleset: t})
+	}
+	return rulesets, nil
+}
+
+// loadRuleset read the captured file, or run the command if none is captured. found is false if not captured or not installed.
+func loadRuleset(ctx context.Context, captured bool, file string, command string, args ...string) ([]byte, bool, error) {
+	if captured {
+		if file == "" {
+			return nil, false, nil
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to read captured ruleset")
+		}
+		return data, true, nil
+	}
+
+	if _, err := exec.LookPath(command); err != nil {
+		return nil, false, nil
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+		return nil, false, errors.Wrapf(err, "failed to run %s: %s", command, strings.TrimSpace(stderr.String()))
+	}
+	return out, true, nil
+}
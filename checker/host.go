@@ -0,0 +1,75 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HostRoot is the root of host file system read by checkers, like /proc, /sys and /etc under it.
+// it could be set by PREFLIGHT_HOST_ROOT if preflight runs in a container with host root mounted.
+var HostRoot = hostRootFromEnv()
+
+func hostRootFromEnv() string {
+	if root := os.Getenv("PREFLIGHT_HOST_ROOT"); root != "" {
+		return root
+	}
+	return "/"
+}
+
+// hostPath return the path under HostRoot.
+func hostPath(path string) string {
+	return filepath.Join(HostRoot, path)
+}
+
+// readHostFile return the trimmed content of file under HostRoot.
+func readHostFile(path string) (string, error) {
+	data, err := os.ReadFile(hostPath(path))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// fileExists return true if the file under HostRoot exists.
+func fileExists(path string) bool {
+	_, err := os.Stat(hostPath(path))
+	return err == nil
+}
+
+// isHostDir return true if the path under HostRoot is a directory.
+func isHostDir(path string) bool {
+	info, err := os.Stat(hostPath(path))
+	return err == nil && info.IsDir()
+}
+
+// globHostFiles return the paths matching the pattern under HostRoot, the paths are absolute ones of host.
+func globHostFiles(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(hostPath(pattern))
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(matches))
+	for _, m := range matches {
+		rel, err := filepath.Rel(HostRoot, m)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, "/"+filepath.ToSlash(rel))
+	}
+	return paths, nil
+}
@@ -0,0 +1,468 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"preflight/pkg/proxy"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultHTTPTimeout limits the request of each endpoint, including the redirects.
+const DefaultHTTPTimeout = 10 * time.Second
+
+// maxHTTPBody is the maximum size of body matched by the regexp.
+const maxHTTPBody = 4 << 20
+
+// hops of the request reported on failure.
+const (
+	hopDNS          = "DNS"
+	hopTCP          = "TCP"
+	hopTLS          = "TLS handshake"
+	hopProxyConnect = "proxy CONNECT"
+	hopHTTP         = "HTTP"
+	hopHTTPStatus   = "HTTP status"
+	hopHTTPBody     = "HTTP body"
+	hopLatency      = "latency"
+)
+
+// HTTPCheck checks the endpoints like registries, yum mirrors and artifact servers are reachable, the proxy is
+// selected by HTTP_PROXY, HTTPS_PROXY and NO_PROXY as the container runtimes do.
+type HTTPCheck struct {
+	Endpoints []HTTPEndpoint `json:"endpoints" yaml:"endpoints"`
+	// CABundle is the PEM file of CAs trusted besides the system ones.
+	CABundle string `json:"caBundle,omitempty" yaml:"caBundle,omitempty"`
+	// ClientCert and ClientKey are the PEM files of client certificate and its key.
+	ClientCert string `json:"clientCert,omitempty" yaml:"clientCert,omitempty"`
+	ClientKey  string `json:"clientKey,omitempty" yaml:"clientKey,omitempty"`
+	// Timeout of each endpoint like 5s, 10s by default.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// HTTPEndpoint is the URL and the expected response.
+type HTTPEndpoint struct {
+	URL string `json:"url" yaml:"url"`
+	// Method is GET by default.
+	Method string `json:"method,omitempty" yaml:"method,omitempty"`
+	// Status are the allowed status codes after redirects, any 2xx or 3xx by default.
+	Status []int `json:"status,omitempty" yaml:"status,omitempty"`
+	// Body is the regexp matched by the body.
+	Body string `json:"body,omitempty" yaml:"body,omitempty"`
+	// MaxLatency is the maximum duration until the body is read, like 500ms.
+	MaxLatency string `json:"maxLatency,omitempty" yaml:"maxLatency,omitempty"`
+}
+
+// newHTTPCheck build HTTPCheck from the endpoints and options separated by ";", an endpoint is the URL followed
+// by the conditions separated by spaces, like "https://registry.local/v2/ status=200|401;http://mirror.local/repodata/repomd.xml body=repomd latency<=500ms;ca=/etc/pki/ca.pem".
+func newHTTPCheck(arg string) (Interface, error) {
+	var h HTTPCheck
+	for _, r := range strings.Split(arg, ";") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(r, "ca="):
+			h.CABundle = strings.TrimPrefix(r, "ca=")
+		case strings.HasPrefix(r, "cert="):
+			h.ClientCert = strings.TrimPrefix(r, "cert=")
+		case strings.HasPrefix(r, "key="):
+			h.ClientKey = strings.TrimPrefix(r, "key=")
+		case strings.HasPrefix(r, "timeout="):
+			h.Timeout = strings.TrimPrefix(r, "timeout=")
+		default:
+			fields := strings.Fields(r)
+			e := HTTPEndpoint{URL: fields[0]}
+			for _, f := range fields[1:] {
+				switch {
+				case strings.HasPrefix(f, "status="):
+					for _, s := range strings.Split(strings.TrimPrefix(f, "status="), "|") {
+						code, err := strconv.Atoi(s)
+						if err != nil {
+							return nil, argError(h.Type(), arg, "invalid status %q of %s", s, e.URL)
+						}
+						e.Status = append(e.Status, code)
+					}
+				case strings.HasPrefix(f, "body="):
+					e.Body = strings.TrimPrefix(f, "body=")
+				case strings.HasPrefix(f, "latency<="):
+					e.MaxLatency = strings.TrimPrefix(f, "latency<=")
+				case strings.HasPrefix(f, "method="):
+					e.Method = strings.ToUpper(strings.TrimPrefix(f, "method="))
+				default:
+					return nil, argError(h.Type(), arg, "unknown condition %q of %s, expected status=, body=, latency<= or method=", f, e.URL)
+				}
+			}
+			h.Endpoints = append(h.Endpoints, e)
+		}
+	}
+	if err := h.ValidateArgs(); err != nil {
+		return nil, argError(h.Type(), arg, "%v", err)
+	}
+	return h, nil
+}
+
+func (h HTTPCheck) Type() string {
+	return strings.ToLower("HTTP")
+}
+
+func (h HTTPCheck) PrettyName() string {
+	return fmt.Sprintf("%s:%s", h.Type(), strings.Join(h.requirements(), ";"))
+}
+
+func (HTTPCheck) Metadata() Metadata {
+	return Metadata{
+		Description: "Check the HTTP(S) endpoints are reachable through the proxy of environment",
+		Level:       FatalLevel,
+		Explain:     "air-gapped installations pull images and packages from internal registries, mirrors and artifact servers.",
+		Suggestion:  "Fix the failed hop: DNS, routes and firewall for TCP, CA bundle and certificates for TLS, HTTPS_PROXY and NO_PROXY for proxy CONNECT",
+	}
+}
+
+func (HTTPCheck) Schema() Schema {
+	return Schema{
+		Arg: &Parameter{
+			Name:        "endpoints",
+			Type:        "string",
+			Example:     "https://registry.local/v2/ status=200|401;http://mirror.local/repodata/repomd.xml body=repomd latency<=500ms;timeout=5s",
+			Description: "the URLs with conditions status=, body=, latency<= and method= separated by spaces, and the options ca=, cert=, key= and timeout= separated by ;",
+		},
+		Parameters: []Parameter{
+			{Name: "endpoints", Type: "[]object", Example: "[{url: 'https://registry.local/v2/', status: [200, 401]}, {url: 'http://mirror.local/repodata/repomd.xml', body: '<repomd', maxLatency: 500ms}]",
+				Description: "the URLs and the expected status codes, body regexp and maximum latency"},
+			{Name: "caBundle", Type: "string", Example: "/etc/pki/ca-trust/source/anchors/registry-ca.pem", Description: "the PEM file of CAs trusted besides the system ones"},
+			{Name: "clientCert", Type: "string", Example: "/etc/docker/certs.d/registry.local/client.cert", Description: "the PEM file of client certificate"},
+			{Name: "clientKey", Type: "string", Example: "/etc/docker/certs.d/registry.local/client.key", Description: "the PEM file of client key"},
+			{Name: "timeout", Type: "string", Default: DefaultHTTPTimeout.String(), Example: "5s", Description: "the timeout of each endpoint"},
+		},
+	}
+}
+
+func (h HTTPCheck) ValidateArgs() error {
+	if len(h.Endpoints) == 0 {
+		return errors.New("at least one endpoint is required")
+	}
+	for _, e := range h.Endpoints {
+		u, err := url.Parse(e.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.Errorf("invalid URL %q, expected http:// or https://", e.URL)
+		}
+		for _, code := range e.Status {
+			if code < 100 || code > 599 {
+				return errors.Errorf("invalid status %d of %s", code, e.URL)
+			}
+		}
+		if e.Body != "" {
+			if _, err := regexp.Compile(e.Body); err != nil {
+				return errors.Wrapf(err, "invalid body regexp of %s", e.URL)
+			}
+		}
+		if e.MaxLatency != "" {
+			if d, err := time.ParseDuration(e.MaxLatency); err != nil || d <= 0 {
+				return errors.Errorf("invalid maximum latency %q of %s", e.MaxLatency, e.URL)
+			}
+		}
+	}
+	if (h.ClientCert == "") != (h.ClientKey == "") {
+		return errors.New("both client cert and key are required")
+	}
+	if h.Timeout != "" {
+		if d, err := time.ParseDuration(h.Timeout); err != nil || d <= 0 {
+			return errors.Errorf("invalid timeout %q", h.Timeout)
+		}
+	}
+	return nil
+}
+
+// requirements return the endpoints and options in the format of spec.
+func (h HTTPCheck) requirements() []string {
+	var rs []string
+	for _, e := range h.Endpoints {
+		rs = append(rs, strings.TrimSpace(e.URL+" "+e.conditions()))
+	}
+	for _, o := range []struct{ key, value string }{{"ca", h.CABundle}, {"cert", h.ClientCert}, {"key", h.ClientKey}, {"timeout", h.Timeout}} {
+		if o.value != "" {
+			rs = append(rs, o.key+"="+o.value)
+		}
+	}
+	return rs
+}
+
+// conditions return the conditions of endpoint in the format of spec.
+func (e HTTPEndpoint) conditions() string {
+	var cs []string
+	if e.Method != "" && e.Method != http.MethodGet {
+		cs = append(cs, "method="+e.Method)
+	}
+	if len(e.Status) > 0 {
+		codes := make([]string, 0, len(e.Status))
+		for _, code := range e.Status {
+			codes = append(codes, strconv.Itoa(code))
+		}
+		cs = append(cs, "status="+strings.Join(codes, "|"))
+	}
+	if e.Body != "" {
+		cs = append(cs, "body="+e.Body)
+	}
+	if e.MaxLatency != "" {
+		cs = append(cs, "latency<="+e.MaxLatency)
+	}
+	return strings.Join(cs, " ")
+}
+
+func (h HTTPCheck) Validate() (bool, error) {
+	return ValidateReport(h.Evaluate(context.Background()))
+}
+
+func (h HTTPCheck) Evaluate(ctx context.Context) (Report, error) {
+	if err := h.ValidateArgs(); err != nil {
+		return Report{}, err
+	}
+	tlsConfig, err := h.tlsConfig()
+	if err != nil {
+		return Report{}, err
+	}
+	timeout := DefaultHTTPTimeout
+	if h.Timeout != "" {
+		timeout, _ = time.ParseDuration(h.Timeout)
+	}
+	proxyFunc := proxy.FromEnvironment(os.Getenv).ProxyFunc()
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:             func(req *http.Request) (*url.URL, error) { return proxyFunc(req.URL) },
+		DialContext:       (&net.Dialer{Timeout: timeout}).DialContext,
+		TLSClientConfig:   tlsConfig,
+		ForceAttemptHTTP2: true,
+		// every request dials again, so that the failed hop is traced.
+		DisableKeepAlives: true,
+	}}
+
+	var details []Detail
+	var observed []string
+	for _, e := range h.Endpoints {
+		d, err := e.request(ctx, client, proxyFunc, timeout)
+		if err != nil {
+			return Report{}, err
+		}
+		details = append(details, d)
+		observed = append(observed, d.Observed)
+	}
+
+	report := ReportDetails(details)
+	report.Observed = strings.Join(observed, "; ")
+	report.Expected = strings.Join(h.requirements(), ";")
+	return report, nil
+}
+
+// tlsConfig return the TLS configuration with the CA bundle and client certificate, or nil for the defaults.
+func (h HTTPCheck) tlsConfig() (*tls.Config, error) {
+	if h.CABundle == "" && h.ClientCert == "" {
+		return nil, nil
+	}
+	config := &tls.Config{}
+	if h.CABundle != "" {
+		pem, err := os.ReadFile(hostPath(h.CABundle))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read CA bundle")
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificate found in CA bundle %s", h.CABundle)
+		}
+		config.RootCAs = pool
+	}
+	if h.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(hostPath(h.ClientCert), hostPath(h.ClientKey))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client certificate")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// httpHops records the hops of request by the trace.
+type httpHops struct {
+	mu         sync.Mutex
+	dnsStarted bool
+	dnsErr     error
+	connected  bool
+	connectErr error
+	tlsStarted bool
+	tlsDone    bool
+	tlsErr     error
+}
+
+func (hops *httpHops) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			hops.mu.Lock()
+			defer hops.mu.Unlock()
+			hops.dnsStarted = true
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			hops.mu.Lock()
+			defer hops.mu.Unlock()
+			hops.dnsErr = info.Err
+		},
+		// ConnectDone is called for each address tried, the connection is established if any of them succeeds.
+		ConnectDone: func(network, addr string, err error) {
+			hops.mu.Lock()
+			defer hops.mu.Unlock()
+			if err == nil {
+				hops.connected = true
+			} else {
+				hops.connectErr = err
+			}
+		},
+		TLSHandshakeStart: func() {
+			hops.mu.Lock()
+			defer hops.mu.Unlock()
+			hops.tlsStarted = true
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			hops.mu.Lock()
+			defer hops.mu.Unlock()
+			hops.tlsDone, hops.tlsErr = true, err
+		},
+	}
+}
+
+// failedHop return the hop where the request without response failed.
+func (hops *httpHops) failedHop(viaProxy bool, https bool) string {
+	hops.mu.Lock()
+	defer hops.mu.Unlock()
+	switch {
+	case hops.dnsStarted && !hops.connected && (hops.dnsErr != nil || hops.connectErr == nil):
+		return hopDNS
+	case !hops.connected:
+		return hopTCP
+	case viaProxy && https && !hops.tlsStarted:
+		return hopProxyConnect
+	case hops.tlsStarted && (!hops.tlsDone || hops.tlsErr != nil):
+		return hopTLS
+	}
+	return hopHTTP
+}
+
+// request send the request to the endpoint and return the detail with the failed hop.
+func (e HTTPEndpoint) request(ctx context.Context, client *http.Client, proxyFunc func(*url.URL) (*url.URL, error), timeout time.Duration) (Detail, error) {
+	d := Detail{Name: e.URL, Status: StatusPass, Expected: e.conditions()}
+	if d.Expected == "" {
+		d.Expected = "status=2xx|3xx"
+	}
+	method := e.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	target, _ := url.Parse(e.URL)
+	via := ""
+	proxyURL, err := proxyFunc(target)
+	if err != nil {
+		return Detail{}, errors.Wrap(err, "failed to select proxy")
+	}
+	if proxyURL != nil {
+		via = " via proxy " + proxyURL.Redacted()
+	}
+
+	hops := &httpHops{}
+	reqCtx, cancel := context.WithTimeout(httptrace.WithClientTrace(ctx, hops.trace()), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, method, e.URL, nil)
+	if err != nil {
+		return Detail{}, err
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	var body []byte
+	if err == nil {
+		body, err = io.ReadAll(io.LimitReader(resp.Body, maxHTTPBody))
+		resp.Body.Close()
+	}
+	latency := time.Since(start).Round(time.Millisecond)
+	if ctx.Err() != nil {
+		return Detail{}, ctx.Err()
+	}
+
+	fail := func(hop, reason string) Detail {
+		d.Status = StatusFail
+		d.Message = fmt.Sprintf("%s %s%s failed at %s: %s", method, e.URL, via, hop, reason)
+		return d
+	}
+	if err != nil {
+		if resp == nil {
+			reason := err.Error()
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				reason = urlErr.Err.Error()
+			}
+			var netErr net.Error
+			if reqCtx.Err() == context.DeadlineExceeded || (errors.As(err, &netErr) && netErr.Timeout()) {
+				reason = fmt.Sprintf("timed out after %s", timeout)
+			}
+			hop := hops.failedHop(proxyURL != nil, target.Scheme == "https")
+			d.Observed = "failed at " + hop + via
+			return fail(hop, reason), nil
+		}
+		d.Observed = fmt.Sprintf("%d in %s%s", resp.StatusCode, latency, via)
+		return fail(hopHTTPBody, fmt.Sprintf("failed to read body: %v", err)), nil
+	}
+
+	d.Observed = fmt.Sprintf("%d in %s%s", resp.StatusCode, latency, via)
+	if !e.allowedStatus(resp.StatusCode) {
+		return fail(hopHTTPStatus, resp.Status), nil
+	}
+	if e.Body != "" && !regexp.MustCompile(e.Body).Match(body) {
+		return fail(hopHTTPBody, fmt.Sprintf("body does not match %q", e.Body)), nil
+	}
+	if e.MaxLatency != "" {
+		if max, _ := time.ParseDuration(e.MaxLatency); latency > max {
+			return fail(hopLatency, fmt.Sprintf("responded in %s, exceeding %s", latency, e.MaxLatency)), nil
+		}
+	}
+	return d, nil
+}
+
+// allowedStatus return true if the status code is one of the allowed ones, or any 2xx and 3xx by default.
+func (e HTTPEndpoint) allowedStatus(code int) bool {
+	if len(e.Status) == 0 {
+		return code >= 200 && code < 400
+	}
+	for _, s := range e.Status {
+		if s == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// states of kernel module.
+const (
+	ModuleLoaded   = "loaded"
+	ModuleBuiltin  = "builtin"
+	ModuleLoadable = "loadable"
+	ModuleMissing  = "missing"
+)
+
+// KernelModuleCheck checks the kernel modules required are loaded or built in the running kernel.
+type KernelModuleCheck struct {
+	// Modules required, glob like ip_vs* matches all the modules known.
+	Modules []string `json:"modules" yaml:"modules"`
+	// RequireLoaded regards the loadable module not loaded yet as failed, otherwise it is a warning.
+	RequireLoaded bool `json:"requireLoaded,omitempty" yaml:"requireLoaded,omitempty"`
+}
+
+// newKernelModuleCheck build KernelModuleCheck from modules separated by "|", like "br_netfilter|overlay|ip_vs*".
+func newKernelModuleCheck(arg string) (Interface, error) {
+	var modules []string
+	for _, m := range strings.Split(arg, "|") {
+		if m = strings.TrimSpace(m); m != "" {
+			modules = append(modules, m)
+		}
+	}
+	k := KernelModuleCheck{Modules: modules}
+	if err := k.ValidateArgs(); err != nil {
+		return nil, argError(k.Type(), arg, "%v, expected modules separated by |, like br_netfilter|overlay", err)
+	}
+	return k, nil
+}
+
+func (k KernelModuleCheck) Type() string {
+	return strings.ToLower("KernelModule")
+}
+
+func (k KernelModuleCheck) PrettyName() string {
+	return fmt.Sprintf("%s:%s", k.Type(), strings.Join(k.Modules, "|"))
+}
+
+func (KernelModuleCheck) Metadata() Metadata {
+	return Metadata{
+		Description: "Check the kernel modules required are loaded or built in",
+		Level:       FatalLevel,
+		Explain:     "kubernetes networking and container storage rely on kernel modules like br_netfilter and overlay, kubeadm fails if they are missing.",
+		Suggestion:  "Load the module by modprobe, and add it to /etc/modules-load.d/ to load it on boot, or install the kernel modules package of the running kernel",
+	}
+}
+
+func (KernelModuleCheck) Schema() Schema {
+	return Schema{
+		Arg: &Parameter{
+			Name:        "modules",
+			Type:        "string",
+			Example:     "br_netfilter|overlay|ip_vs*|nf_conntrack",
+			Description: "the modules required separated by |, glob is supported",
+		},
+		Parameters: []Parameter{
+			{Name: "modules", Type: "[]string", Example: "[br_netfilter, overlay, ip_vs*]", Description: "the modules required, glob is supported"},
+			{Name: "requireLoaded", Type: "bool", Default: "false", Example: "true", Description: "regard the loadable module not loaded yet as failed"},
+		},
+	}
+}
+
+func (k KernelModuleCheck) ValidateArgs() error {
+	if len(k.Modules) == 0 {
+		return errors.New("at least one module is required")
+	}
+	for _, m := range k.Modules {
+		if _, err := path.Match(m, ""); err != nil {
+			return errors.Errorf("invalid module pattern %q", m)
+		}
+	}
+	return nil
+}
+
+func (k KernelModuleCheck) Validate() (bool, error) {
+	return ValidateReport(k.Evaluate(context.Background()))
+}
+
+func (k KernelModuleCheck) Evaluate(ctx context.Context) (Report, error) {
+	release, err := readHostFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return Report{}, errors.Wrap(err, "failed to get kernel release")
+	}
+	states, err := moduleStates(release)
+	if err != nil {
+		return Report{}, err
+	}
+
+	var details []Detail
+	for _, pattern := range k.Modules {
+		pattern = normalizeModule(pattern)
+		matched := matchModules(states, pattern)
+		if len(matched) == 0 {
+			details = append(details, k.detail(pattern, ModuleMissing, release))
+			continue
+		}
+		for _, m := range matched {
+			details = append(details, k.detail(m, states[m], release))
+		}
+	}
+
+	report := ReportDetails(details)
+	report.Observed = "kernel " + release
+	report.Expected = strings.Join(k.Modules, "|")
+	return report, nil
+}
+
+func (k KernelModuleCheck) detail(module, state, release string) Detail {
+	d := Detail{Name: module, Status: StatusPass, Observed: state, Expected: ModuleLoaded}
+	switch state {
+	case ModuleLoadable:
+		d.Status = StatusWarn
+		if k.RequireLoaded {
+			d.Status = StatusFail
+		}
+		d.Message = fmt.Sprintf("module %s is not loaded, run modprobe %s to load it", module, module)
+	case ModuleMissing:
+		d.Status = StatusFail
+		d.Message = fmt.Sprintf("module %s is neither loaded nor available for kernel %s", module, release)
+	}
+	return d
+}
+
+// moduleStates return the state of all modules known by the running kernel, the loaded state wins.
+func moduleStates(release string) (map[string]string, error) {
+	states := make(map[string]string)
+
+	dir := path.Join("/lib/modules", release)
+	// the modules of running kernel may not be installed, like in container, only the loaded ones are known.
+	for _, f := range []struct {
+		name  string
+		state string
+	}{
+		{"modules.dep", ModuleLoadable},
+		{"modules.builtin", ModuleBuiltin},
+	} {
+		err := scanHostFile(path.Join(dir, f.name), func(line string) {
+			// kernel/net/bridge/br_netfilter.ko.xz: kernel/net/llc/llc.ko.xz
+			file := strings.SplitN(line, ":", 2)[0]
+			if name := moduleName(file); name != "" {
+				states[name] = f.state
+			}
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "failed to read %s", f.name)
+		}
+	}
+
+	// br_netfilter 32768 0 - Live 0x0000000000000000
+	err := scanHostFile("/proc/modules", func(line string) {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			states[normalizeModule(fields[0])] = ModuleLoaded
+		}
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read loaded modules")
+	}
+	return states, nil
+}
+
+// moduleName return the module name of the module file, like br_netfilter of kernel/net/bridge/br_netfilter.ko.xz.
+func moduleName(file string) string {
+	name := path.Base(strings.TrimSpace(file))
+	i := strings.Index(name, ".ko")
+	if i <= 0 {
+		return ""
+	}
+	return normalizeModule(name[:i])
+}
+
+// normalizeModule the "-" and "_" in module name are equivalent.
+func normalizeModule(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+func matchModules(states map[string]string, pattern string) []string {
+	var matched []string
+	for m := range states {
+		if ok, _ := path.Match(pattern, m); ok {
+			matched = append(matched, m)
+		}
+	}
+	sort.Strings(matched)
+	return matched
+}
+
+// scanHostFile call fn with each non-empty line of file under HostRoot.
+func scanHostFile(file string, fn func(line string)) error {
+	f, err := os.Open(hostPath(file))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			fn(line)
+		}
+	}
+	return scanner.Err()
+}
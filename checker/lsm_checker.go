@@ -0,0 +1,325 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// modes of SELinux and AppArmor.
+const (
+	SELinuxEnforcing  = "enforcing"
+	SELinuxPermissive = "permissive"
+	SELinuxDisabled   = "disabled"
+	AppArmorEnabled   = "enabled"
+	AppArmorDisabled  = "disabled"
+)
+
+// SELinuxConfig is the persisted config of SELinux.
+const SELinuxConfig = "/etc/selinux/config"
+
+// LSMCheck checks the modes of SELinux and AppArmor, both the runtime modes and the ones after reboot.
+type LSMCheck struct {
+	// SELinux the allowed modes of SELinux separated by |, like permissive|disabled.
+	SELinux string `json:"selinux,omitempty" yaml:"selinux,omitempty"`
+	// SELinuxPolicy the required policy of SELinux if it is not disabled, like targeted.
+	SELinuxPolicy string `json:"selinuxPolicy,omitempty" yaml:"selinuxPolicy,omitempty"`
+	// AppArmor the allowed modes of AppArmor separated by |, enabled or disabled.
+	AppArmor string `json:"apparmor,omitempty" yaml:"apparmor,omitempty"`
+}
+
+// newLSMCheck build LSMCheck from the requirements separated by ";",
+// like "selinux=permissive|disabled;apparmor=disabled", empty arg reports the modes only.
+func newLSMCheck(arg string) (Interface, error) {
+	var l LSMCheck
+	for _, r := range strings.Split(arg, ";") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		kv := strings.SplitN(r, "=", 2)
+		if len(kv) != 2 {
+			return nil, argError(l.Type(), arg, "invalid requirement %q, expected like selinux=permissive", r)
+		}
+		value := strings.TrimSpace(kv[1])
+		switch strings.TrimSpace(kv[0]) {
+		case "selinux":
+			l.SELinux = value
+		case "selinux-policy":
+			l.SELinuxPolicy = value
+		case "apparmor":
+			l.AppArmor = value
+		default:
+			return nil, argError(l.Type(), arg, "unknown requirement %q, expected one of selinux=,selinux-policy=,apparmor=", r)
+		}
+	}
+	if err := l.ValidateArgs(); err != nil {
+		return nil, argError(l.Type(), arg, "%v", err)
+	}
+	return l, nil
+}
+
+func (l LSMCheck) Type() string {
+	return strings.ToLower("LSM")
+}
+
+func (l LSMCheck) PrettyName() string {
+	if rs := l.requirements(); len(rs) > 0 {
+		return fmt.Sprintf("%s:%s", l.Type(), strings.Join(rs, ";"))
+	}
+	return l.Type()
+}
+
+func (LSMCheck) Metadata() Metadata {
+	return Metadata{
+		Description: "Check the runtime and persisted modes of SELinux and AppArmor",
+		Level:       FatalLevel,
+		Explain:     "containers may fail to access volumes or start if SELinux or AppArmor is enforcing without the required policy, and the mode set at runtime is lost after reboot if not persisted.",
+		Suggestion:  "Set SELinux mode by setenforce and SELINUX of /etc/selinux/config, disabling or enabling SELinux and AppArmor requires reboot",
+	}
+}
+
+func (LSMCheck) Schema() Schema {
+	return Schema{
+		Arg: &Parameter{
+			Name:        "requirements",
+			Type:        "string",
+			Example:     "selinux=permissive|disabled;apparmor=disabled",
+			Description: "the requirements separated by ;, keys are selinux,selinux-policy and apparmor, empty reports the modes only",
+		},
+		Parameters: []Parameter{
+			{Name: "selinux", Type: "string", Example: "enforcing|permissive", Description: "the allowed modes of SELinux separated by |, enforcing, permissive or disabled"},
+			{Name: "selinuxPolicy", Type: "string", Example: "targeted", Description: "the required policy of SELinux if it is not disabled"},
+			{Name: "apparmor", Type: "string", Example: AppArmorEnabled, Description: "the allowed modes of AppArmor separated by |, enabled or disabled"},
+		},
+	}
+}
+
+func (l LSMCheck) ValidateArgs() error {
+	if err := validateModes("selinux", l.SELinux, SELinuxEnforcing, SELinuxPermissive, SELinuxDisabled); err != nil {
+		return err
+	}
+	return validateModes("apparmor", l.AppArmor, AppArmorEnabled, AppArmorDisabled)
+}
+
+// validateModes return error if any of modes separated by | is not one of the valid ones.
+func validateModes(name, modes string, valid ...string) error {
+	if modes == "" {
+		return nil
+	}
+	for _, m := range strings.Split(modes, "|") {
+		found := false
+		for _, v := range valid {
+			found = found || strings.TrimSpace(m) == v
+		}
+		if !found {
+			return errors.Errorf("invalid %s mode %q, expected %s separated by |", name, m, strings.Join(valid, ","))
+		}
+	}
+	return nil
+}
+
+// requirements return the requirements in the format of spec.
+func (l LSMCheck) requirements() []string {
+	var rs []string
+	for _, r := range []struct{ key, value string }{
+		{"selinux", l.SELinux},
+		{"selinux-policy", l.SELinuxPolicy},
+		{"apparmor", l.AppArmor},
+	} {
+		if r.value != "" {
+			rs = append(rs, r.key+"="+r.value)
+		}
+	}
+	return rs
+}
+
+func (l LSMCheck) Validate() (bool, error) {
+	return ValidateReport(l.Evaluate(context.Background()))
+}
+
+func (l LSMCheck) Evaluate(ctx context.Context) (Report, error) {
+	if err := l.ValidateArgs(); err != nil {
+		return Report{}, err
+	}
+
+	cmdline, err := readHostFile("/proc/cmdline")
+	if err != nil && !os.IsNotExist(err) {
+		return Report{}, errors.Wrap(err, "failed to read kernel cmdline")
+	}
+	params := kernelParams(cmdline)
+
+	selinux, err := l.selinuxDetails(params)
+	if err != nil {
+		return Report{}, err
+	}
+	apparmor, err := l.apparmorDetails(params)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := ReportDetails(append(selinux, apparmor...))
+	report.Observed = fmt.Sprintf("selinux %s, apparmor %s", selinux[0].Observed, apparmor[0].Observed)
+	report.Expected = strings.Join(l.requirements(), ";")
+	return report, nil
+}
+
+// kernelParams return the parameters of kernel cmdline, the last one wins if a parameter is repeated.
+func kernelParams(cmdline string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Fields(cmdline) {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) == 2 {
+			params[kv[0]] = kv[1]
+		} else {
+			params[kv[0]] = ""
+		}
+	}
+	return params
+}
+
+func (l LSMCheck) selinuxDetails(params map[string]string) ([]Detail, error) {
+	runtime := SELinuxDisabled
+	enforce, err := readHostFile("/sys/fs/selinux/enforce")
+	switch {
+	case err == nil && enforce == "1":
+		runtime = SELinuxEnforcing
+	case err == nil:
+		runtime = SELinuxPermissive
+	case !os.IsNotExist(err):
+		return nil, errors.Wrap(err, "failed to read SELinux mode")
+	}
+
+	config := make(map[string]string)
+	err = scanHostFile(SELinuxConfig, func(line string) {
+		// SELINUX=enforcing
+		if kv := strings.SplitN(line, "=", 2); len(kv) == 2 && !strings.HasPrefix(line, "#") {
+			config[strings.TrimSpace(kv[0])] = strings.Trim(strings.TrimSpace(kv[1]), `"'`)
+		}
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to read SELinux config")
+	}
+
+	// the kernel cmdline overrides the config at boot.
+	persisted, source := SELinuxDisabled, SELinuxConfig+" not found"
+	if mode, ok := config["SELINUX"]; ok {
+		persisted, source = strings.ToLower(mode), fmt.Sprintf("SELINUX=%s of %s", mode, SELinuxConfig)
+	}
+	switch {
+	case params["selinux"] == "0":
+		persisted, source = SELinuxDisabled, "selinux=0 of kernel cmdline"
+	case params["enforcing"] == "0" && persisted == SELinuxEnforcing:
+		persisted, source = SELinuxPermissive, "enforcing=0 of kernel cmdline"
+	}
+
+	details := []Detail{lsmModeDetail("selinux", runtime, l.SELinux)}
+	d := lsmModeDetail("selinux after reboot", persisted, l.SELinux)
+	if persisted != runtime {
+		if d.Status == StatusPass {
+			d.Status = StatusWarn
+		}
+		d.Message = fmt.Sprintf("SELinux is %s now, but will be %s after reboot by %s", runtime, persisted, source)
+		if runtime != SELinuxDisabled && persisted != SELinuxDisabled {
+			d.Message += fmt.Sprintf(", run setenforce %s or update %s to make them agree", setenforceArg(persisted), SELinuxConfig)
+		} else {
+			d.Message += ", enabling or disabling SELinux only takes effect after reboot"
+		}
+	}
+	details = append(details, d)
+
+	if l.SELinuxPolicy != "" {
+		policy := Detail{Name: "selinux policy", Status: StatusPass, Observed: config["SELINUXTYPE"], Expected: l.SELinuxPolicy}
+		switch {
+		case runtime == SELinuxDisabled && persisted == SELinuxDisabled:
+			policy.Status = StatusSkip
+			policy.Message = "SELinux is disabled"
+		case policy.Observed != l.SELinuxPolicy:
+			policy.Status = StatusFail
+			policy.Message = fmt.Sprintf("SELinux policy is %q by SELINUXTYPE of %s, but required %s", policy.Observed, SELinuxConfig, l.SELinuxPolicy)
+		}
+		details = append(details, policy)
+	}
+	return details, nil
+}
+
+// setenforceArg return the argument of setenforce to set the SELinux mode.
+func setenforceArg(mode string) string {
+	if mode == SELinuxEnforcing {
+		return "1"
+	}
+	return "0"
+}
+
+func (l LSMCheck) apparmorDetails(params map[string]string) ([]Detail, error) {
+	runtime := AppArmorDisabled
+	if _, err := os.Stat(hostPath("/sys/kernel/security/apparmor")); err == nil {
+		runtime = AppArmorEnabled
+	} else if enabled, err := readHostFile("/sys/module/apparmor/parameters/enabled"); err == nil && enabled == "Y" {
+		// securityfs may not be mounted, such as in a container.
+		runtime = AppArmorEnabled
+	}
+
+	details := []Detail{lsmModeDetail("apparmor", runtime, l.AppArmor)}
+	if runtime == AppArmorEnabled {
+		if d, ok := apparmorProfilesDetail(); ok {
+			details = append(details, d)
+		}
+		if params["apparmor"] == "0" {
+			d := lsmModeDetail("apparmor after reboot", AppArmorDisabled, l.AppArmor)
+			if d.Status == StatusPass {
+				d.Status = StatusWarn
+			}
+			d.Message = "AppArmor is enabled now, but will be disabled after reboot by apparmor=0 of kernel cmdline"
+			details = append(details, d)
+		}
+	}
+	return details, nil
+}
+
+// apparmorProfilesDetail report the number of loaded profiles by mode, it is false if the profiles are not readable.
+func apparmorProfilesDetail() (Detail, bool) {
+	modes := make(map[string]int)
+	total := 0
+	// /usr/sbin/chronyd (enforce)
+	err := scanHostFile("/sys/kernel/security/apparmor/profiles", func(line string) {
+		if i := strings.LastIndex(line, " ("); i > 0 && strings.HasSuffix(line, ")") {
+			modes[line[i+2:len(line)-1]]++
+			total++
+		}
+	})
+	if err != nil {
+		return Detail{}, false
+	}
+	observed := fmt.Sprintf("%d loaded", total)
+	for _, mode := range []string{"enforce", "complain", "kill", "unconfined"} {
+		if modes[mode] > 0 {
+			observed += fmt.Sprintf(", %d %s", modes[mode], mode)
+		}
+	}
+	return Detail{Name: "apparmor profiles", Status: StatusPass, Observed: observed}, true
+}
+
+// lsmModeDetail check the mode is one of the allowed modes, any mode is allowed if it is empty.
+func lsmModeDetail(name, mode, allowed string) Detail {
+	if allowed == "" {
+		return Detail{Name: name, Status: StatusPass, Observed: mode}
+	}
+	return oneOfDetail(name, mode, allowed)
+}
@@ -18,7 +18,9 @@
 package checker
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -26,7 +28,15 @@ import (
 )
 
 type MemCheck struct {
-	Mem uint64
+	Mem uint64 `json:"mem" yaml:"mem"`
+}
+
+func newMemCheck(arg string) (Interface, error) {
+	mem, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil || mem == 0 {
+		return nil, argError(MemCheck{}.Type(), arg, "expected a positive number of megabytes")
+	}
+	return MemCheck{Mem: mem}, nil
 }
 
 func (m MemCheck) Type() string {
@@ -46,17 +56,33 @@ func (MemCheck) Metadata() Metadata {
 	}
 }
 
+func (MemCheck) Schema() Schema {
+	mem := Parameter{
+		Name:        "mem",
+		Type:        "int",
+		Unit:        "MB",
+		Example:     "1700",
+		Description: "the minimum megabytes of total memory",
+	}
+	return Schema{Arg: &mem, Parameters: []Parameter{mem}}
+}
+
 func (m MemCheck) Validate() (bool, error) {
+	return ValidateReport(m.Evaluate(context.Background()))
+}
+
+func (m MemCheck) Evaluate(ctx context.Context) (Report, error) {
 	info := syscall.Sysinfo_t{}
 	err := syscall.Sysinfo(&info)
 	if err != nil {
-		return false, errors.Wrapf(err, "failed to get system info")
+		return Report{}, errors.Wrapf(err, "failed to get system info")
 	}
 
 	// Total holds the total usable memory. Unit holds the size of a memory unit in bytes. Multiply them and convert to MB
 	actual := info.Totalram * uint64(info.Unit) / 1024 / 1024
+	observed, expected := fmt.Sprintf("%d MB", actual), fmt.Sprintf(">=%d MB", m.Mem)
 	if actual < m.Mem {
-		return false, errors.Errorf("the system RAM (%d MB) is less than the minimum %d MB", actual, m.Mem)
+		return Failed(observed, expected, "the system RAM (%d MB) is less than the minimum %d MB", actual, m.Mem), nil
 	}
-	return true, nil
+	return Passed(observed, expected), nil
 }
@@ -0,0 +1,342 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SwapOff requires no swap is active.
+const SwapOff = "off"
+
+// MemConfigCheck checks the swap, overcommit, transparent hugepage and hugepages settings, the empty one is not checked.
+type MemConfigCheck struct {
+	// Swap is "off", or the maximum size of active swap like 1Gi.
+	Swap string `json:"swap,omitempty" yaml:"swap,omitempty"`
+	// OvercommitMemory the allowed values of vm.overcommit_memory separated by |, like 0|1.
+	OvercommitMemory string `json:"overcommitMemory,omitempty" yaml:"overcommitMemory,omitempty"`
+	// TransparentHugepage the allowed modes of transparent hugepage separated by |, like never or never|madvise.
+	TransparentHugepage string `json:"transparentHugepage,omitempty" yaml:"transparentHugepage,omitempty"`
+	// TransparentHugepageDefrag the allowed defrag modes of transparent hugepage separated by |.
+	TransparentHugepageDefrag string `json:"transparentHugepageDefrag,omitempty" yaml:"transparentHugepageDefrag,omitempty"`
+	// Hugepages the minimum number of hugepages of the size, like 2Mi>=1024 or 1Gi>=4.
+	Hugepages []string `json:"hugepages,omitempty" yaml:"hugepages,omitempty"`
+}
+
+var hugepagesRegexp = regexp.MustCompile(`^\s*(\d+[KMG]i)\s*>=\s*(\d+)\s*$`)
+
+// newMemConfigCheck build MemConfigCheck from the requirements separated by ";",
+// like "swap=off;overcommit=1;thp=never;hugepages-2Mi>=1024".
+func newMemConfigCheck(arg string) (Interface, error) {
+	var m MemConfigCheck
+	for _, r := range strings.Split(arg, ";") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		i := strings.IndexAny(r, "<>=")
+		if i <= 0 {
+			return nil, argError(m.Type(), arg, "invalid requirement %q, expected like swap=off", r)
+		}
+		key, rest := strings.TrimSpace(r[:i]), r[i:]
+		switch {
+		case key == "swap" && strings.HasPrefix(rest, "<="):
+			m.Swap = strings.TrimSpace(rest[2:])
+		case key == "swap" && rest == "="+SwapOff:
+			m.Swap = SwapOff
+		case key == "overcommit" && rest[0] == '=':
+			m.OvercommitMemory = strings.TrimSpace(rest[1:])
+		case key == "thp" && rest[0] == '=':
+			m.TransparentHugepage = strings.TrimSpace(rest[1:])
+		case key == "thp-defrag" && rest[0] == '=':
+			m.TransparentHugepageDefrag = strings.TrimSpace(rest[1:])
+		case strings.HasPrefix(key, "hugepages-"):
+			m.Hugepages = append(m.Hugepages, strings.TrimPrefix(key, "hugepages-")+rest)
+		default:
+			return nil, argError(m.Type(), arg, "unknown requirement %q, expected one of swap=off,swap<=1Gi,overcommit=,thp=,thp-defrag=,hugepages-2Mi>=", r)
+		}
+	}
+	if err := m.ValidateArgs(); err != nil {
+		return nil, argError(m.Type(), arg, "%v", err)
+	}
+	return m, nil
+}
+
+func (m MemConfigCheck) Type() string {
+	return strings.ToLower("MemConfig")
+}
+
+func (m MemConfigCheck) PrettyName() string {
+	return fmt.Sprintf("%s:%s", m.Type(), strings.Join(m.requirements(), ";"))
+}
+
+func (MemConfigCheck) Metadata() Metadata {
+	return Metadata{
+		Description: "Check the swap, overcommit, transparent hugepage and hugepages settings",
+		Level:       FatalLevel,
+		Explain:     "kubelet refuses to start with swap on by default, and databases suffer latency spikes with transparent hugepage enabled.",
+		Suggestion:  "Turn off swap by swapoff -a and remove it from /etc/fstab, set vm.overcommit_memory by sysctl, and set transparent hugepage by kernel cmdline or /sys/kernel/mm/transparent_hugepage",
+	}
+}
+
+func (MemConfigCheck) Schema() Schema {
+	return Schema{
+		Arg: &Parameter{
+			Name:        "requirements",
+			Type:        "string",
+			Example:     "swap=off;overcommit=1;thp=never;hugepages-2Mi>=1024",
+			Description: "the requirements separated by ;, keys are swap,overcommit,thp,thp-defrag and hugepages-${size}",
+		},
+		Parameters: []Parameter{
+			{Name: "swap", Type: "string", Example: "off", Description: "off, or the maximum size of active swap like 1Gi"},
+			{Name: "overcommitMemory", Type: "string", Example: "0|1", Description: "the allowed values of vm.overcommit_memory separated by |"},
+			{Name: "transparentHugepage", Type: "string", Example: "never", Description: "the allowed modes of transparent hugepage separated by |"},
+			{Name: "transparentHugepageDefrag", Type: "string", Example: "never|madvise", Description: "the allowed defrag modes of transparent hugepage separated by |"},
+			{Name: "hugepages", Type: "[]string", Unit: "pages", Example: "['2Mi>=1024', '1Gi>=2']", Description: "the minimum number of hugepages of the size"},
+		},
+	}
+}
+
+func (m MemConfigCheck) ValidateArgs() error {
+	if len(m.requirements()) == 0 {
+		return errors.New("at least one requirement is required")
+	}
+	if m.Swap != "" && m.Swap != SwapOff {
+		if _, err := parseSize(m.Swap); err != nil {
+			return errors.Errorf("invalid swap %q, expected off or the maximum size like 1Gi", m.Swap)
+		}
+	}
+	for _, h := range m.Hugepages {
+		if !hugepagesRegexp.MatchString(h) {
+			return errors.Errorf("invalid hugepages %q, expected like 2Mi>=1024", h)
+		}
+	}
+	return nil
+}
+
+// requirements return the requirements in the format of spec.
+func (m MemConfigCheck) requirements() []string {
+	var rs []string
+	switch m.Swap {
+	case "":
+	case SwapOff:
+		rs = append(rs, "swap="+SwapOff)
+	default:
+		rs = append(rs, "swap<="+m.Swap)
+	}
+	for _, r := range []struct{ key, value string }{
+		{"overcommit", m.OvercommitMemory},
+		{"thp", m.TransparentHugepage},
+		{"thp-defrag", m.TransparentHugepageDefrag},
+	} {
+		if r.value != "" {
+			rs = append(rs, r.key+"="+r.value)
+		}
+	}
+	for _, h := range m.Hugepages {
+		rs = append(rs, "hugepages-"+strings.ReplaceAll(h, " ", ""))
+	}
+	return rs
+}
+
+func (m MemConfigCheck) Validate() (bool, error) {
+	return ValidateReport(m.Evaluate(context.Background()))
+}
+
+func (m MemConfigCheck) Evaluate(ctx context.Context) (Report, error) {
+	if err := m.ValidateArgs(); err != nil {
+		return Report{}, err
+	}
+
+	var details []Detail
+	if m.Swap != "" {
+		d, err := m.swapDetail()
+		if err != nil {
+			return Report{}, err
+		}
+		details = append(details, d)
+	}
+	if m.OvercommitMemory != "" {
+		value, err := readHostFile("/proc/sys/vm/overcommit_memory")
+		if err != nil {
+			return Report{}, errors.Wrap(err, "failed to read vm.overcommit_memory")
+		}
+		details = append(details, oneOfDetail("vm.overcommit_memory", value, m.OvercommitMemory))
+	}
+	for _, thp := range []struct{ name, file, allowed string }{
+		{"transparent hugepage", "enabled", m.TransparentHugepage},
+		{"transparent hugepage defrag", "defrag", m.TransparentHugepageDefrag},
+	} {
+		if thp.allowed == "" {
+			continue
+		}
+		content, err := readHostFile("/sys/kernel/mm/transparent_hugepage/" + thp.file)
+		if os.IsNotExist(err) {
+			// kernel built without transparent hugepage never uses it.
+			content = "[never]"
+		} else if err != nil {
+			return Report{}, errors.Wrapf(err, "failed to read %s", thp.name)
+		}
+		details = append(details, oneOfDetail(thp.name, selectedMode(content), thp.allowed))
+	}
+	for _, h := range m.Hugepages {
+		d, err := hugepagesDetail(h)
+		if err != nil {
+			return Report{}, err
+		}
+		details = append(details, d)
+	}
+
+	report := ReportDetails(details)
+	report.Expected = strings.Join(m.requirements(), ";")
+	return report, nil
+}
+
+func (m MemConfigCheck) swapDetail() (Detail, error) {
+	var (
+		total   uint64
+		devices []string
+	)
+	// Filename    Type       Size     Used  Priority
+	// /swap.img   file       2097148  0     -2
+	err := scanHostFile("/proc/swaps", func(line string) {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] == "Filename" {
+			return
+		}
+		size, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			return
+		}
+		total += size * 1024
+		devices = append(devices, fields[0])
+	})
+	if err != nil {
+		return Detail{}, errors.Wrap(err, "failed to read active swaps")
+	}
+
+	d := Detail{Name: "swap", Status: StatusPass, Observed: SwapOff}
+	if len(devices) > 0 {
+		d.Observed = fmt.Sprintf("%s on %s", formatSize(total), strings.Join(devices, ","))
+	}
+	if m.Swap == SwapOff {
+		d.Expected = SwapOff
+		if len(devices) > 0 {
+			d.Status = StatusFail
+			d.Message = fmt.Sprintf("swap is required off, but %s", d.Observed)
+		}
+		return d, nil
+	}
+
+	limit, _ := parseSize(m.Swap)
+	d.Expected = "<=" + m.Swap
+	if total > limit {
+		d.Status = StatusFail
+		d.Message = fmt.Sprintf("swap is %s, more than the limit %s", d.Observed, m.Swap)
+	}
+	return d, nil
+}
+
+// hugepagesDetail check the hugepages of the size like 2Mi>=1024.
+func hugepagesDetail(requirement string) (Detail, error) {
+	match := hugepagesRegexp.FindStringSubmatch(requirement)
+	size, _ := parseSize(match[1])
+	required, _ := strconv.ParseUint(match[2], 10, 64)
+	name := "hugepages-" + match[1]
+
+	pages, err := hugepages(size)
+	if err != nil {
+		return Detail{}, err
+	}
+	d := Detail{Name: name, Status: StatusPass, Observed: strconv.FormatUint(pages, 10), Expected: ">=" + match[2]}
+	if pages < required {
+		d.Status = StatusFail
+		d.Message = fmt.Sprintf("%s pages are %d, less than the required %d", name, pages, required)
+	}
+	return d, nil
+}
+
+// hugepages return the number of hugepages of size in bytes, from /sys/kernel/mm/hugepages,
+// or /proc/meminfo if it is the default size.
+func hugepages(size uint64) (uint64, error) {
+	nr, err := readHostFile(fmt.Sprintf("/sys/kernel/mm/hugepages/hugepages-%dkB/nr_hugepages", size/1024))
+	if err == nil {
+		return strconv.ParseUint(nr, 10, 64)
+	}
+	if !os.IsNotExist(err) {
+		return 0, errors.Wrap(err, "failed to read hugepages")
+	}
+
+	meminfo, err := readMeminfo()
+	if err != nil {
+		return 0, err
+	}
+	if meminfo["Hugepagesize"]*1024 != size {
+		// the size is not supported by the kernel.
+		return 0, nil
+	}
+	return meminfo["HugePages_Total"], nil
+}
+
+// readMeminfo return the values of /proc/meminfo, the ones in kB are not converted.
+func readMeminfo() (map[string]uint64, error) {
+	meminfo := make(map[string]uint64)
+	err := scanHostFile("/proc/meminfo", func(line string) {
+		// Hugepagesize:       2048 kB
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			return
+		}
+		fields := strings.Fields(kv[1])
+		if len(fields) == 0 {
+			return
+		}
+		if v, err := strconv.ParseUint(fields[0], 10, 64); err == nil {
+			meminfo[kv[0]] = v
+		}
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read meminfo")
+	}
+	return meminfo, nil
+}
+
+// selectedMode return the mode in brackets, like never of "always madvise [never]".
+func selectedMode(content string) string {
+	start, end := strings.Index(content, "["), strings.Index(content, "]")
+	if start < 0 || end < start {
+		return content
+	}
+	return content[start+1 : end]
+}
+
+// oneOfDetail check the observed value is one of the allowed values separated by |.
+func oneOfDetail(name, observed, allowed string) Detail {
+	d := Detail{Name: name, Status: StatusPass, Observed: observed, Expected: allowed}
+	for _, a := range strings.Split(allowed, "|") {
+		if strings.TrimSpace(a) == observed {
+			return d
+		}
+	}
+	d.Status = StatusFail
+	d.Message = fmt.Sprintf("%s is %s, but required %s", name, observed, allowed)
+	return d
+}
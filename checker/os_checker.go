@@ -15,6 +15,8 @@
 package checker
 
 import (
+	"context"
+	"fmt"
 	"preflight/pkg/system"
 	"regexp"
 	"strings"
@@ -25,11 +27,36 @@ import (
 // OsCheck machine system information
 type OsCheck struct {
 	// OSType: freebsd, linux
-	OSType string
+	OSType string `json:"osType" yaml:"osType"`
 	// valid os distribution list : ubuntu, centos
-	OSDistribution []string
+	OSDistribution []string `json:"osDistribution" yaml:"osDistribution"`
 	// KernelVersions define supported kernel version. It is a group of regexps.
-	KernelVersions []string
+	KernelVersions []string `json:"kernelVersions" yaml:"kernelVersions"`
+}
+
+// DefaultOSDistributions the os distributions supported by default.
+var DefaultOSDistributions = []string{"ubuntu", "centos"}
+
+// DefaultKernelVersions requires 3.10+, or newer.
+var DefaultKernelVersions = []string{`^3\.[1-9][0-9].*$`, `^([4-9]|[1-9][0-9]+)\.([0-9]+)\.([0-9]+).*$`}
+
+// newOsCheck build linux OsCheck, arg is the supported os distributions separated by "|", like "ubuntu|centos".
+func newOsCheck(arg string) (Interface, error) {
+	distributions := DefaultOSDistributions
+	if arg != "" {
+		distributions = nil
+		for _, d := range strings.Split(arg, "|") {
+			if d = strings.TrimSpace(d); d == "" {
+				return nil, argError(OsCheck{}.Type(), arg, "expected os distributions separated by |, like ubuntu|centos")
+			}
+			distributions = append(distributions, strings.ToLower(d))
+		}
+	}
+	return OsCheck{
+		OSType:         "linux",
+		OSDistribution: distributions,
+		KernelVersions: DefaultKernelVersions,
+	}, nil
 }
 
 func (a OsCheck) Type() string {
@@ -41,21 +68,41 @@ func (a OsCheck) PrettyName() string {
 }
 
 func (a OsCheck) Validate() (bool, error) {
+	return ValidateReport(a.Evaluate(context.Background()))
+}
+
+func (a OsCheck) Evaluate(ctx context.Context) (Report, error) {
 	info, err := system.GetHostInfo()
 	if err != nil {
-		return false, errors.Wrapf(err, "failed to get system info")
+		return Report{}, errors.Wrapf(err, "failed to get system info")
 	}
 
+	osType := Detail{Name: "os type", Status: StatusPass, Observed: info.OS, Expected: a.OSType}
 	if a.OSType != info.OS {
-		return false, errors.Errorf("required os type is %s,but got %s", a.OSType, info.OS)
+		osType.Status = StatusFail
+		osType.Message = fmt.Sprintf("required os type is %s,but got %s", a.OSType, info.OS)
 	}
 
+	distribution := Detail{Name: "os distribution", Status: StatusPass,
+		Observed: info.OSDistribution, Expected: strings.Join(a.OSDistribution, ",")}
 	if !a.validateDistribution(info.OSDistribution) {
-		return false, errors.Errorf("required os distribution list is %s,but got %s",
+		distribution.Status = StatusFail
+		distribution.Message = fmt.Sprintf("required os distribution list is %s,but got %s",
 			a.OSDistribution, info.OSDistribution)
 	}
 
-	return a.validateKernel(info.Kernel)
+	kernel := Detail{Name: "kernel", Status: StatusPass,
+		Observed: info.Kernel, Expected: strings.Join(a.KernelVersions, " or ")}
+	matched, err := a.validateKernel(info.Kernel)
+	if err != nil {
+		return Report{}, err
+	}
+	if !matched {
+		kernel.Status = StatusFail
+		kernel.Message = fmt.Sprintf("required os Kernel 3.10+, or newer,but got %s", info.Kernel)
+	}
+
+	return ReportDetails([]Detail{osType, distribution, kernel}), nil
 }
 
 func (a OsCheck) validateDistribution(distribution string) bool {
@@ -69,13 +116,16 @@ func (a OsCheck) validateDistribution(distribution string) bool {
 
 func (a OsCheck) validateKernel(version string) (bool, error) {
 	for _, versionRegexp := range a.KernelVersions {
-		r := regexp.MustCompile(versionRegexp)
+		r, err := regexp.Compile(versionRegexp)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid kernel version regexp %s", versionRegexp)
+		}
 		if r.MatchString(version) {
 			return true, nil
 		}
 	}
 
-	return false, errors.Errorf("required os Kernel 3.10+, or newer,but got %s", version)
+	return false, nil
 }
 
 func (OsCheck) Metadata() Metadata {
@@ -86,3 +136,35 @@ func (OsCheck) Metadata() Metadata {
 		Suggestion:  "",
 	}
 }
+
+func (OsCheck) Schema() Schema {
+	return Schema{
+		Arg: &Parameter{
+			Name:        "osDistribution",
+			Type:        "string",
+			Default:     strings.Join(DefaultOSDistributions, "|"),
+			Example:     "ubuntu|centos",
+			Description: "the supported os distributions separated by |",
+		},
+		Parameters: []Parameter{
+			{
+				Name:        "osType",
+				Type:        "string",
+				Example:     "linux",
+				Description: "the required os type, like linux",
+			},
+			{
+				Name:        "osDistribution",
+				Type:        "[]string",
+				Example:     "[ubuntu, centos]",
+				Description: "the supported os distributions",
+			},
+			{
+				Name:        "kernelVersions",
+				Type:        "[]string",
+				Example:     `['^5\.']`,
+				Description: "the regexps of supported kernel versions, kernel matches any of them is supported",
+			},
+		},
+	}
+}
@@ -15,7 +15,9 @@
 package checker
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -24,7 +26,15 @@ import (
 )
 
 type PortCheck struct {
-	Port int
+	Port int `json:"port" yaml:"port"`
+}
+
+func newPortCheck(arg string) (Interface, error) {
+	port, err := strconv.Atoi(arg)
+	if err != nil || port < 1 || port > 65535 {
+		return nil, argError(PortCheck{}.Type(), arg, "expected a port number in range 1-65535")
+	}
+	return PortCheck{Port: port}, nil
 }
 
 func (p PortCheck) Type() string {
@@ -44,17 +54,36 @@ func (PortCheck) Metadata() Metadata {
 	}
 }
 
+func (PortCheck) Schema() Schema {
+	port := Parameter{
+		Name:        "port",
+		Type:        "int",
+		Example:     "6443",
+		Description: "the TCP port required to be available, in range 1-65535",
+	}
+	return Schema{Arg: &port, Parameters: []Parameter{port}}
+}
+
+// Exclusive the port is bound while validating, it should not run with other checkers.
+func (PortCheck) Exclusive() bool {
+	return true
+}
+
 // Validate if the port is available.
 func (p PortCheck) Validate() (bool, error) {
+	return ValidateReport(p.Evaluate(context.Background()))
+}
+
+func (p PortCheck) Evaluate(ctx context.Context) (Report, error) {
 	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", p.Port))
 	if err != nil {
-		return false, errors.Errorf("Port %d is in use,%v", p.Port, err)
+		return Failed("in use", "available", "Port %d is in use,%v", p.Port, err), nil
 	}
 	if ln != nil {
 		if err = ln.Close(); err != nil {
-			return false, errors.Errorf("when closing port %d, encountered %v", p.Port, err)
+			return Report{}, errors.Errorf("when closing port %d, encountered %v", p.Port, err)
 		}
 	}
 
-	return true, nil
+	return Passed("available", "available"), nil
 }
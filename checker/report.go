@@ -0,0 +1,170 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Status of checker evaluated.
+type Status string
+
+const (
+	// StatusPass the requirement is met.
+	StatusPass Status = "pass"
+	// StatusFail the requirement is not met.
+	StatusFail Status = "fail"
+	// StatusError the checker itself failed, so it is unknown whether the requirement is met.
+	StatusError Status = "error"
+	// StatusWarn the requirement is met, but something should be noticed.
+	StatusWarn Status = "warn"
+	// StatusSkip the checker is not run.
+	StatusSkip Status = "skip"
+)
+
+// Detail is one item evaluated by checker, like one of the required modules, or one of the hosts.
+type Detail struct {
+	Name     string `json:"name" yaml:"name"`
+	Status   Status `json:"status" yaml:"status"`
+	Host     string `json:"host,omitempty" yaml:"host,omitempty"`
+	Observed string `json:"observed,omitempty" yaml:"observed,omitempty"`
+	Expected string `json:"expected,omitempty" yaml:"expected,omitempty"`
+	Message  string `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+// Report is the result of checker evaluated.
+type Report struct {
+	// Status is one of StatusPass, StatusFail and StatusWarn.
+	Status Status
+	// Message shows why the requirement is not met.
+	Message string
+	// Host where the checker evaluated, empty means local host.
+	Host string
+	// Observed the actual value found by checker.
+	Observed string
+	// Expected the required value.
+	Expected string
+	// Details of each item evaluated.
+	Details []Detail
+}
+
+// Evaluator is implemented by checkers which report the observed and expected values.
+// The returned error means the checker itself failed, the requirement not met is reported by Report.Status.
+type Evaluator interface {
+	Evaluate(ctx context.Context) (Report, error)
+}
+
+// Evaluate the checker with ctx. for the checker not implement Evaluator, the error of Validate
+// is regarded as the requirement not met, since they could not be told apart.
+func Evaluate(ctx context.Context, c Interface) (Report, error) {
+	if e, ok := c.(Evaluator); ok {
+		return evaluateContext(ctx, e)
+	}
+
+	passed, err := ValidateContext(ctx, c)
+	if err != nil {
+		if ctx.Err() != nil {
+			return Report{}, err
+		}
+		return Report{Status: StatusFail, Message: err.Error()}, nil
+	}
+	if !passed {
+		return Report{Status: StatusFail, Message: "validate not passed"}, nil
+	}
+	return Report{Status: StatusPass}, nil
+}
+
+// evaluateContext run the evaluator in a goroutine and return when ctx is done, since most of the
+// evaluators ignore ctx, such as the ones stat a stale mount.
+func evaluateContext(ctx context.Context, e Evaluator) (Report, error) {
+	if err := ctx.Err(); err != nil {
+		return Report{}, err
+	}
+
+	type evaluateResult struct {
+		report Report
+		err    error
+	}
+	ch := make(chan evaluateResult, 1)
+	goBackground(ctx, func() {
+		report, err := e.Evaluate(ctx)
+		ch <- evaluateResult{report: report, err: err}
+	})
+
+	select {
+	case r := <-ch:
+		return r.report, r.err
+	case <-ctx.Done():
+		return Report{}, ctx.Err()
+	}
+}
+
+// ValidateReport convert the result of Evaluate to the result of Validate.
+func ValidateReport(report Report, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	if report.Status == StatusFail {
+		return false, errors.New(report.Message)
+	}
+	return true, nil
+}
+
+// Passed return a passed report.
+func Passed(observed, expected string) Report {
+	return Report{Status: StatusPass, Observed: observed, Expected: expected}
+}
+
+// Failed return a failed report with message.
+func Failed(observed, expected, format string, args ...interface{}) Report {
+	return Report{Status: StatusFail, Observed: observed, Expected: expected, Message: fmt.Sprintf(format, args...)}
+}
+
+// ReportDetails return the report of details, its status is the worst status of details, the detail
+// in error is regarded as failed. the message is joined by the messages of details failed or warned.
+func ReportDetails(details []Detail) Report {
+	report := Report{Status: StatusPass, Details: details}
+	for _, d := range details {
+		if statusSeverity(d.Status) > statusSeverity(report.Status) {
+			report.Status = d.Status
+		}
+		if statusSeverity(d.Status) > 0 && d.Message != "" {
+			if report.Message != "" {
+				report.Message += "; "
+			}
+			report.Message += d.Message
+		}
+	}
+	if report.Status == StatusError {
+		report.Status = StatusFail
+	}
+	return report
+}
+
+func statusSeverity(s Status) int {
+	switch s {
+	case StatusWarn:
+		return 1
+	case StatusFail:
+		return 2
+	case StatusError:
+		return 3
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+// Parameter describes an argument of checker.
+type Parameter struct {
+	// Name of the argument, it is the field of args in plan file.
+	Name string `json:"name" yaml:"name"`
+	// Type of the argument, like int, string, []string.
+	Type string `json:"type" yaml:"type"`
+	// Unit of the argument, like MB, empty if the argument has no unit.
+	Unit string `json:"unit,omitempty" yaml:"unit,omitempty"`
+	// Default value used if the argument is omitted, empty means the argument is required.
+	Default string `json:"default,omitempty" yaml:"default,omitempty"`
+	// Example value of the argument, written in the format of checker spec or YAML.
+	Example string `json:"example,omitempty" yaml:"example,omitempty"`
+	// Description shows what the argument means.
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Schema describes the arguments of checker.
+type Schema struct {
+	// Arg is the argument of checker spec, like "6443" of "port:6443".
+	// it is nil if the checker could only be declared in plan file.
+	Arg *Parameter `json:"arg,omitempty" yaml:"arg,omitempty"`
+	// Parameters are the args of checker in plan file.
+	Parameters []Parameter `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+}
+
+// Parameterized is implemented by checkers which declare the schema of their arguments.
+type Parameterized interface {
+	Schema() Schema
+}
+
+// GetSchema return the schema of checker, it is empty if the checker not declare it.
+func GetSchema(c Interface) Schema {
+	if p, ok := c.(Parameterized); ok {
+		return p.Schema()
+	}
+	return Schema{}
+}
@@ -0,0 +1,351 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// sysctlOperators are ordered so that the longer one is matched first.
+var sysctlOperators = []string{">=", "<=", "!=", ">", "<", "="}
+
+// SysctlDirs are the directories of sysctl.d in the order of priority, the file in the former
+// overrides the one with the same name in the latter, see sysctl.d(5).
+var SysctlDirs = []string{"/etc/sysctl.d", "/run/sysctl.d", "/usr/local/lib/sysctl.d", "/usr/lib/sysctl.d", "/lib/sysctl.d"}
+
+// SysctlConf is applied after the files of sysctl.d by procps `sysctl --system`, while systemd-sysctl only
+// applies it through the link /etc/sysctl.d/99-sysctl.conf shipped by most distributions.
+const SysctlConf = "/etc/sysctl.conf"
+
+// SysctlCheck checks the kernel parameters of /proc/sys, and whether they are persisted by sysctl config files.
+type SysctlCheck struct {
+	// Params required, like net.ipv4.ip_forward=1, vm.max_map_count>=262144,
+	// the range is inclusive like fs.inotify.max_user_watches=524288..1048576.
+	Params []string `json:"params" yaml:"params"`
+	// RequirePersisted regards the param not persisted as failed, otherwise it is a warning.
+	RequirePersisted bool `json:"requirePersisted,omitempty" yaml:"requirePersisted,omitempty"`
+}
+
+// sysctlParam is a parsed param of SysctlCheck.
+type sysctlParam struct {
+	Key   string
+	Op    string
+	Value string
+}
+
+func (p sysctlParam) String() string {
+	return p.Key + p.Op + p.Value
+}
+
+// newSysctlCheck build SysctlCheck from params separated by ";", like "net.ipv4.ip_forward=1;vm.max_map_count>=262144".
+func newSysctlCheck(arg string) (Interface, error) {
+	var params []string
+	for _, p := range strings.Split(arg, ";") {
+		if p = strings.TrimSpace(p); p != "" {
+			params = append(params, p)
+		}
+	}
+	s := SysctlCheck{Params: params}
+	if err := s.ValidateArgs(); err != nil {
+		return nil, argError(s.Type(), arg, "%v", err)
+	}
+	return s, nil
+}
+
+func (s SysctlCheck) Type() string {
+	return strings.ToLower("Sysctl")
+}
+
+func (s SysctlCheck) PrettyName() string {
+	return fmt.Sprintf("%s:%s", s.Type(), strings.Join(s.Params, ";"))
+}
+
+func (SysctlCheck) Metadata() Metadata {
+	return Metadata{
+		Description: "Check the kernel parameters and whether they are persisted",
+		Level:       FatalLevel,
+		Explain:     "kubernetes requires kernel parameters like net.ipv4.ip_forward=1, the ones not persisted are lost after reboot.",
+		Suggestion:  "Set the parameter by sysctl -w, and write it to a file of /etc/sysctl.d/ to persist it",
+	}
+}
+
+func (SysctlCheck) Schema() Schema {
+	return Schema{
+		Arg: &Parameter{
+			Name:        "params",
+			Type:        "string",
+			Example:     "net.ipv4.ip_forward=1;vm.max_map_count>=262144",
+			Description: "the params separated by ;, operators are =,!=,>=,<=,>,< and the range like =1..10",
+		},
+		Parameters: []Parameter{
+			{Name: "params", Type: "[]string", Example: "['net.ipv4.ip_forward=1', 'fs.inotify.max_user_watches=524288..1048576']", Description: "the params required"},
+			{Name: "requirePersisted", Type: "bool", Default: "false", Example: "true", Description: "regard the param not persisted as failed"},
+		},
+	}
+}
+
+func (s SysctlCheck) ValidateArgs() error {
+	if len(s.Params) == 0 {
+		return errors.New("at least one param is required")
+	}
+	for _, p := range s.Params {
+		if _, err := parseSysctlParam(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseSysctlParam parse the param like vm.max_map_count>=262144.
+func parseSysctlParam(s string) (sysctlParam, error) {
+	i := strings.IndexAny(s, "<>=!")
+	if i <= 0 {
+		return sysctlParam{}, errors.Errorf("invalid param %q, expected like net.ipv4.ip_forward=1", s)
+	}
+	p := sysctlParam{Key: normalizeSysctlKey(strings.TrimSpace(s[:i]))}
+	for _, op := range sysctlOperators {
+		if strings.HasPrefix(s[i:], op) {
+			p.Op, p.Value = op, strings.TrimSpace(s[i+len(op):])
+			break
+		}
+	}
+	if p.Op == "" || p.Value == "" {
+		return sysctlParam{}, errors.Errorf("invalid param %q, expected like net.ipv4.ip_forward=1", s)
+	}
+	// check the value could be compared.
+	if _, err := compareValue("0", p.Op, p.Value); err != nil {
+		return sysctlParam{}, errors.Wrapf(err, "invalid param %q", s)
+	}
+	return p, nil
+}
+
+func (s SysctlCheck) Validate() (bool, error) {
+	return ValidateReport(s.Evaluate(context.Background()))
+}
+
+func (s SysctlCheck) Evaluate(ctx context.Context) (Report, error) {
+	if err := s.ValidateArgs(); err != nil {
+		return Report{}, err
+	}
+	persisted, err := persistedSysctls()
+	if err != nil {
+		return Report{}, err
+	}
+
+	var details []Detail
+	for _, str := range s.Params {
+		p, _ := parseSysctlParam(str)
+		d, err := s.evaluateParam(p, persisted)
+		if err != nil {
+			return Report{}, err
+		}
+		details = append(details, d)
+	}
+	report := ReportDetails(details)
+	report.Expected = strings.Join(s.Params, ";")
+	return report, nil
+}
+
+func (s SysctlCheck) evaluateParam(p sysctlParam, persisted map[string]sysctlSetting) (Detail, error) {
+	d := Detail{Name: p.Key, Status: StatusPass, Expected: p.Op + p.Value}
+
+	value, err := readHostFile(path.Join("/proc/sys", sysctlKeyPath(p.Key)))
+	if os.IsNotExist(err) {
+		d.Status = StatusFail
+		d.Observed = "not found"
+		d.Message = fmt.Sprintf("%s not found, the kernel module providing it may not be loaded", p.Key)
+		return d, nil
+	}
+	if err != nil {
+		return Detail{}, errors.Wrapf(err, "failed to read %s", p.Key)
+	}
+	d.Observed = value
+	if ok, err := compareValue(value, p.Op, p.Value); err != nil || !ok {
+		d.Status = StatusFail
+		d.Message = fmt.Sprintf("%s is %s, but required %s%s", p.Key, value, p.Op, p.Value)
+		return d, nil
+	}
+
+	setting, found := persisted[p.Key]
+	ok, _ := compareValue(setting.Value, p.Op, p.Value)
+	switch {
+	case found && ok:
+		d.Message = fmt.Sprintf("%s is persisted in %s", p.Key, setting.File)
+		return d, nil
+	case found:
+		d.Message = fmt.Sprintf("%s is %s now, but it will be %s after reboot as set in %s", p.Key, value, setting.Value, setting.File)
+	default:
+		d.Message = fmt.Sprintf("%s is %s now, but it is not persisted in sysctl config files", p.Key, value)
+	}
+	d.Status = StatusWarn
+	if s.RequirePersisted {
+		d.Status = StatusFail
+	}
+	return d, nil
+}
+
+// sysctlSetting is the value of key set by sysctl config file.
+type sysctlSetting struct {
+	Value string
+	File  string
+}
+
+// persistedSysctls return the settings applied on boot, the later one overrides the former in the order of
+// procps `sysctl --system`, that is the files of SysctlDirs are sorted by their names, and then SysctlConf.
+// SysctlConf linked by the files of SysctlDirs is not applied again, so that the file reported is the link
+// which systemd-sysctl applies, the result is the same in both orders then.
+func persistedSysctls() (map[string]sysctlSetting, error) {
+	files := make(map[string]string)
+	for i := len(SysctlDirs) - 1; i >= 0; i-- {
+		matches, err := filepath.Glob(hostPath(path.Join(SysctlDirs[i], "*.conf")))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			files[filepath.Base(m)] = path.Join(SysctlDirs[i], filepath.Base(m))
+		}
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ordered []string
+	confLinked := false
+	confPath, _ := filepath.EvalSymlinks(hostPath(SysctlConf))
+	for _, name := range names {
+		ordered = append(ordered, files[name])
+		if resolved, err := filepath.EvalSymlinks(hostPath(files[name])); err == nil && confPath != "" && resolved == confPath {
+			confLinked = true
+		}
+	}
+	if !confLinked {
+		ordered = append(ordered, SysctlConf)
+	}
+
+	settings := make(map[string]sysctlSetting)
+	for _, file := range ordered {
+		err := scanHostFile(file, func(line string) {
+			if line[0] == '#' || line[0] == ';' {
+				return
+			}
+			kv := strings.SplitN(line, "=", 2)
+			if len(kv) != 2 {
+				return
+			}
+			// the key prefixed with "-" means the error of setting it is ignored.
+			key := normalizeSysctlKey(strings.TrimPrefix(strings.TrimSpace(kv[0]), "-"))
+			settings[key] = sysctlSetting{Value: strings.TrimSpace(kv[1]), File: file}
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "failed to read %s", file)
+		}
+	}
+	return settings, nil
+}
+
+// normalizeSysctlKey convert the key separated by "/" to the one separated by ".".
+func normalizeSysctlKey(key string) string {
+	if strings.Contains(key, "/") && !strings.Contains(key, ".") {
+		return strings.ReplaceAll(key, "/", ".")
+	}
+	return key
+}
+
+// sysctlKeyPath return the path of key under /proc/sys, "." and "/" are swapped,
+// like net.ipv4.conf.eth0/100.rp_filter to net/ipv4/conf/eth0.100/rp_filter.
+func sysctlKeyPath(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.':
+			return '/'
+		case '/':
+			return '.'
+		}
+		return r
+	}, key)
+}
+
+// compareValue compare the observed value with the required value by op. the value is compared as integer
+// if both are integers, otherwise as string with whitespaces collapsed. the range like 1..10 is inclusive.
+func compareValue(observed, op, required string) (bool, error) {
+	observed = strings.Join(strings.Fields(observed), " ")
+	required = strings.Join(strings.Fields(required), " ")
+
+	if op == "=" && strings.Contains(required, "..") {
+		bounds := strings.SplitN(required, "..", 2)
+		min, ok1 := parseSysctlInt(strings.TrimSpace(bounds[0]))
+		max, ok2 := parseSysctlInt(strings.TrimSpace(bounds[1]))
+		if !ok1 || !ok2 || min.Cmp(max) > 0 {
+			return false, errors.Errorf("invalid range %q, expected like 1..10", required)
+		}
+		v, ok := parseSysctlInt(observed)
+		return ok && v.Cmp(min) >= 0 && v.Cmp(max) <= 0, nil
+	}
+
+	r, ok := parseSysctlInt(required)
+	if !ok {
+		switch op {
+		case "=":
+			return observed == required, nil
+		case "!=":
+			return observed != required, nil
+		default:
+			return false, errors.Errorf("operator %s requires an integer, but got %q", op, required)
+		}
+	}
+	v, ok := parseSysctlInt(observed)
+	if !ok {
+		return false, nil
+	}
+	switch c := v.Cmp(r); op {
+	case "=":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case ">=":
+		return c >= 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case "<":
+		return c < 0, nil
+	}
+	return false, errors.Errorf("unknown operator %s", op)
+}
+
+// parseSysctlInt parse the value as unsigned first, since the params like kernel.shmmax are unsigned 64-bit,
+// and then as signed for the negative ones.
+func parseSysctlInt(s string) (*big.Int, bool) {
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return new(big.Int).SetUint64(u), true
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return big.NewInt(i), true
+	}
+	return nil, false
+}
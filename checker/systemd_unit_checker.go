@@ -0,0 +1,364 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"preflight/pkg/system"
+
+	"github.com/pkg/errors"
+)
+
+// systemdUnitProperties are the properties shown by systemctl for SystemdUnitCheck.
+var systemdUnitProperties = []string{"Id", "Names", "LoadState", "ActiveState", "SubState", "UnitFileState", "ActiveEnterTimestampMonotonic"}
+
+// SystemdUnitCheck checks the states of systemd units, like firewalld inactive, chronyd active and enabled,
+// kubelet not running yet or docker masked.
+type SystemdUnitCheck struct {
+	Rules []SystemdUnitRule `json:"rules" yaml:"rules"`
+	// SystemctlShow is the file of captured output of systemctl show, systemctl is run if it is empty.
+	SystemctlShow string `json:"systemctlShow,omitempty" yaml:"systemctlShow,omitempty"`
+}
+
+// SystemdUnitRule is satisfied if one of the units is in one of the states, a state prefixed with ! is disallowed.
+type SystemdUnitRule struct {
+	// Units like chronyd or ntpd.service, the type is service if omitted.
+	Units []string `json:"units" yaml:"units"`
+	// ActiveState like active, inactive, failed or activating.
+	ActiveState []string `json:"activeState,omitempty" yaml:"activeState,omitempty"`
+	// SubState like running, exited or dead.
+	SubState []string `json:"subState,omitempty" yaml:"subState,omitempty"`
+	// UnitFileState like enabled, disabled, static or masked.
+	UnitFileState []string `json:"unitFileState,omitempty" yaml:"unitFileState,omitempty"`
+	// MinUptime is the minimum duration since the unit entered active state, like 5m.
+	MinUptime string `json:"minUptime,omitempty" yaml:"minUptime,omitempty"`
+}
+
+// systemdUnitConditions are the conditions of rule in spec.
+var systemdUnitConditions = []struct {
+	key      string
+	property string
+	states   func(r *SystemdUnitRule) *[]string
+}{
+	{"active", "ActiveState", func(r *SystemdUnitRule) *[]string { return &r.ActiveState }},
+	{"sub", "SubState", func(r *SystemdUnitRule) *[]string { return &r.SubState }},
+	{"file", "UnitFileState", func(r *SystemdUnitRule) *[]string { return &r.UnitFileState }},
+}
+
+// newSystemdUnitCheck build SystemdUnitCheck from the rules separated by ";", a rule is the units separated by "|"
+// and the conditions separated by space, like "firewalld:active=inactive|failed;chronyd|ntpd:active=active file=enabled uptime>=5m;kubelet:sub=!running".
+// the conditions are not separated by "," which separates the args of --args.
+func newSystemdUnitCheck(arg string) (Interface, error) {
+	var s SystemdUnitCheck
+	for _, r := range strings.Split(arg, ";") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		parts := strings.SplitN(r, ":", 2)
+		if len(parts) != 2 {
+			return nil, argError(s.Type(), arg, "invalid rule %q, expected like chronyd:active=active", r)
+		}
+		var rule SystemdUnitRule
+		for _, u := range strings.Split(parts[0], "|") {
+			rule.Units = append(rule.Units, strings.TrimSpace(u))
+		}
+		for _, c := range strings.Fields(parts[1]) {
+			if strings.HasPrefix(c, "uptime>=") {
+				rule.MinUptime = strings.TrimPrefix(c, "uptime>=")
+				continue
+			}
+			kv := strings.SplitN(c, "=", 2)
+			found := false
+			for _, cond := range systemdUnitConditions {
+				if len(kv) == 2 && strings.TrimSpace(kv[0]) == cond.key {
+					states := cond.states(&rule)
+					for _, v := range strings.Split(kv[1], "|") {
+						*states = append(*states, strings.TrimSpace(v))
+					}
+					found = true
+				}
+			}
+			if !found {
+				return nil, argError(s.Type(), arg, "invalid condition %q, expected active=, sub=, file= or uptime>=", c)
+			}
+		}
+		s.Rules = append(s.Rules, rule)
+	}
+	if err := s.ValidateArgs(); err != nil {
+		return nil, argError(s.Type(), arg, "%v", err)
+	}
+	return s, nil
+}
+
+func (s SystemdUnitCheck) Type() string {
+	return strings.ToLower("SystemdUnit")
+}
+
+func (s SystemdUnitCheck) PrettyName() string {
+	return fmt.Sprintf("%s:%s", s.Type(), strings.Join(s.requirements(), ";"))
+}
+
+func (SystemdUnitCheck) Metadata() Metadata {
+	return Metadata{
+		Description: "Check the active, sub and unit file states of systemd units",
+		Level:       FatalLevel,
+		Explain:     "services like firewalld or a stale kubelet interfere with the installation, while the time sync service is required by the cluster.",
+		Suggestion:  "Start, stop, enable, disable or mask the units by systemctl",
+	}
+}
+
+func (SystemdUnitCheck) Schema() Schema {
+	return Schema{
+		Arg: &Parameter{
+			Name:        "rules",
+			Type:        "string",
+			Example:     "firewalld:active=inactive|failed;chronyd|ntpd:active=active file=enabled uptime>=5m;kubelet:sub=!running",
+			Description: "the rules separated by ;, a rule is the units separated by | and the conditions active=, sub=, file= or uptime>= separated by space",
+		},
+		Parameters: []Parameter{
+			{Name: "rules", Type: "[]object", Example: "[{units: [chronyd, ntpd], activeState: [active], unitFileState: [enabled], minUptime: 5m}, {units: [docker], unitFileState: [masked]}]",
+				Description: "the rules satisfied by one of the units, the states are allowed or disallowed if prefixed with !"},
+			{Name: "systemctlShow", Type: "string", Description: "the file of captured output of systemctl show, systemctl is run if empty"},
+		},
+	}
+}
+
+func (s SystemdUnitCheck) ValidateArgs() error {
+	if len(s.Rules) == 0 {
+		return errors.New("at least one rule is required")
+	}
+	for _, r := range s.Rules {
+		if len(r.Units) == 0 {
+			return errors.New("at least one unit of rule is required")
+		}
+		for _, u := range r.Units {
+			if u == "" {
+				return errors.New("empty unit name")
+			}
+		}
+		if len(r.ActiveState) == 0 && len(r.SubState) == 0 && len(r.UnitFileState) == 0 && r.MinUptime == "" {
+			return errors.Errorf("at least one condition of %s is required", strings.Join(r.Units, "|"))
+		}
+		for _, cond := range systemdUnitConditions {
+			for _, state := range *cond.states(&r) {
+				if strings.TrimPrefix(state, "!") == "" {
+					return errors.Errorf("empty %s of %s", cond.property, strings.Join(r.Units, "|"))
+				}
+			}
+		}
+		if r.MinUptime != "" {
+			if _, err := time.ParseDuration(r.MinUptime); err != nil {
+				return errors.Errorf("invalid minimum uptime %q of %s", r.MinUptime, strings.Join(r.Units, "|"))
+			}
+		}
+	}
+	return nil
+}
+
+// requirements return the rules in the format of spec.
+func (s SystemdUnitCheck) requirements() []string {
+	var rs []string
+	for _, r := range s.Rules {
+		rs = append(rs, strings.Join(r.Units, "|")+":"+r.conditions())
+	}
+	return rs
+}
+
+// conditions return the conditions of rule in the format of spec.
+func (r SystemdUnitRule) conditions() string {
+	var cs []string
+	for _, cond := range systemdUnitConditions {
+		if states := *cond.states(&r); len(states) > 0 {
+			cs = append(cs, cond.key+"="+strings.Join(states, "|"))
+		}
+	}
+	if r.MinUptime != "" {
+		cs = append(cs, "uptime>="+r.MinUptime)
+	}
+	return strings.Join(cs, " ")
+}
+
+func (s SystemdUnitCheck) Validate() (bool, error) {
+	return ValidateReport(s.Evaluate(context.Background()))
+}
+
+func (s SystemdUnitCheck) Evaluate(ctx context.Context) (Report, error) {
+	if err := s.ValidateArgs(); err != nil {
+		return Report{}, err
+	}
+
+	var units []string
+	seen := make(map[string]bool)
+	uptimeRequired := false
+	for _, r := range s.Rules {
+		for _, u := range r.Units {
+			if u = unitName(u); !seen[u] {
+				seen[u] = true
+				units = append(units, u)
+			}
+		}
+		uptimeRequired = uptimeRequired || r.MinUptime != ""
+	}
+	shown, err := s.showUnits(ctx, units)
+	if err != nil {
+		return Report{}, err
+	}
+	var now time.Duration
+	if uptimeRequired {
+		if now, err = bootUptime(); err != nil {
+			return Report{}, err
+		}
+	}
+
+	var details []Detail
+	var observed []string
+	for _, r := range s.Rules {
+		d := Detail{Name: strings.Join(r.Units, "|"), Status: StatusFail, Expected: r.conditions()}
+		var states, messages []string
+		for _, u := range r.Units {
+			u = unitName(u)
+			props := findUnit(shown, u)
+			state, mismatches := r.match(u, props, now)
+			states = append(states, state)
+			if len(mismatches) == 0 {
+				d.Status, d.Observed = StatusPass, state
+				break
+			}
+			messages = append(messages, strings.Join(mismatches, ", "))
+		}
+		if d.Status != StatusPass {
+			d.Observed = strings.Join(states, ", ")
+			d.Message = strings.Join(messages, "; ")
+			if len(r.Units) > 1 {
+				d.Message = fmt.Sprintf("none of %s is satisfied: %s", d.Name, d.Message)
+			}
+		}
+		details = append(details, d)
+		observed = append(observed, d.Observed)
+	}
+
+	report := ReportDetails(details)
+	report.Observed = strings.Join(observed, "; ")
+	report.Expected = strings.Join(s.requirements(), ";")
+	return report, nil
+}
+
+// showUnits return the properties of units from the captured file or systemctl.
+func (s SystemdUnitCheck) showUnits(ctx context.Context, units []string) ([]system.UnitProperties, error) {
+	if s.SystemctlShow != "" {
+		data, err := os.ReadFile(s.SystemctlShow)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read captured systemctl show")
+		}
+		return system.ParseSystemctlShow(bytes.NewReader(data))
+	}
+	if HostRoot != "/" {
+		return nil, errors.Errorf("systemctl could not show the units of host root %s, capture its output by systemctlShow", HostRoot)
+	}
+	return system.ShowUnits(ctx, systemdUnitProperties, units...)
+}
+
+// findUnit return the properties of unit, the unit is not-found if it is not shown.
+func findUnit(shown []system.UnitProperties, unit string) system.UnitProperties {
+	for _, p := range shown {
+		if p.HasName(unit) {
+			return p
+		}
+	}
+	return system.UnitProperties{"Id": unit, "LoadState": "not-found", "ActiveState": "inactive", "SubState": "dead"}
+}
+
+// match return the states of the unit like "chronyd.service active/running enabled" and the mismatched conditions.
+func (r SystemdUnitRule) match(unit string, props system.UnitProperties, now time.Duration) (string, []string) {
+	state := fmt.Sprintf("%s %s/%s", unit, props["ActiveState"], props["SubState"])
+	if load := props["LoadState"]; load != "" && load != "loaded" && load != props["UnitFileState"] {
+		state += " " + props["LoadState"]
+	}
+	if props["UnitFileState"] != "" {
+		state += " " + props["UnitFileState"]
+	}
+
+	var mismatches []string
+	for _, cond := range systemdUnitConditions {
+		states := *cond.states(&r)
+		if len(states) == 0 {
+			continue
+		}
+		value := props[cond.property]
+		if !matchStates(value, states) {
+			if value == "" {
+				value = "empty"
+			}
+			mismatches = append(mismatches, fmt.Sprintf("%s %s is %s, expected %s", unit, cond.property, value, strings.Join(states, "|")))
+		}
+	}
+	if r.MinUptime != "" {
+		min, _ := time.ParseDuration(r.MinUptime)
+		entered, _ := strconv.ParseInt(props["ActiveEnterTimestampMonotonic"], 10, 64)
+		if props["ActiveState"] != "active" || entered <= 0 {
+			mismatches = append(mismatches, fmt.Sprintf("%s is not up, expected up for %s", unit, r.MinUptime))
+		} else {
+			uptime := (now - time.Duration(entered)*time.Microsecond).Truncate(time.Second)
+			state += " up " + uptime.String()
+			if uptime < min {
+				mismatches = append(mismatches, fmt.Sprintf("%s is up for %s, expected %s", unit, uptime, r.MinUptime))
+			}
+		}
+	}
+	return state, mismatches
+}
+
+// matchStates return true if the value is one of the allowed states and none of the disallowed ones prefixed with !.
+func matchStates(value string, states []string) bool {
+	allowed := false
+	restricted := false
+	for _, s := range states {
+		if strings.HasPrefix(s, "!") {
+			if value == s[1:] {
+				return false
+			}
+			continue
+		}
+		restricted = true
+		allowed = allowed || value == s
+	}
+	return allowed || !restricted
+}
+
+// bootUptime return the duration since boot by /proc/uptime, which is compared with the monotonic timestamps of
+// systemd, so that the uptime of unit is independent of the time zone and the wall clock adjusted by ntp.
+// /proc/uptime includes the time suspended while the monotonic timestamps do not, which is rare on the nodes.
+func bootUptime() (time.Duration, error) {
+	content, err := readHostFile("/proc/uptime")
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read /proc/uptime")
+	}
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return 0, errors.New("empty /proc/uptime")
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid /proc/uptime %q", content)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,648 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LimitsConf and the files of LimitsDir are read by pam_limits in order, see limits.conf(5).
+const (
+	LimitsConf = "/etc/security/limits.conf"
+	LimitsDir  = "/etc/security/limits.d"
+)
+
+// SystemdUnitDirs are the directories of system units in the order of priority, see systemd.unit(5).
+var SystemdUnitDirs = []string{"/etc/systemd/system", "/run/systemd/system", "/usr/local/lib/systemd/system", "/usr/lib/systemd/system", "/lib/systemd/system"}
+
+// SystemdSystemConf sets the DefaultLimit* of services, its drop-ins are in system.conf.d under the same
+// directories of SystemdUnitDirs' parents, see systemd-system.conf(5).
+const SystemdSystemConf = "/etc/systemd/system.conf"
+
+// unlimited is the value of "unlimited" in limits.conf and "infinity" of systemd.
+const unlimited = math.MaxUint64
+
+// ulimitItem is a resource limit, the size is in KB as limits.conf.
+type ulimitItem struct {
+	directive string
+	size      bool
+	// the default soft and hard limits of systemd, the default nproc is derived from kernel.threads-max.
+	soft, hard uint64
+}
+
+var ulimitItems = map[string]ulimitItem{
+	"nofile":  {directive: "LimitNOFILE", soft: 1024, hard: 524288},
+	"nproc":   {directive: "LimitNPROC"},
+	"core":    {directive: "LimitCORE", size: true, soft: 0, hard: unlimited},
+	"stack":   {directive: "LimitSTACK", size: true, soft: 8192, hard: unlimited},
+	"memlock": {directive: "LimitMEMLOCK", size: true, soft: 8192, hard: 8192},
+	"as":      {directive: "LimitAS", size: true, soft: unlimited, hard: unlimited},
+	"fsize":   {directive: "LimitFSIZE", size: true, soft: unlimited, hard: unlimited},
+	"data":    {directive: "LimitDATA", size: true, soft: unlimited, hard: unlimited},
+	"cpu":     {directive: "LimitCPU", soft: unlimited, hard: unlimited},
+	"locks":   {directive: "LimitLOCKS", soft: unlimited, hard: unlimited},
+}
+
+var ulimitRegexp = regexp.MustCompile(`^\s*([a-z]+)(?:\.(soft|hard))?\s*>=\s*(\d+|unlimited)\s*$`)
+
+// UlimitCheck checks the effective resource limits of the processes of a user, or of a systemd service.
+type UlimitCheck struct {
+	// Limits the required minimums, like nofile>=65536 for both soft and hard limits, or nofile.soft>=1024,
+	// the size is in KB as limits.conf.
+	Limits []string `json:"limits" yaml:"limits"`
+	// User whose limits are set by pam_limits, it is root if neither user nor service is specified.
+	User string `json:"user,omitempty" yaml:"user,omitempty"`
+	// Service is the systemd service whose limits are set by its unit, like containerd or containerd.service.
+	Service string `json:"service,omitempty" yaml:"service,omitempty"`
+}
+
+// newUlimitCheck build UlimitCheck from the limits and the target separated by ";",
+// like "nofile>=65536;nproc>=4096;user=mysql" or "nofile>=1048576;service=containerd".
+func newUlimitCheck(arg string) (Interface, error) {
+	var u UlimitCheck
+	for _, r := range strings.Split(arg, ";") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(r, "user="):
+			u.User = strings.TrimSpace(strings.TrimPrefix(r, "user="))
+		case strings.HasPrefix(r, "service="):
+			u.Service = strings.TrimSpace(strings.TrimPrefix(r, "service="))
+		default:
+			u.Limits = append(u.Limits, r)
+		}
+	}
+	if err := u.ValidateArgs(); err != nil {
+		return nil, argError(u.Type(), arg, "%v", err)
+	}
+	return u, nil
+}
+
+func (u UlimitCheck) Type() string {
+	return strings.ToLower("Ulimit")
+}
+
+func (u UlimitCheck) PrettyName() string {
+	return fmt.Sprintf("%s:%s", u.Type(), strings.Join(u.requirements(), ";"))
+}
+
+func (UlimitCheck) Metadata() Metadata {
+	return Metadata{
+		Description: "Check the effective resource limits of a user or systemd service",
+		Level:       FatalLevel,
+		Explain:     "databases and kubernetes components fail with too many open files or could not create threads if the limits are low.",
+		Suggestion:  "Raise the limits by limits.conf or LimitNOFILE of the service, and fs.nr_open if the limit exceeds it",
+	}
+}
+
+func (UlimitCheck) Schema() Schema {
+	return Schema{
+		Arg: &Parameter{
+			Name:        "limits",
+			Type:        "string",
+			Example:     "nofile>=65536;nproc>=4096;user=mysql",
+			Description: "the required minimums and the user or service separated by ;",
+		},
+		Parameters: []Parameter{
+			{Name: "limits", Type: "[]string", Unit: "KB for sizes", Example: "['nofile>=1048576', 'memlock.soft>=unlimited']", Description: "the required minimums of soft and hard limits, or one of them by .soft or .hard"},
+			{Name: "user", Type: "string", Default: "root", Example: "mysql", Description: "the user whose limits are set by pam_limits"},
+			{Name: "service", Type: "string", Description: "the systemd service whose limits are set by its unit like containerd, exclusive with user"},
+		},
+	}
+}
+
+func (u UlimitCheck) ValidateArgs() error {
+	if len(u.Limits) == 0 {
+		return errors.New("at least one limit is required")
+	}
+	for _, l := range u.Limits {
+		match := ulimitRegexp.FindStringSubmatch(l)
+		if match == nil {
+			return errors.Errorf("invalid limit %q, expected like nofile>=65536", l)
+		}
+		if _, ok := ulimitItems[match[1]]; !ok {
+			return errors.Errorf("unsupported limit %q, expected one of %s", match[1], strings.Join(ulimitItemNames(), ","))
+		}
+	}
+	if u.User != "" && u.Service != "" {
+		return errors.New("only one of user and service could be specified")
+	}
+	return nil
+}
+
+func ulimitItemNames() []string {
+	names := make([]string, 0, len(ulimitItems))
+	for name := range ulimitItems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// requirements return the requirements in the format of spec.
+func (u UlimitCheck) requirements() []string {
+	rs := append([]string{}, u.Limits...)
+	if u.User != "" {
+		rs = append(rs, "user="+u.User)
+	}
+	if u.Service != "" {
+		rs = append(rs, "service="+u.Service)
+	}
+	return rs
+}
+
+// target return the description of user or service.
+func (u UlimitCheck) target() string {
+	if u.Service != "" {
+		return "service " + unitName(u.Service)
+	}
+	if u.User != "" {
+		return "user " + u.User
+	}
+	return "user root"
+}
+
+func (u UlimitCheck) Validate() (bool, error) {
+	return ValidateReport(u.Evaluate(context.Background()))
+}
+
+func (u UlimitCheck) Evaluate(ctx context.Context) (Report, error) {
+	if err := u.ValidateArgs(); err != nil {
+		return Report{}, err
+	}
+
+	limits, err := systemdDefaultLimits()
+	if err != nil {
+		return Report{}, err
+	}
+	if u.Service != "" {
+		found, err := serviceLimits(unitName(u.Service), limits)
+		if err != nil {
+			return Report{}, err
+		}
+		if !found {
+			report := ReportDetails([]Detail{{Name: "service", Status: StatusFail, Observed: "not found", Expected: unitName(u.Service),
+				Message: fmt.Sprintf("unit %s is not found in %s", unitName(u.Service), strings.Join(SystemdUnitDirs, ","))}})
+			report.Expected = strings.Join(u.requirements(), ";")
+			return report, nil
+		}
+	} else {
+		user := u.User
+		if user == "" {
+			user = "root"
+		}
+		if err := pamLimits(user, limits); err != nil {
+			return Report{}, err
+		}
+	}
+
+	nrOpen, err := readSysctlUint("fs.nr_open")
+	if err != nil {
+		return Report{}, err
+	}
+	var details []Detail
+	var observed []string
+	maxNofile := uint64(0)
+	for _, l := range u.Limits {
+		match := ulimitRegexp.FindStringSubmatch(l)
+		item, kind := match[1], match[2]
+		required := parseLimitValue(match[3])
+		if item == "nofile" && required > maxNofile {
+			maxNofile = required
+		}
+
+		limit := limits[item]
+		for _, k := range []string{"soft", "hard"} {
+			if kind != "" && kind != k {
+				continue
+			}
+			value := limit.soft
+			if k == "hard" {
+				value = limit.hard
+			}
+			details = append(details, u.limitDetail(item, k, value, required, nrOpen))
+		}
+		observed = append(observed, fmt.Sprintf("%s=%s:%s", item, formatLimit(limit.soft.value), formatLimit(limit.hard.value)))
+	}
+
+	if maxNofile > 0 {
+		fileMax, err := readSysctlUint("fs.file-max")
+		if err != nil {
+			return Report{}, err
+		}
+		for _, k := range []struct {
+			name  string
+			value uint64
+			// the limit could never be raised above fs.nr_open, while fs.file-max is shared by all processes.
+			status Status
+		}{
+			{"fs.nr_open", nrOpen, StatusFail},
+			{"fs.file-max", fileMax, StatusWarn},
+		} {
+			d := Detail{Name: k.name, Status: StatusPass, Observed: formatLimit(k.value), Expected: ">=" + formatLimit(maxNofile)}
+			if k.value < maxNofile {
+				d.Status = k.status
+				d.Message = fmt.Sprintf("%s is %d, less than the required nofile %s", k.name, k.value, formatLimit(maxNofile))
+			}
+			details = append(details, d)
+		}
+	}
+
+	report := ReportDetails(details)
+	report.Observed = u.target() + " " + strings.Join(observed, ",")
+	report.Expected = strings.Join(u.requirements(), ";")
+	return report, nil
+}
+
+func (u UlimitCheck) limitDetail(item, kind string, value limitSetting, required, nrOpen uint64) Detail {
+	name := item + " " + kind
+	d := Detail{Name: name, Status: StatusPass, Observed: fmt.Sprintf("%s by %s", formatLimit(value.value), value.source), Expected: ">=" + formatLimit(required)}
+	switch {
+	case item == "nofile" && value.value > nrOpen && u.Service == "":
+		d.Status = StatusFail
+		d.Message = fmt.Sprintf("%s limit of %s is %s by %s, pam_limits fails to set it since it exceeds fs.nr_open %d",
+			name, u.target(), formatLimit(value.value), value.source, nrOpen)
+	case value.value < required:
+		d.Status = StatusFail
+		d.Message = fmt.Sprintf("%s limit of %s is %s by %s, less than the required %s", name, u.target(), formatLimit(value.value), value.source, formatLimit(required))
+	}
+	return d
+}
+
+// limitSetting is the value of limit and the file which sets it.
+type limitSetting struct {
+	value  uint64
+	source string
+}
+
+type limitPair struct {
+	soft, hard limitSetting
+}
+
+func parseLimitValue(s string) uint64 {
+	switch strings.ToLower(s) {
+	case "unlimited", "infinity", "-1":
+		return unlimited
+	}
+	v, _ := strconv.ParseUint(s, 10, 64)
+	return v
+}
+
+func formatLimit(v uint64) string {
+	if v == unlimited {
+		return "unlimited"
+	}
+	return strconv.FormatUint(v, 10)
+}
+
+func readSysctlUint(key string) (uint64, error) {
+	value, err := readHostFile(path.Join("/proc/sys", sysctlKeyPath(key)))
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to read %s", key)
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// systemdDefaultLimits return the DefaultLimit* of systemd, or the built-in defaults.
+func systemdDefaultLimits() (map[string]limitPair, error) {
+	limits := make(map[string]limitPair)
+	for name, item := range ulimitItems {
+		limits[name] = limitPair{soft: limitSetting{item.soft, "default"}, hard: limitSetting{item.hard, "default"}}
+	}
+	// the default nproc of init is half of kernel.threads-max.
+	threads, err := readSysctlUint("kernel.threads-max")
+	if err != nil {
+		return nil, err
+	}
+	limits["nproc"] = limitPair{soft: limitSetting{threads / 2, "default"}, hard: limitSetting{threads / 2, "default"}}
+
+	files := []string{SystemdSystemConf}
+	dropIns, err := dropInFiles([]string{"/etc/systemd", "/run/systemd", "/usr/local/lib/systemd", "/usr/lib/systemd", "/lib/systemd"}, "system.conf.d")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range append(files, dropIns...) {
+		err := scanUnitFile(file, "Manager", func(key, value string) {
+			if name, ok := limitItemOf(strings.TrimPrefix(key, "Default")); ok && strings.HasPrefix(key, "Default") {
+				setSystemdLimit(limits, name, value, file)
+			}
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "failed to read %s", file)
+		}
+	}
+	return limits, nil
+}
+
+// serviceLimits apply the limits of the unit file and its drop-ins, it is false if the unit is not found.
+func serviceLimits(unit string, limits map[string]limitPair) (bool, error) {
+	names := []string{unit}
+	if i := strings.Index(unit, "@"); i > 0 {
+		// the template of instance like getty@tty1.service is getty@.service.
+		names = append(names, unit[:i+1]+path.Ext(unit))
+	}
+
+	var files []string
+	for _, name := range names {
+		for _, dir := range SystemdUnitDirs {
+			if _, err := os.Stat(hostPath(path.Join(dir, name))); err == nil {
+				files = append(files, path.Join(dir, name))
+				break
+			}
+		}
+		if len(files) > 0 {
+			break
+		}
+	}
+	if len(files) == 0 {
+		return false, nil
+	}
+	for i := len(names) - 1; i >= 0; i-- {
+		dropIns, err := dropInFiles(SystemdUnitDirs, names[i]+".d")
+		if err != nil {
+			return false, err
+		}
+		files = append(files, dropIns...)
+	}
+
+	for _, file := range files {
+		err := scanUnitFile(file, "Service", func(key, value string) {
+			if name, ok := limitItemOf(key); ok {
+				setSystemdLimit(limits, name, value, file)
+			}
+		})
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to read %s", file)
+		}
+	}
+	return true, nil
+}
+
+// unitName append .service to the name without unit type.
+func unitName(name string) string {
+	if path.Ext(name) == "" {
+		return name + ".service"
+	}
+	return name
+}
+
+// dropInFiles return the *.conf files of the sub directory under dirs in order of their names,
+// the file in the former dir masks the one with the same name in the latter.
+func dropInFiles(dirs []string, sub string) ([]string, error) {
+	files := make(map[string]string)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		matches, err := filepath.Glob(hostPath(path.Join(dirs[i], sub, "*.conf")))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			files[filepath.Base(m)] = path.Join(dirs[i], sub, filepath.Base(m))
+		}
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ordered := make([]string, 0, len(names))
+	for _, name := range names {
+		ordered = append(ordered, files[name])
+	}
+	return ordered, nil
+}
+
+// scanUnitFile call fn with the key and value of the section in the systemd unit or config file.
+func scanUnitFile(file, section string, fn func(key, value string)) error {
+	current := ""
+	return scanHostFile(file, func(line string) {
+		switch {
+		case line[0] == '#' || line[0] == ';':
+		case line[0] == '[':
+			current = strings.Trim(line, "[]")
+		case current == section:
+			if kv := strings.SplitN(line, "=", 2); len(kv) == 2 {
+				fn(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+			}
+		}
+	})
+}
+
+// limitItemOf return the item of systemd directive like LimitNOFILE.
+func limitItemOf(directive string) (string, bool) {
+	for name, item := range ulimitItems {
+		if item.directive == directive {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// setSystemdLimit set the limit by the value like 65536, 1024:524288 or infinity, the size is in bytes
+// with optional suffix K, M, G, T, P or E, and the empty value resets it to the default.
+func setSystemdLimit(limits map[string]limitPair, name, value, file string) {
+	item := ulimitItems[name]
+	if value == "" {
+		limits[name] = limitPair{soft: limitSetting{item.soft, file}, hard: limitSetting{item.hard, file}}
+		return
+	}
+	parts := strings.SplitN(value, ":", 2)
+	soft, ok := parseSystemdLimit(parts[0], item.size)
+	if !ok {
+		return
+	}
+	hard := soft
+	if len(parts) == 2 {
+		if hard, ok = parseSystemdLimit(parts[1], item.size); !ok {
+			return
+		}
+	}
+	limits[name] = limitPair{soft: limitSetting{soft, file}, hard: limitSetting{hard, file}}
+}
+
+func parseSystemdLimit(s string, size bool) (uint64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "infinity" {
+		return unlimited, true
+	}
+	if !size {
+		v, err := strconv.ParseUint(s, 10, 64)
+		return v, err == nil
+	}
+	multiplier := uint64(1)
+	if i := strings.IndexAny(s, "KMGTPE"); i > 0 && i == len(s)-1 {
+		multiplier = uint64(1) << (10 * (strings.IndexByte("KMGTPE", s[i]) + 1))
+		s = s[:i]
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	// convert bytes to KB as limits.conf.
+	return v * multiplier / 1024, err == nil
+}
+
+// priorities of pam_limits, the lower one overrides the higher one, and the later one overrides the former one
+// with the same priority.
+const (
+	limitsPriorityUser = iota
+	limitsPriorityGroup
+	limitsPriorityAll
+	limitsPriorityDefault
+)
+
+// pamLimits apply the limits of LimitsConf and LimitsDir for the user as pam_limits.
+func pamLimits(user string, limits map[string]limitPair) error {
+	uid, gid, groups, err := lookupUser(user)
+	if err != nil {
+		return err
+	}
+
+	files := []string{LimitsConf}
+	matches, err := filepath.Glob(hostPath(path.Join(LimitsDir, "*.conf")))
+	if err != nil {
+		return err
+	}
+	sort.Strings(matches)
+	for _, m := range matches {
+		files = append(files, path.Join(LimitsDir, filepath.Base(m)))
+	}
+
+	priorities := make(map[string]int)
+	for _, file := range files {
+		err := scanHostFile(file, func(line string) {
+			if line[0] == '#' {
+				return
+			}
+			// <domain> <type> <item> <value>
+			fields := strings.Fields(line)
+			if len(fields) < 4 {
+				return
+			}
+			domain, kind, item, value := fields[0], fields[1], fields[2], fields[3]
+			if _, ok := ulimitItems[item]; !ok {
+				return
+			}
+			priority, ok := matchLimitsDomain(domain, user, uid, gid, groups)
+			if !ok {
+				return
+			}
+			v := parseLimitValue(value)
+			if v == 0 && value != "0" {
+				return
+			}
+			limit := limits[item]
+			for _, k := range []string{"soft", "hard"} {
+				if kind != "-" && kind != k {
+					continue
+				}
+				key := item + " " + k
+				if p, set := priorities[key]; set && p < priority {
+					continue
+				}
+				priorities[key] = priority
+				if k == "soft" {
+					limit.soft = limitSetting{v, file}
+				} else {
+					limit.hard = limitSetting{v, file}
+				}
+			}
+			limits[item] = limit
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to read %s", file)
+		}
+	}
+	return nil
+}
+
+// matchLimitsDomain return the priority if the domain like user, @group, *, 1000: or @100:200 matches the user.
+func matchLimitsDomain(domain, user string, uid, gid int, groups map[string]bool) (int, bool) {
+	switch {
+	case domain == user:
+		return limitsPriorityUser, true
+	case domain == "*":
+		// the wildcard does not apply to root.
+		return limitsPriorityAll, uid != 0
+	case strings.Contains(domain, ":"):
+		id, priority := uid, limitsPriorityUser
+		if strings.HasPrefix(domain, "@") || strings.HasPrefix(domain, "%") {
+			id, priority = gid, limitsPriorityGroup
+		}
+		bounds := strings.SplitN(strings.TrimLeft(domain, "@%"), ":", 2)
+		min, max := 0, math.MaxInt32
+		var err1, err2 error
+		if bounds[0] != "" {
+			min, err1 = strconv.Atoi(bounds[0])
+		}
+		if bounds[1] != "" {
+			max, err2 = strconv.Atoi(bounds[1])
+		}
+		return priority, err1 == nil && err2 == nil && id >= 0 && min <= id && id <= max
+	case strings.HasPrefix(domain, "@"):
+		return limitsPriorityGroup, groups[domain[1:]]
+	case strings.HasPrefix(domain, "%"):
+		return limitsPriorityAll, domain == "%" || groups[domain[1:]]
+	}
+	return limitsPriorityDefault, false
+}
+
+// lookupUser return the uid, gid and groups of the user from /etc/passwd and /etc/group, the ids are -1 if not found.
+func lookupUser(user string) (int, int, map[string]bool, error) {
+	uid, gid := -1, -1
+	err := scanHostFile("/etc/passwd", func(line string) {
+		// mysql:x:27:27:MySQL Server:/var/lib/mysql:/bin/false
+		fields := strings.Split(line, ":")
+		if len(fields) >= 4 && fields[0] == user {
+			uid, _ = strconv.Atoi(fields[2])
+			gid, _ = strconv.Atoi(fields[3])
+		}
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return 0, 0, nil, errors.Wrap(err, "failed to read /etc/passwd")
+	}
+	if uid < 0 && user == "root" {
+		uid, gid = 0, 0
+	}
+
+	groups := make(map[string]bool)
+	err = scanHostFile("/etc/group", func(line string) {
+		// docker:x:998:alice,bob
+		fields := strings.Split(line, ":")
+		if len(fields) < 4 {
+			return
+		}
+		if id, err := strconv.Atoi(fields[2]); err == nil && id == gid {
+			groups[fields[0]] = true
+		}
+		for _, member := range strings.Split(fields[3], ",") {
+			if member == user {
+				groups[fields[0]] = true
+			}
+		}
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return 0, 0, nil, errors.Wrap(err, "failed to read /etc/group")
+	}
+	return uid, gid, groups, nil
+}
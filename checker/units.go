@@ -0,0 +1,73 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40}, {"Pi", 1 << 50},
+	{"K", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12}, {"P", 1e15},
+}
+
+// parseSize parse the size like 50Gi, 500M or 1024 to bytes, the binary and decimal units are both supported.
+func parseSize(s string) (uint64, error) {
+	str := strings.TrimSuffix(strings.TrimSpace(s), "B")
+	multiple := float64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(str, u.suffix) {
+			str, multiple = strings.TrimSuffix(str, u.suffix), u.bytes
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil || v < 0 {
+		return 0, errors.Errorf("invalid size %q, expected a number with optional unit like 50Gi", s)
+	}
+	return uint64(v * multiple), nil
+}
+
+// formatSize format bytes in binary unit, like 50.0Gi.
+func formatSize(bytes uint64) string {
+	for i := 4; i >= 0; i-- {
+		u := sizeUnits[i]
+		if float64(bytes) >= u.bytes {
+			return fmt.Sprintf("%.1f%s", float64(bytes)/u.bytes, u.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", bytes)
+}
+
+// parsePercent parse the percent like 10% or 10, it must be in range 0-100.
+func parsePercent(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || v < 0 || v > 100 {
+		return 0, errors.Errorf("invalid percent %q, expected a number in range 0-100 like 10%%", s)
+	}
+	return v, nil
+}
+
+// formatPercent format percent with one decimal, like 10.5%.
+func formatPercent(v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64) + "%"
+}
@@ -0,0 +1,189 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// semVersion is a semantic version like 1.7.2 or v1.28.0-rc.1, the build metadata is ignored.
+type semVersion struct {
+	numbers    []int
+	preRelease string
+	raw        string
+}
+
+func parseVersion(s string) (semVersion, error) {
+	raw := strings.TrimSpace(s)
+	v := semVersion{raw: raw}
+	core := strings.TrimPrefix(raw, "v")
+	if i := strings.Index(core, "+"); i >= 0 {
+		core = core[:i]
+	}
+	if i := strings.Index(core, "-"); i >= 0 {
+		core, v.preRelease = core[:i], core[i+1:]
+	}
+	for _, n := range strings.Split(core, ".") {
+		number, err := strconv.Atoi(n)
+		if err != nil || number < 0 {
+			return semVersion{}, errors.Errorf("invalid version %q", s)
+		}
+		v.numbers = append(v.numbers, number)
+	}
+	return v, nil
+}
+
+func (v semVersion) String() string {
+	return v.raw
+}
+
+// compare return -1, 0 or 1 if v is less than, equal to or greater than o, the missing numbers are 0,
+// and the pre-release version is less than the release one.
+func (v semVersion) compare(o semVersion) int {
+	for i := 0; i < len(v.numbers) || i < len(o.numbers); i++ {
+		a, b := 0, 0
+		if i < len(v.numbers) {
+			a = v.numbers[i]
+		}
+		if i < len(o.numbers) {
+			b = o.numbers[i]
+		}
+		if a != b {
+			return compareInt(a, b)
+		}
+	}
+	switch {
+	case v.preRelease == o.preRelease:
+		return 0
+	case v.preRelease == "":
+		return 1
+	case o.preRelease == "":
+		return -1
+	default:
+		return comparePreRelease(v.preRelease, o.preRelease)
+	}
+}
+
+// comparePreRelease compare the identifiers separated by dot, the numeric ones are compared numerically.
+func comparePreRelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil && an != bn:
+			return compareInt(an, bn)
+		case aErr == nil && bErr != nil:
+			return -1
+		case aErr != nil && bErr == nil:
+			return 1
+		case as[i] != bs[i]:
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+	return compareInt(len(as), len(bs))
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+type versionCondition struct {
+	op      string
+	version semVersion
+}
+
+func (c versionCondition) match(v semVersion) bool {
+	r := v.compare(c.version)
+	switch c.op {
+	case "=":
+		return r == 0
+	case "!=":
+		return r != 0
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	case "<":
+		return r < 0
+	default:
+		return r <= 0
+	}
+}
+
+// versionConstraint is the conditions separated by space which are all required, and the alternatives
+// separated by ||, like ">=1.8.4 <2" or "<1.7 || >=1.7.2".
+type versionConstraint [][]versionCondition
+
+func parseVersionConstraint(s string) (versionConstraint, error) {
+	var constraint versionConstraint
+	for _, alternative := range strings.Split(s, "||") {
+		var conditions []versionCondition
+		fields := strings.Fields(alternative)
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			op := ""
+			for _, o := range []string{">=", "<=", "!=", ">", "<", "="} {
+				if strings.HasPrefix(field, o) {
+					op = o
+					break
+				}
+			}
+			value := strings.TrimSpace(field[len(op):])
+			if value == "" && i+1 < len(fields) {
+				// the operator is separated from version by space, like ">= 1.8"
+				i++
+				value = fields[i]
+			}
+			if op == "" {
+				op = "="
+			}
+			v, err := parseVersion(value)
+			if err != nil {
+				return nil, errors.Errorf("invalid version constraint %q, expected like >=1.8.4 <2", s)
+			}
+			conditions = append(conditions, versionCondition{op: op, version: v})
+		}
+		if len(conditions) == 0 {
+			return nil, errors.Errorf("invalid version constraint %q, expected like >=1.8.4 <2", s)
+		}
+		constraint = append(constraint, conditions)
+	}
+	return constraint, nil
+}
+
+// match return true if v meets all conditions of any alternative.
+func (c versionConstraint) match(v semVersion) bool {
+	for _, conditions := range c {
+		matched := true
+		for _, condition := range conditions {
+			matched = matched && condition.match(v)
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
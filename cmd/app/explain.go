@@ -0,0 +1,109 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"preflight/checker"
+	"preflight/plan"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var explainCmd = &cobra.Command{
+	Use:     "explain ${type}",
+	Short:   "preflight explain",
+	Long:    "show the description, level, explanation, suggestion, parameters and examples of checker",
+	Example: `preflight explain port`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		checkType := strings.ToLower(args[0])
+		c, err := checker.GetCheckersByType(checkType)
+		if err != nil {
+			return err
+		}
+		explain(os.Stdout, checkType, c)
+		return nil
+	},
+}
+
+func explain(w io.Writer, checkType string, c checker.Interface) {
+	metadata := c.Metadata()
+	schema := checker.GetSchema(c)
+
+	fmt.Fprintf(w, "TYPE:        %s\n", checkType)
+	fmt.Fprintf(w, "LEVEL:       %s\n", metadata.Level)
+	fmt.Fprintf(w, "DESCRIPTION: %s\n", metadata.Description)
+	if metadata.Explain != "" {
+		fmt.Fprintf(w, "\nEXPLAIN:\n  %s\n", metadata.Explain)
+	}
+	if metadata.Suggestion != "" {
+		fmt.Fprintf(w, "\nSUGGESTION:\n  %s\n", metadata.Suggestion)
+	}
+
+	if schema.Arg != nil {
+		fmt.Fprintf(w, "\nARGUMENT:\n  %s: %s\n", specName(checkType, schema), schema.Arg.Description)
+		if schema.Arg.Default != "" {
+			fmt.Fprintf(w, "  default is %s if omitted\n", schema.Arg.Default)
+		}
+	}
+	if len(schema.Parameters) > 0 {
+		fmt.Fprintf(w, "\nPARAMETERS:\n")
+		table := tablewriter.NewWriter(w)
+		table.SetHeader([]string{"NAME", "TYPE", "UNIT", "DEFAULT", "EXAMPLE", "DESCRIPTION"})
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		for _, p := range schema.Parameters {
+			table.Append([]string{p.Name, p.Type, p.Unit, p.Default, p.Example, p.Description})
+		}
+		table.Render()
+	}
+
+	fmt.Fprintf(w, "\nEXAMPLES:\n")
+	for _, e := range examples(checkType, schema) {
+		fmt.Fprintf(w, "  %s\n", strings.ReplaceAll(e, "\n", "\n  "))
+	}
+}
+
+// examples return the invocations of checker by spec, --checker and plan file.
+func examples(checkType string, schema checker.Schema) []string {
+	var es []string
+	if schema.Arg != nil && schema.Arg.Example != "" {
+		es = append(es,
+			fmt.Sprintf("preflight run '%s:%s'", checkType, schema.Arg.Example),
+			fmt.Sprintf("preflight run -c %s --args '%s'", checkType, schema.Arg.Example),
+		)
+	}
+
+	p := []string{"# preflight run -f plan.yaml", "version: " + plan.Version, "checkers:", "  - type: " + checkType}
+	var args []string
+	for _, param := range schema.Parameters {
+		if param.Example != "" {
+			args = append(args, fmt.Sprintf("      %s: %s", param.Name, param.Example))
+		}
+	}
+	if len(args) > 0 {
+		p = append(p, "    args:")
+		p = append(p, args...)
+	}
+	return append(es, strings.Join(p, "\n"))
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+}
@@ -15,12 +15,15 @@
 package app
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"preflight/checker"
 
 	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -31,27 +34,53 @@ const (
 )
 
 type ListResponse struct {
-	Type     string
-	Name     string
-	Metadata checker.Metadata
+	Type     string           `json:"type" yaml:"type"`
+	Name     string           `json:"name" yaml:"name"`
+	Metadata checker.Metadata `json:"metadata" yaml:"metadata"`
+	Schema   checker.Schema   `json:"schema" yaml:"schema"`
 }
 
+var listOutput string
+
 var listCmd = &cobra.Command{
-	Use:     "list",
-	Short:   "preflight list",
-	Long:    "",
-	Example: `preflight list`,
+	Use:   "list",
+	Short: "preflight list",
+	Long:  "",
+	Example: `preflight list
+preflight list -o json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var resp []ListResponse
 
-		for types, checker := range checker.GetAllCheckers() {
+		for _, types := range checker.GetAllCheckerTypes() {
+			c, err := checker.GetCheckersByType(types)
+			if err != nil {
+				return err
+			}
 			resp = append(resp, ListResponse{
-				Name:     fmt.Sprintf("%s:%s", types, "${arg}"),
+				Name:     specName(types, checker.GetSchema(c)),
 				Type:     types,
-				Metadata: checker.Metadata(),
+				Metadata: c.Metadata(),
+				Schema:   checker.GetSchema(c),
 			})
 		}
 
+		switch listOutput {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "    ")
+			return enc.Encode(resp)
+		case "yaml":
+			enc := yaml.NewEncoder(os.Stdout)
+			enc.SetIndent(2)
+			if err := enc.Encode(resp); err != nil {
+				return err
+			}
+			return enc.Close()
+		case "table":
+		default:
+			return errors.Errorf("unknown output format %q, expected one of table,json,yaml", listOutput)
+		}
+
 		table := tablewriter.NewWriter(os.Stdout)
 		table.SetHeader([]string{checkerName, checkerType, level, description})
 
@@ -65,6 +94,20 @@ var listCmd = &cobra.Command{
 	},
 }
 
+// specName return the checker spec with the name of its argument, like port:${port}.
+// the checker could only be declared in plan file has no argument.
+func specName(checkType string, schema checker.Schema) string {
+	switch {
+	case schema.Arg != nil:
+		return fmt.Sprintf("%s:${%s}", checkType, schema.Arg.Name)
+	case len(schema.Parameters) > 0:
+		return checkType
+	default:
+		return fmt.Sprintf("%s:%s", checkType, "${arg}")
+	}
+}
+
 func init() {
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", "table", "specify the output format, one of table|json|yaml")
 	rootCmd.AddCommand(listCmd)
 }
@@ -15,10 +15,15 @@
 package app
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
+	"os"
+	"os/signal"
+	"preflight/checker"
 	"preflight/result"
 	"preflight/runner"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -30,41 +35,156 @@ type RunArgs struct {
 	NotTolerable bool
 	CheckerType  string
 	CheckerArgs  string
+	PlanFile     string
+	CheckTimeout time.Duration
+	Timeout      time.Duration
+	Concurrency  int
+	FailOn       string
+	Output       string
+	ReportFile   string
+	MetricsFile  string
 }
 
 var runArgs *RunArgs
 
 var runCmd = &cobra.Command{
-	Use:     "run",
-	Short:   "preflight run",
-	Long:    "",
-	Example: `preflight run`,
-	RunE:    runPreflight,
+	Use:   "run [${type}:${arg}...]",
+	Short: "preflight run",
+	Long:  "",
+	Example: `preflight run
+preflight run -f plan.yaml
+preflight run -c port --args 6443,10250
+preflight run memory:2048 port:6443
+preflight run -o junit > preflight.xml
+preflight run -o html --report-file report.html
+preflight run --metrics-file /var/lib/node_exporter/textfile/preflight.prom`,
+	RunE: runPreflight,
 }
 
 func runPreflight(cmd *cobra.Command, args []string) error {
-	r, err := runner.NewDefaultRunner(runner.WithSkips(runArgs.Skip), runner.WithToleration(runArgs.NotTolerable))
+	if !checker.IsValidLevel(runArgs.FailOn) {
+		return errors.Errorf("invalid --fail-on level %q, expected one of %s", runArgs.FailOn, strings.Join(checker.Levels, ","))
+	}
+	writer, err := result.GetWriter(runArgs.Output)
 	if err != nil {
-		return errors.Wrap(err, "failed to init runner")
+		return err
+	}
+
+	opts := []runner.Option{
+		runner.WithSkips(runArgs.Skip),
+		runner.WithToleration(runArgs.NotTolerable),
+		runner.WithFailFastLevel(runArgs.FailOn),
+		runner.WithCheckTimeout(runArgs.CheckTimeout),
+		runner.WithTimeout(runArgs.Timeout),
+		runner.WithConcurrency(runArgs.Concurrency),
 	}
 
-	resp := result.NewDefaultFormatter(r.Execute()).Format(result.WithIgnores(runArgs.Ignore))
+	checks, err := buildCheckers(args)
+	if err != nil {
+		return errors.Wrap(err, "failed to build checkers")
+	}
 
-	responseJSON, err := json.MarshalIndent(resp, "", "    ")
+	r, err := runner.NewCheckRunner(checks, opts...)
 	if err != nil {
-		return errors.Wrap(err, "format json failed")
+		return errors.Wrap(err, "failed to init runner")
 	}
 
-	fmt.Println(string(responseJSON))
+	// stop running on SIGINT/SIGTERM and still show the report of finished checkers,
+	// the second signal will terminate the process immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	start := time.Now()
+	resp := result.NewDefaultFormatter(r.ExecuteContext(ctx)).Format(result.WithIgnores(runArgs.Ignore))
 
+	if err := writeReport(writer, resp); err != nil {
+		return errors.Wrapf(err, "format %s failed", runArgs.Output)
+	}
+	if runArgs.MetricsFile != "" {
+		if err := result.ExportPrometheus(runArgs.MetricsFile, resp, start); err != nil {
+			return errors.Wrap(err, "export metrics failed")
+		}
+	}
+
+	exitCode = result.ExitCode(resp, runArgs.FailOn)
 	return nil
 }
 
+// writeReport write the report to --report-file, or stdout if not specified.
+func writeReport(writer result.Writer, resp result.Response) error {
+	if runArgs.ReportFile == "" {
+		return writer(os.Stdout, resp)
+	}
+
+	f, err := os.Create(runArgs.ReportFile)
+	if err != nil {
+		return err
+	}
+	if err := writer(f, resp); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// buildCheckers collect checkers from plan file, `--checker` with `--args` and checker specs in order,
+// the build-in checkers are used if none of them specified.
+func buildCheckers(specs []string) ([]checker.Interface, error) {
+	var checks []checker.Interface
+	if runArgs.PlanFile != "" {
+		planChecks, err := runner.BuildPlanCheckers(runArgs.PlanFile)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, planChecks...)
+	}
+
+	if runArgs.CheckerType == "" && runArgs.CheckerArgs != "" {
+		return nil, errors.New("--args must be used with --checker")
+	}
+	if runArgs.CheckerType != "" {
+		checkerArgs := []string{runArgs.CheckerArgs}
+		if runArgs.CheckerArgs != "" {
+			checkerArgs = strings.Split(runArgs.CheckerArgs, ",")
+		}
+		for _, arg := range checkerArgs {
+			c, err := checker.NewChecker(runArgs.CheckerType, arg)
+			if err != nil {
+				return nil, err
+			}
+			checks = append(checks, c)
+		}
+	}
+
+	specChecks, err := runner.BuildSpecCheckers(specs)
+	if err != nil {
+		return nil, err
+	}
+	checks = append(checks, specChecks...)
+
+	if len(checks) == 0 {
+		return runner.BuildInitCheckers(), nil
+	}
+	return checks, nil
+}
+
 func init() {
 	runArgs = &RunArgs{}
-	runCmd.Flags().StringVarP(&runArgs.CheckerType, "checker", "c", "", "specify checker type")
-	runCmd.Flags().StringVar(&runArgs.CheckerArgs, "args", "", "specify checker args when you want run specify checker")
+	runCmd.Flags().StringVarP(&runArgs.CheckerType, "checker", "c", "", "specify checker type, run preflight list to see all types")
+	runCmd.Flags().StringVar(&runArgs.CheckerArgs, "args", "", "specify checker args separated by comma, one checker is built for each arg")
+	runCmd.Flags().StringVarP(&runArgs.PlanFile, "file", "f", "", "specify the check plan file written in YAML or JSON")
 	runCmd.Flags().BoolVar(&runArgs.NotTolerable, "not-tolerable", false, "specify runner option whether return immediately when an error is reported.")
+	runCmd.Flags().StringVar(&runArgs.FailOn, "fail-on", checker.InfoLevel, "specify the lowest level of failed checker which makes preflight exit with non-zero code, and makes --not-tolerable return")
+	runCmd.Flags().StringVarP(&runArgs.Output, "output", "o", "json", "specify the output format, one of "+strings.Join(result.GetAllFormats(), "|"))
+	runCmd.Flags().StringVar(&runArgs.ReportFile, "report-file", "", "specify the file to write the report to instead of stdout")
+	runCmd.Flags().StringVar(&runArgs.MetricsFile, "metrics-file", "", "specify the file to export the result as Prometheus metrics, like the textfile of node_exporter")
+	runCmd.Flags().DurationVar(&runArgs.CheckTimeout, "check-timeout", 0, "specify the timeout of each checker, like 30s, zero means no limit")
+	runCmd.Flags().DurationVar(&runArgs.Timeout, "timeout", 0, "specify the timeout of the whole run, like 5m, zero means no limit")
+	runCmd.Flags().IntVar(&runArgs.Concurrency, "concurrency", 1, "specify the max number of checkers run at the same time")
 	runCmd.Flags().StringSliceVar(&runArgs.Skip, "skip", []string{}, "run all checkers expect this checker")
 	runCmd.Flags().StringSliceVar(&runArgs.Ignore, "ignore-errors", []string{}, "specify checker type and run all checkers ignore this checker error")
 	rootCmd.AddCommand(runCmd)
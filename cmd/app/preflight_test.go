@@ -0,0 +1,40 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	"preflight/checker"
+)
+
+func TestBuildCheckersSystemdUnitArgs(t *testing.T) {
+	origin := runArgs
+	t.Cleanup(func() { runArgs = origin })
+	runArgs = &RunArgs{CheckerType: "systemdunit", CheckerArgs: "chronyd|ntpd:active=active file=enabled uptime>=5m,firewalld:active=inactive"}
+
+	checks, err := buildCheckers(nil)
+	if err != nil {
+		t.Fatalf("failed to build checkers: %v", err)
+	}
+	if len(checks) != 2 {
+		t.Fatalf("expected a checker for each of --args, but got %+v", checks)
+	}
+	expected := checker.SystemdUnitRule{Units: []string{"chronyd", "ntpd"}, ActiveState: []string{"active"}, UnitFileState: []string{"enabled"}, MinUptime: "5m"}
+	if s, ok := checks[0].(checker.SystemdUnitCheck); !ok || len(s.Rules) != 1 || !reflect.DeepEqual(s.Rules[0], expected) {
+		t.Errorf("expected the conditions of chronyd|ntpd kept in one rule, but got %+v", checks[0])
+	}
+}
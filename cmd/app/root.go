@@ -28,6 +28,9 @@ var rootCmd = &cobra.Command{
 	Long:  ``,
 }
 
+// exitCode is set by the command which exits with non-zero code without error, like preflight run.
+var exitCode int
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -35,4 +38,5 @@ func Execute() {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
+	os.Exit(exitCode)
 }
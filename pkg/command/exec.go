@@ -17,6 +17,7 @@ package command
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -35,6 +36,8 @@ type HostCmd struct {
 	stdin   io.Reader
 	stdout  io.Writer
 	stderr  io.Writer
+	silent  bool
+	ctx     context.Context
 }
 
 // NewHostCmd returns a new HostCmd to run a command on a host
@@ -77,6 +80,18 @@ func (c *HostCmd) Stdin(in io.Reader) *HostCmd {
 	return c
 }
 
+// Silent disables printing the command before running it
+func (c *HostCmd) Silent() *HostCmd {
+	c.silent = true
+	return c
+}
+
+// Context sets the context which kills the inner command when it is done
+func (c *HostCmd) Context(ctx context.Context) *HostCmd {
+	c.ctx = ctx
+	return c
+}
+
 // SetEnv sets env variables to be used when running the inner command
 func (c *HostCmd) SetEnv(env ...string) *HostCmd {
 	c.env = env
@@ -85,7 +100,11 @@ func (c *HostCmd) SetEnv(env ...string) *HostCmd {
 
 func (c *HostCmd) runInnnerCommand() error {
 	// create the commands
-	cmd := exec.Command(c.command, c.args...)
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cmd := exec.CommandContext(ctx, c.command, c.args...)
 
 	// Redirect flows if requested
 	if c.stdin != nil {
@@ -103,6 +122,8 @@ func (c *HostCmd) runInnnerCommand() error {
 	}
 
 	// eventually print the proxy command, and then run the command to be executed
-	fmt.Printf("Running: %s", strings.Join(cmd.Args, " "))
+	if !c.silent {
+		fmt.Printf("Running: %s", strings.Join(cmd.Args, " "))
+	}
 	return cmd.Run()
 }
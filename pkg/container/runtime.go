@@ -0,0 +1,254 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package container query the container runtimes through the HTTP APIs served on their unix sockets,
+// and the clients ctr and crictl for the gRPC ones.
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os/exec"
+	"preflight/pkg/command"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// names of container runtimes.
+const (
+	Docker     = "docker"
+	Containerd = "containerd"
+	Crio       = "crio"
+)
+
+// cgroup drivers of kubelet and container runtimes.
+const (
+	CgroupDriverSystemd  = "systemd"
+	CgroupDriverCgroupfs = "cgroupfs"
+)
+
+// clients of the gRPC APIs of containerd and the CRI runtimes.
+const (
+	Ctr    = "ctr"
+	Crictl = "crictl"
+)
+
+// Runtimes are the supported container runtimes in order.
+var Runtimes = []string{Docker, Containerd, Crio}
+
+// DefaultSockets are the default unix sockets of container runtimes.
+var DefaultSockets = map[string]string{
+	Docker:     "/var/run/docker.sock",
+	Containerd: "/run/containerd/containerd.sock",
+	Crio:       "/var/run/crio/crio.sock",
+}
+
+// Info of container runtime, the unknown ones are empty.
+type Info struct {
+	Name          string
+	Version       string
+	StorageDriver string
+	CgroupDriver  string
+	RootDir       string
+	// CRI is true if the runtime serves the CRI API for kubelet, it is false for the containerd
+	// shipped with docker whose cri plugin is disabled.
+	CRI bool
+}
+
+// GetInfo query the info of the container runtime through its socket.
+func GetInfo(ctx context.Context, runtime, socket string) (Info, error) {
+	switch runtime {
+	case Docker:
+		return dockerInfo(ctx, socket)
+	case Containerd:
+		return containerdInfo(ctx, socket)
+	case Crio:
+		return crioInfo(ctx, socket)
+	default:
+		return Info{}, errors.Errorf("unknown container runtime %s", runtime)
+	}
+}
+
+// dockerInfo query GET /version and GET /info of docker engine API.
+func dockerInfo(ctx context.Context, socket string) (Info, error) {
+	info := Info{Name: Docker}
+	var version struct {
+		Version string
+	}
+	if err := getJSON(ctx, socket, "/version", &version); err != nil {
+		return info, err
+	}
+	info.Version = version.Version
+
+	var system struct {
+		Driver        string
+		CgroupDriver  string
+		DockerRootDir string
+	}
+	if err := getJSON(ctx, socket, "/info", &system); err != nil {
+		return info, err
+	}
+	info.StorageDriver, info.CgroupDriver, info.RootDir = system.Driver, system.CgroupDriver, system.DockerRootDir
+	return info, nil
+}
+
+// containerdInfo query the version by ctr, and the status of CRI plugin by crictl for its config.
+func containerdInfo(ctx context.Context, socket string) (Info, error) {
+	info := Info{Name: Containerd}
+	lines, err := run(ctx, Ctr, "--address", socket, "version")
+	if err != nil {
+		return info, err
+	}
+	// the version of server follows the one of client.
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "Server:" {
+			info.Version = field(lines[i:], "Version")
+		}
+	}
+	if info.Version == "" {
+		return info, errors.Errorf("version of containerd is not found in the output of ctr version: %s", strings.Join(lines, "\n"))
+	}
+
+	// kubeadm requires crictl on the nodes, the containerd without it is only used by docker.
+	if _, err := exec.LookPath(Crictl); err != nil {
+		return info, nil
+	}
+	status, err := criInfo(ctx, socket)
+	if err != nil {
+		if ctx.Err() != nil {
+			return info, ctx.Err()
+		}
+		// the cri plugin is disabled.
+		return info, nil
+	}
+	info.CRI = true
+
+	var config struct {
+		Containerd struct {
+			Snapshotter        string `json:"snapshotter"`
+			DefaultRuntimeName string `json:"defaultRuntimeName"`
+			Runtimes           map[string]struct {
+				Options map[string]interface{} `json:"options"`
+			} `json:"runtimes"`
+		} `json:"containerd"`
+		ContainerdRootDir string `json:"containerdRootDir"`
+	}
+	if err := json.Unmarshal(status["config"], &config); err != nil {
+		return info, errors.Wrap(err, "failed to parse the config of containerd cri plugin")
+	}
+	info.StorageDriver, info.RootDir = config.Containerd.Snapshotter, config.ContainerdRootDir
+	if runtime, ok := config.Containerd.Runtimes[config.Containerd.DefaultRuntimeName]; ok {
+		info.CgroupDriver = CgroupDriverCgroupfs
+		if systemd, _ := runtime.Options["SystemdCgroup"].(bool); systemd {
+			info.CgroupDriver = CgroupDriverSystemd
+		}
+	}
+	return info, nil
+}
+
+// crioInfo query the version by crictl, and GET /info of CRI-O served on the same socket.
+func crioInfo(ctx context.Context, socket string) (Info, error) {
+	info := Info{Name: Crio, CRI: true}
+	lines, err := run(ctx, Crictl, "--runtime-endpoint", "unix://"+socket, "version")
+	if err != nil {
+		return info, err
+	}
+	if info.Version = field(lines, "RuntimeVersion"); info.Version == "" {
+		return info, errors.Errorf("version of crio is not found in the output of crictl version: %s", strings.Join(lines, "\n"))
+	}
+
+	var crio struct {
+		StorageDriver string `json:"storage_driver"`
+		StorageRoot   string `json:"storage_root"`
+		CgroupDriver  string `json:"cgroup_driver"`
+	}
+	if err := getJSON(ctx, socket, "/info", &crio); err != nil {
+		return info, err
+	}
+	info.StorageDriver, info.CgroupDriver, info.RootDir = crio.StorageDriver, crio.CgroupDriver, crio.StorageRoot
+	return info, nil
+}
+
+// criInfo return the verbose info of CRI runtime status, like config of containerd cri plugin.
+func criInfo(ctx context.Context, socket string) (map[string]json.RawMessage, error) {
+	lines, err := run(ctx, Crictl, "--runtime-endpoint", "unix://"+socket, "info")
+	if err != nil {
+		return nil, err
+	}
+	// the warnings logged to stderr are captured before the output.
+	for i, line := range lines {
+		if strings.HasPrefix(line, "{") {
+			lines = lines[i:]
+			break
+		}
+	}
+	var info map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(strings.Join(lines, "\n")), &info); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the output of crictl info")
+	}
+	return info, nil
+}
+
+// run the client and return its output lines.
+func run(ctx context.Context, client string, args ...string) ([]string, error) {
+	lines, err := command.NewHostCmd(client, args...).Silent().Context(ctx).RunAndCapture()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, errors.Wrapf(err, "failed to run %s %s: %s", client, strings.Join(args, " "), strings.TrimSpace(strings.Join(lines, "\n")))
+	}
+	return lines, nil
+}
+
+// field return the value of the first line like "key: value", empty if not found.
+func field(lines []string, key string) string {
+	for _, line := range lines {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) == key {
+			return strings.TrimSpace(kv[1])
+		}
+	}
+	return ""
+}
+
+// getJSON request the HTTP API served on the unix socket, and decode the JSON response to v.
+func getJSON(ctx context.Context, socket, path string, v interface{}) error {
+	client := http.Client{Transport: &http.Transport{
+		DisableKeepAlives: true,
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("GET %s: %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errors.Wrapf(err, "failed to decode the response of %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// ServerAddress return the address of nameserver with the default port 53 if it is omitted.
+func ServerAddress(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(server, "53")
+}
+
+// Query send the recursive query of name and type to the nameserver over UDP, and retry over TCP if the response
+// is truncated.
+func Query(ctx context.Context, server, name string, qtype uint16) (Message, error) {
+	query := Message{
+		ID:               uint16(rand.Intn(1 << 16)),
+		RecursionDesired: true,
+		Questions:        []Question{{Name: name, Type: qtype, Class: ClassINET}},
+	}
+	msg, err := query.Pack()
+	if err != nil {
+		return Message{}, err
+	}
+	response, err := exchange(ctx, "udp", ServerAddress(server), query.ID, msg)
+	if err == nil && response.Truncated {
+		response, err = exchange(ctx, "tcp", ServerAddress(server), query.ID, msg)
+	}
+	return response, err
+}
+
+func exchange(ctx context.Context, network, address string, id uint16, msg []byte) (Message, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return Message{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if network == "tcp" {
+		if _, err := conn.Write(append([]byte{byte(len(msg) >> 8), byte(len(msg))}, msg...)); err != nil {
+			return Message{}, err
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return Message{}, err
+		}
+		buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return Message{}, err
+		}
+		return parseResponse(buf, id)
+	}
+
+	if _, err := conn.Write(msg); err != nil {
+		return Message{}, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return Message{}, err
+		}
+		// skip the stale responses of former queries on the same port.
+		if response, err := parseResponse(buf[:n], id); err == nil {
+			return response, nil
+		}
+	}
+}
+
+func parseResponse(buf []byte, id uint16) (Message, error) {
+	m, err := ParseMessage(buf)
+	if err != nil {
+		return Message{}, err
+	}
+	if !m.Response || m.ID != id {
+		return Message{}, errors.Errorf("unexpected DNS message %d", m.ID)
+	}
+	return m, nil
+}
@@ -0,0 +1,271 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Types of the resource records, see RFC 1035 and RFC 3596.
+const (
+	TypeA     uint16 = 1
+	TypeCNAME uint16 = 5
+	TypeAAAA  uint16 = 28
+)
+
+// ClassINET is the only class supported.
+const ClassINET uint16 = 1
+
+// Response codes, see RFC 1035.
+const (
+	RcodeSuccess        = 0
+	RcodeFormatError    = 1
+	RcodeServerFailure  = 2
+	RcodeNameError      = 3
+	RcodeNotImplemented = 4
+	RcodeRefused        = 5
+)
+
+var rcodeNames = map[int]string{
+	RcodeSuccess:        "NOERROR",
+	RcodeFormatError:    "FORMERR",
+	RcodeServerFailure:  "SERVFAIL",
+	RcodeNameError:      "NXDOMAIN",
+	RcodeNotImplemented: "NOTIMP",
+	RcodeRefused:        "REFUSED",
+}
+
+// RcodeName return the name of response code like NXDOMAIN.
+func RcodeName(rcode int) string {
+	if name, ok := rcodeNames[rcode]; ok {
+		return name
+	}
+	return "RCODE" + strconv.Itoa(rcode)
+}
+
+const (
+	flagResponse           = 1 << 15
+	flagTruncated          = 1 << 9
+	flagRecursionDesired   = 1 << 8
+	flagRecursionAvailable = 1 << 7
+)
+
+// Message is a DNS message with the questions and answers, the authority and additional records are ignored.
+type Message struct {
+	ID                 uint16
+	Response           bool
+	Truncated          bool
+	RecursionDesired   bool
+	RecursionAvailable bool
+	Rcode              int
+	Questions          []Question
+	Answers            []Resource
+}
+
+// Question is the name and type queried.
+type Question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+// Resource is an answer, IP is set for A and AAAA records, and Target is set for CNAME records.
+type Resource struct {
+	Name   string
+	Type   uint16
+	Class  uint16
+	TTL    uint32
+	IP     net.IP
+	Target string
+}
+
+// Pack encode the message, the names are not compressed.
+func (m Message) Pack() ([]byte, error) {
+	b := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(b[0:], m.ID)
+	flags := uint16(m.Rcode & 0xf)
+	for _, f := range []struct {
+		set  bool
+		flag uint16
+	}{
+		{m.Response, flagResponse},
+		{m.Truncated, flagTruncated},
+		{m.RecursionDesired, flagRecursionDesired},
+		{m.RecursionAvailable, flagRecursionAvailable},
+	} {
+		if f.set {
+			flags |= f.flag
+		}
+	}
+	binary.BigEndian.PutUint16(b[2:], flags)
+	binary.BigEndian.PutUint16(b[4:], uint16(len(m.Questions)))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(m.Answers)))
+
+	var err error
+	for _, q := range m.Questions {
+		if b, err = appendName(b, q.Name); err != nil {
+			return nil, err
+		}
+		b = appendUint16(b, q.Type)
+		b = appendUint16(b, q.Class)
+	}
+	for _, r := range m.Answers {
+		if b, err = appendName(b, r.Name); err != nil {
+			return nil, err
+		}
+		b = appendUint16(b, r.Type)
+		b = appendUint16(b, r.Class)
+		b = append(b, byte(r.TTL>>24), byte(r.TTL>>16), byte(r.TTL>>8), byte(r.TTL))
+		var data []byte
+		switch r.Type {
+		case TypeA:
+			data = r.IP.To4()
+		case TypeAAAA:
+			data = r.IP.To16()
+		case TypeCNAME:
+			if data, err = appendName(nil, r.Target); err != nil {
+				return nil, err
+			}
+		}
+		if data == nil {
+			return nil, errors.Errorf("invalid data of resource %s type %d", r.Name, r.Type)
+		}
+		b = appendUint16(b, uint16(len(data)))
+		b = append(b, data...)
+	}
+	return b, nil
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// appendName append the name like kubernetes.io as the labels, the trailing dot is optional.
+func appendName(b []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if label == "" || len(label) > 63 {
+				return nil, errors.Errorf("invalid name %q", name)
+			}
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	return append(b, 0), nil
+}
+
+// ParseMessage decode the DNS message, the records other than A, AAAA and CNAME in answers are skipped.
+func ParseMessage(msg []byte) (Message, error) {
+	if len(msg) < 12 {
+		return Message{}, errors.New("short DNS message")
+	}
+	flags := binary.BigEndian.Uint16(msg[2:])
+	m := Message{
+		ID:                 binary.BigEndian.Uint16(msg[0:]),
+		Response:           flags&flagResponse != 0,
+		Truncated:          flags&flagTruncated != 0,
+		RecursionDesired:   flags&flagRecursionDesired != 0,
+		RecursionAvailable: flags&flagRecursionAvailable != 0,
+		Rcode:              int(flags & 0xf),
+	}
+	questions, answers := int(binary.BigEndian.Uint16(msg[4:])), int(binary.BigEndian.Uint16(msg[6:]))
+
+	off := 12
+	for i := 0; i < questions; i++ {
+		name, next, err := readName(msg, off)
+		if err != nil {
+			return Message{}, err
+		}
+		if next+4 > len(msg) {
+			return Message{}, errors.New("short DNS question")
+		}
+		m.Questions = append(m.Questions, Question{Name: name, Type: binary.BigEndian.Uint16(msg[next:]), Class: binary.BigEndian.Uint16(msg[next+2:])})
+		off = next + 4
+	}
+	for i := 0; i < answers; i++ {
+		name, next, err := readName(msg, off)
+		if err != nil {
+			return Message{}, err
+		}
+		if next+10 > len(msg) {
+			return Message{}, errors.New("short DNS resource")
+		}
+		r := Resource{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(msg[next:]),
+			Class: binary.BigEndian.Uint16(msg[next+2:]),
+			TTL:   binary.BigEndian.Uint32(msg[next+4:]),
+		}
+		length := int(binary.BigEndian.Uint16(msg[next+8:]))
+		start := next + 10
+		if start+length > len(msg) {
+			return Message{}, errors.New("short DNS resource data")
+		}
+		data := msg[start : start+length]
+		off = start + length
+
+		switch {
+		case r.Type == TypeA && length == net.IPv4len, r.Type == TypeAAAA && length == net.IPv6len:
+			r.IP = append(net.IP(nil), data...)
+		case r.Type == TypeCNAME:
+			if r.Target, _, err = readName(msg, start); err != nil {
+				return Message{}, err
+			}
+		default:
+			continue
+		}
+		m.Answers = append(m.Answers, r)
+	}
+	return m, nil
+}
+
+// readName read the name at offset with the compression pointers, it returns the offset after the name.
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for hops := 0; ; hops++ {
+		if off >= len(msg) || hops > 127 {
+			return "", 0, errors.New("invalid DNS name")
+		}
+		length := int(msg[off])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case length&0xc0 == 0xc0:
+			if off+1 >= len(msg) {
+				return "", 0, errors.New("invalid DNS name pointer")
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+		default:
+			if off+1+length > len(msg) {
+				return "", 0, errors.New("invalid DNS label")
+			}
+			labels = append(labels, string(msg[off+1:off+1+length]))
+			off += 1 + length
+		}
+	}
+}
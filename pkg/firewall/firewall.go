@@ -0,0 +1,136 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package firewall evaluates whether the inbound packets are accepted by the INPUT rules of iptables and nftables,
+// the rulesets are parsed from the output of iptables-save and nft -j list ruleset, so that they could be captured.
+package firewall
+
+import (
+	"net"
+	"strings"
+)
+
+// Packet is the first packet of a new inbound connection.
+type Packet struct {
+	// Protocol is tcp or udp.
+	Protocol string
+	// Port is the destination port.
+	Port int
+	// Source is the network of source addresses, a rule matches the source only if it matches all the addresses.
+	Source *net.IPNet
+	// Interface is the inbound interface, empty means any interface other than lo.
+	Interface string
+}
+
+// IPv6 return true if the source of packet is IPv6.
+func (p Packet) IPv6() bool {
+	return p.Source.IP.To4() == nil
+}
+
+// loopback return true if the packet comes from lo.
+func (p Packet) loopback() bool {
+	if p.Interface != "" {
+		return p.Interface == "lo"
+	}
+	return p.Source.IP.IsLoopback()
+}
+
+// matchInterface return true if the inbound interface of packet matches the name, which ends with + or *
+// matches the interfaces with the prefix. The unknown interface of packet only matches the name matching all
+// the interfaces, the rules with other names except lo are possibly matching, see interfaceUnknown.
+func (p Packet) matchInterface(name string) bool {
+	if p.loopback() {
+		return matchWildcard(name, "lo")
+	}
+	if p.Interface == "" {
+		return name == "+" || name == "*"
+	}
+	return matchWildcard(name, p.Interface)
+}
+
+// interfaceUnknown return true if the inbound interface of packet is unknown and the name is a specific one,
+// the rule matching it, like -i eth0 or ! -i eth0, may or may not match the packet.
+func (p Packet) interfaceUnknown(name string) bool {
+	return p.Interface == "" && !p.loopback() && name != "+" && name != "*" && name != "lo"
+}
+
+func matchWildcard(pattern, name string) bool {
+	if strings.HasSuffix(pattern, "+") || strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(name, pattern[:len(pattern)-1])
+	}
+	return pattern == name
+}
+
+// containsNet return true if all addresses of n are in the network.
+func containsNet(network, n *net.IPNet) bool {
+	networkOnes, networkBits := network.Mask.Size()
+	ones, bits := n.Mask.Size()
+	return networkBits == bits && networkOnes <= ones && network.Contains(n.IP)
+}
+
+// overlapNet return true if the networks have any address in common.
+func overlapNet(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// ParseNet parse the CIDR or IP address, the IP address is a network of itself.
+func ParseNet(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, &net.ParseError{Type: "IP address", Text: s}
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+	if ip4 := n.IP.To4(); ip4 != nil {
+		n.IP = ip4
+	}
+	return n, nil
+}
+
+// Verdict of the packet evaluated by a ruleset.
+type Verdict struct {
+	// Accepted is true if the packet is accepted.
+	Accepted bool
+	// Action is accept, drop or reject.
+	Action string
+	// Rule is the rule which accepts or drops the packet, or the policy of chain if no rule matched.
+	Rule string
+	// Skipped is the rules not evaluated since their matches are not supported.
+	Skipped []string
+	// Possible is the rules not applied since they match specific interfaces, while the interface of packet is unknown.
+	Possible []string
+}
+
+// Ruleset evaluates the packet on INPUT.
+type Ruleset interface {
+	Evaluate(p Packet) Verdict
+}
+
+// actions of verdict.
+const (
+	ActionAccept = "accept"
+	ActionDrop   = "drop"
+	ActionReject = "reject"
+)
+
+// maxJumps limits the depth of chains jumped, iptables and nftables limit it too.
+const maxJumps = 64
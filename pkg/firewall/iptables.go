@@ -0,0 +1,388 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firewall
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Iptables is the filter table parsed from the output of iptables-save or ip6tables-save.
+type Iptables struct {
+	chains map[string]*iptablesChain
+	// nftables is true if the tables are managed by iptables-nft, which are listed by nft too.
+	nftables bool
+}
+
+type iptablesChain struct {
+	policy string
+	rules  []*iptablesRule
+}
+
+// iptablesRule is a rule appended to chain, the negated matches are inverted.
+type iptablesRule struct {
+	raw string
+
+	protocol    string
+	protocolNeg bool
+	sources     []*net.IPNet
+	sourceNeg   bool
+	iface       string
+	ifaceNeg    bool
+	ports       []portRange
+	portNeg     bool
+	states      []string
+	stateNeg    bool
+	syn         bool
+	synNeg      bool
+	// never is true if the rule never matches the inbound packet, like --dst-type BROADCAST.
+	never bool
+	// unsupported are the options which could not be evaluated, the rule is skipped if any.
+	unsupported []string
+
+	target string
+	goto_  bool
+}
+
+type portRange struct {
+	from, to int
+}
+
+func (r portRange) contains(port int) bool {
+	return r.from <= port && port <= r.to
+}
+
+// ParseIptablesSave parse the filter table of iptables-save output, the other tables are ignored.
+func ParseIptablesSave(r io.Reader) (*Iptables, error) {
+	t := &Iptables{chains: make(map[string]*iptablesChain)}
+	table := ""
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#"):
+			// # Generated by iptables-save v1.8.7 (nf_tables) on Mon Jan  1 00:00:00 2024
+			t.nftables = t.nftables || strings.Contains(line, "(nf_tables)")
+		case line == "":
+		case strings.HasPrefix(line, "*"):
+			table = line[1:]
+		case line == "COMMIT":
+			table = ""
+		case table != "filter":
+		case strings.HasPrefix(line, ":"):
+			// :INPUT ACCEPT [0:0]
+			fields := strings.Fields(line[1:])
+			if len(fields) < 2 {
+				return nil, errors.Errorf("invalid chain of iptables: %s", line)
+			}
+			t.chain(fields[0]).policy = fields[1]
+		case strings.HasPrefix(line, "-A "):
+			tokens, err := splitArgs(line)
+			if err != nil {
+				return nil, err
+			}
+			if len(tokens) < 2 {
+				return nil, errors.Errorf("invalid rule of iptables: %s", line)
+			}
+			rule, err := parseIptablesRule(line, tokens[2:])
+			if err != nil {
+				return nil, err
+			}
+			chain := t.chain(tokens[1])
+			chain.rules = append(chain.rules, rule)
+		}
+	}
+	return t, scanner.Err()
+}
+
+// NFTables return true if the tables are managed by iptables-nft, the filter table should be ignored by Nftables.
+func (t *Iptables) NFTables() bool {
+	return t.nftables
+}
+
+func (t *Iptables) chain(name string) *iptablesChain {
+	c, ok := t.chains[name]
+	if !ok {
+		c = &iptablesChain{}
+		t.chains[name] = c
+	}
+	return c
+}
+
+// splitArgs split the line by space, the quoted args like --comment "kube-system/kube-dns:dns" are unquoted.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	quoted, escaped, inArg := false, false, false
+	for _, c := range line {
+		switch {
+		case escaped:
+			arg.WriteRune(c)
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted, inArg = !quoted, true
+		case c == ' ' && !quoted:
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quoted {
+		return nil, errors.Errorf("unterminated quote of iptables rule: %s", line)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+func parseIptablesRule(raw string, tokens []string) (*iptablesRule, error) {
+	r := &iptablesRule{raw: raw}
+	negate := false
+	value := func(i int) (string, error) {
+		if i+1 >= len(tokens) {
+			return "", errors.Errorf("missing value of %s in iptables rule: %s", tokens[i], raw)
+		}
+		return tokens[i+1], nil
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		option := tokens[i]
+		if option == "!" {
+			negate = true
+			continue
+		}
+
+		var v string
+		var err error
+		switch option {
+		case "-m", "--match", "-p", "--protocol", "-s", "--source", "-i", "--in-interface", "-o", "--out-interface",
+			"--dport", "--destination-port", "--dports", "--destination-ports", "--ctstate", "--state", "--comment",
+			"--dst-type", "-j", "--jump", "-g", "--goto", "--limit", "--limit-burst", "--icmp-type", "--icmpv6-type":
+			if v, err = value(i); err != nil {
+				return nil, err
+			}
+			i++
+		}
+
+		switch option {
+		case "-m", "--match", "--comment", "--limit", "--limit-burst", "--icmp-type", "--icmpv6-type", "-o", "--out-interface":
+			// the modules are implied by the options, the others do not affect the first packet on INPUT.
+		case "-p", "--protocol":
+			r.protocol, r.protocolNeg = protocolName(v), negate
+		case "-s", "--source":
+			for _, s := range strings.Split(v, ",") {
+				n, err := ParseNet(s)
+				if err != nil {
+					return nil, errors.Wrapf(err, "invalid source of iptables rule: %s", raw)
+				}
+				r.sources = append(r.sources, n)
+			}
+			r.sourceNeg = negate
+		case "-i", "--in-interface":
+			r.iface, r.ifaceNeg = v, negate
+		case "--dport", "--destination-port", "--dports", "--destination-ports":
+			if r.ports, err = parsePortRanges(v, ":"); err != nil {
+				return nil, errors.Wrapf(err, "invalid port of iptables rule: %s", raw)
+			}
+			r.portNeg = negate
+		case "--ctstate", "--state":
+			r.states, r.stateNeg = strings.Split(strings.ToUpper(v), ","), negate
+		case "--syn":
+			r.syn, r.synNeg = true, negate
+		case "--dst-type":
+			// the destination of inbound packet is a local unicast address.
+			r.never = r.never || (v == "LOCAL") == negate
+		case "-j", "--jump", "-g", "--goto":
+			r.target, r.goto_ = v, option == "-g" || option == "--goto"
+			// the rest are the options of target, like --reject-with.
+			return r, nil
+		default:
+			r.unsupported = append(r.unsupported, option)
+			// skip the value of unsupported option
+			for i+1 < len(tokens) && !strings.HasPrefix(tokens[i+1], "-") && tokens[i+1] != "!" {
+				i++
+			}
+		}
+		negate = false
+	}
+	return r, nil
+}
+
+// protocolName return the name of protocol number, like tcp of 6.
+func protocolName(p string) string {
+	switch strings.ToLower(p) {
+	case "6":
+		return "tcp"
+	case "17":
+		return "udp"
+	case "0", "all":
+		return ""
+	default:
+		return strings.ToLower(p)
+	}
+}
+
+// parsePortRanges parse the ports separated by comma, the range is separated by sep, like 22,10250:10252.
+func parsePortRanges(s, sep string) ([]portRange, error) {
+	var ranges []portRange
+	for _, p := range strings.Split(s, ",") {
+		bounds := strings.SplitN(p, sep, 2)
+		from, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, errors.Errorf("invalid port %q", p)
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, errors.Errorf("invalid port %q", p)
+			}
+		}
+		ranges = append(ranges, portRange{from: from, to: to})
+	}
+	return ranges, nil
+}
+
+// match return whether the rule matches the packet, and false if the rule is not supported.
+// possible is true if the rule matches except the interface, which is unknown.
+func (r *iptablesRule) match(p Packet) (matched, possible bool) {
+	if len(r.unsupported) > 0 || r.never {
+		return false, false
+	}
+	if r.protocol != "" && (r.protocol == p.Protocol) == r.protocolNeg {
+		return false, false
+	}
+	if len(r.sources) > 0 {
+		matched := false
+		for _, s := range r.sources {
+			if r.sourceNeg {
+				matched = matched || overlapNet(s, p.Source)
+			} else {
+				matched = matched || containsNet(s, p.Source)
+			}
+		}
+		if matched == r.sourceNeg {
+			return false, false
+		}
+	}
+	if r.iface != "" {
+		if p.interfaceUnknown(r.iface) {
+			possible = true
+		} else if p.matchInterface(r.iface) == r.ifaceNeg {
+			return false, false
+		}
+	}
+	if len(r.ports) > 0 {
+		matched := false
+		for _, pr := range r.ports {
+			matched = matched || pr.contains(p.Port)
+		}
+		if matched == r.portNeg {
+			return false, false
+		}
+	}
+	if len(r.states) > 0 {
+		matched := false
+		for _, s := range r.states {
+			matched = matched || s == "NEW"
+		}
+		if matched == r.stateNeg {
+			return false, false
+		}
+	}
+	if r.syn && (p.Protocol == "tcp") == r.synNeg {
+		return false, false
+	}
+	return !possible, possible
+}
+
+// decisive return true if the target of rule could decide the verdict, that is not the ones like LOG.
+func (r *iptablesRule) decisive(t *Iptables) bool {
+	switch r.target {
+	case "ACCEPT", "DROP", "REJECT", "RETURN":
+		return true
+	}
+	_, ok := t.chains[r.target]
+	return ok
+}
+
+// Evaluate the packet on the INPUT chain of filter table.
+func (t *Iptables) Evaluate(p Packet) Verdict {
+	input, ok := t.chains["INPUT"]
+	if !ok {
+		return Verdict{Accepted: true, Action: ActionAccept, Rule: "no INPUT chain"}
+	}
+	var v Verdict
+	if t.walk(input, p, &v, 0) {
+		return v
+	}
+	v.Action = strings.ToLower(input.policy)
+	v.Accepted = v.Action == ActionAccept
+	v.Rule = fmt.Sprintf("policy %s of chain INPUT", input.policy)
+	return v
+}
+
+// walk the rules of chain, return true if the packet is accepted, dropped or rejected.
+func (t *Iptables) walk(c *iptablesChain, p Packet, v *Verdict, depth int) bool {
+	if depth > maxJumps {
+		return false
+	}
+	for _, r := range c.rules {
+		if len(r.unsupported) > 0 {
+			v.Skipped = append(v.Skipped, r.raw)
+			continue
+		}
+		matched, possible := r.match(p)
+		if possible && r.decisive(t) {
+			v.Possible = append(v.Possible, r.raw)
+			continue
+		}
+		if !matched {
+			continue
+		}
+		switch r.target {
+		case "ACCEPT", "DROP", "REJECT":
+			v.Action, v.Accepted, v.Rule = strings.ToLower(r.target), r.target == "ACCEPT", r.raw
+			return true
+		case "RETURN":
+			return false
+		}
+		if next, ok := t.chains[r.target]; ok {
+			if t.walk(next, p, v, depth+1) {
+				return true
+			}
+			if r.goto_ {
+				// the chain gone to returns to the caller of current chain.
+				return false
+			}
+		}
+		// the targets like LOG and MARK continue.
+	}
+	return false
+}
@@ -0,0 +1,520 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firewall
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Nftables is the ruleset parsed from the output of nft -j list ruleset.
+type Nftables struct {
+	chains  map[string]*nftChain
+	sets    map[string][]interface{}
+	ignored map[string]bool
+}
+
+type nftChain struct {
+	Family string `json:"family"`
+	Table  string `json:"table"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Hook   string `json:"hook"`
+	Prio   int    `json:"prio"`
+	Policy string `json:"policy"`
+	rules  []*nftRule
+}
+
+func (c *nftChain) String() string {
+	return fmt.Sprintf("%s %s %s", c.Family, c.Table, c.Name)
+}
+
+type nftRule struct {
+	Family  string                       `json:"family"`
+	Table   string                       `json:"table"`
+	Chain   string                       `json:"chain"`
+	Handle  int                          `json:"handle"`
+	Comment string                       `json:"comment"`
+	Expr    []map[string]json.RawMessage `json:"expr"`
+}
+
+func (r *nftRule) String() string {
+	s := fmt.Sprintf("%s %s %s handle %d", r.Family, r.Table, r.Chain, r.Handle)
+	if r.Comment != "" {
+		s += fmt.Sprintf(" comment %q", r.Comment)
+	}
+	return s
+}
+
+type nftSet struct {
+	Family string        `json:"family"`
+	Table  string        `json:"table"`
+	Name   string        `json:"name"`
+	Elem   []interface{} `json:"elem"`
+}
+
+func nftKey(family, table, name string) string {
+	return family + " " + table + " " + name
+}
+
+// ParseNftables parse the JSON output of nft -j list ruleset.
+func ParseNftables(data []byte) (*Nftables, error) {
+	var doc struct {
+		Nftables []map[string]json.RawMessage `json:"nftables"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, "invalid nftables ruleset")
+	}
+
+	t := &Nftables{chains: make(map[string]*nftChain), sets: make(map[string][]interface{}), ignored: make(map[string]bool)}
+	var rules []*nftRule
+	for _, item := range doc.Nftables {
+		for kind, raw := range item {
+			var err error
+			switch kind {
+			case "chain":
+				c := &nftChain{}
+				if err = json.Unmarshal(raw, c); err == nil {
+					t.chains[nftKey(c.Family, c.Table, c.Name)] = c
+				}
+			case "rule":
+				r := &nftRule{}
+				if err = json.Unmarshal(raw, r); err == nil {
+					rules = append(rules, r)
+				}
+			case "set", "map":
+				s := &nftSet{}
+				if err = json.Unmarshal(raw, s); err == nil {
+					t.sets[nftKey(s.Family, s.Table, s.Name)] = s.Elem
+				}
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid nftables %s", kind)
+			}
+		}
+	}
+	for _, r := range rules {
+		c, ok := t.chains[nftKey(r.Family, r.Table, r.Chain)]
+		if !ok {
+			return nil, errors.Errorf("chain of nftables rule %s not found", r)
+		}
+		c.rules = append(c.rules, r)
+	}
+	return t, nil
+}
+
+// IgnoreTable ignore the table in evaluation, such as the ones managed by iptables-nft which are evaluated by Iptables.
+func (t *Nftables) IgnoreTable(family, table string) {
+	t.ignored[family+" "+table] = true
+}
+
+// Evaluate the packet on the filter chains hooked on input in order of priority, the packet accepted by
+// a chain continues to the next one, and it is accepted only if all of them accept it.
+func (t *Nftables) Evaluate(p Packet) Verdict {
+	family := "ip"
+	if p.IPv6() {
+		family = "ip6"
+	}
+	var chains []*nftChain
+	for _, c := range t.chains {
+		if c.Hook == "input" && c.Type == "filter" && (c.Family == "inet" || c.Family == family) && !t.ignored[c.Family+" "+c.Table] {
+			chains = append(chains, c)
+		}
+	}
+	sort.Slice(chains, func(i, j int) bool {
+		if chains[i].Prio != chains[j].Prio {
+			return chains[i].Prio < chains[j].Prio
+		}
+		return chains[i].String() < chains[j].String()
+	})
+
+	v := Verdict{Accepted: true, Action: ActionAccept, Rule: "no input chain"}
+	for _, c := range chains {
+		v.Action, v.Rule = "", ""
+		if !t.walk(c, p, &v, 0) {
+			v.Action = c.Policy
+			if v.Action == "" {
+				v.Action = ActionAccept
+			}
+			v.Rule = fmt.Sprintf("policy %s of chain %s", v.Action, c)
+		}
+		v.Accepted = v.Action == ActionAccept
+		if !v.Accepted {
+			return v
+		}
+	}
+	return v
+}
+
+// walk the rules of chain, return true if the packet is accepted, dropped or rejected.
+func (t *Nftables) walk(c *nftChain, p Packet, v *Verdict, depth int) bool {
+	if depth > maxJumps {
+		return false
+	}
+	for _, r := range c.rules {
+		verdict, supported, possible := t.evalRule(r, p)
+		if !supported {
+			v.Skipped = append(v.Skipped, r.String())
+			continue
+		}
+		if verdict == nil {
+			continue
+		}
+		if possible {
+			v.Possible = append(v.Possible, r.String())
+			continue
+		}
+		switch verdict.kind {
+		case ActionAccept, ActionDrop, ActionReject:
+			v.Action, v.Rule = verdict.kind, r.String()
+			return true
+		case "return":
+			return false
+		case "jump", "goto":
+			next, ok := t.chains[nftKey(c.Family, c.Table, verdict.target)]
+			if ok && t.walk(next, p, v, depth+1) {
+				return true
+			}
+			if verdict.kind == "goto" {
+				return false
+			}
+		}
+	}
+	return false
+}
+
+type nftVerdict struct {
+	kind   string
+	target string
+}
+
+// parseVerdict return the verdict statement, or nil if it is not a verdict.
+func parseVerdict(kind string, raw json.RawMessage) *nftVerdict {
+	switch kind {
+	case "accept", "drop", "reject", "return":
+		return &nftVerdict{kind: kind}
+	case "jump", "goto":
+		var target struct {
+			Target string `json:"target"`
+		}
+		if json.Unmarshal(raw, &target) == nil {
+			return &nftVerdict{kind: kind, target: target.Target}
+		}
+	}
+	return nil
+}
+
+// evalRule return the verdict if the rule matches, nil if it does not match or has no verdict,
+// supported is false if any expression is not supported, possible is true if the verdict depends on
+// the unknown interface of packet.
+func (t *Nftables) evalRule(r *nftRule, p Packet) (verdict *nftVerdict, supported, possible bool) {
+	for _, expr := range r.Expr {
+		for kind, raw := range expr {
+			switch kind {
+			case "match":
+				var m struct {
+					Op    string          `json:"op"`
+					Left  json.RawMessage `json:"left"`
+					Right interface{}     `json:"right"`
+				}
+				if err := json.Unmarshal(raw, &m); err != nil {
+					return nil, false, false
+				}
+				matched, supported, maybe := t.evalMatch(r, p, m.Op, m.Left, m.Right)
+				if !supported {
+					return nil, false, false
+				}
+				if !matched && !maybe {
+					return nil, true, false
+				}
+				possible = possible || maybe
+			case "vmap":
+				var vmap struct {
+					Key  json.RawMessage `json:"key"`
+					Data interface{}     `json:"data"`
+				}
+				if err := json.Unmarshal(raw, &vmap); err != nil {
+					return nil, false, false
+				}
+				verdict, supported, maybe := t.evalVmap(r, p, vmap.Key, vmap.Data)
+				return verdict, supported, possible || maybe
+			case "counter", "log", "limit", "mangle", "notrack", "quota":
+				// the statements do not affect the verdict.
+			case "continue":
+				return nil, true, false
+			default:
+				if v := parseVerdict(kind, raw); v != nil {
+					return v, true, possible
+				}
+				return nil, false, false
+			}
+		}
+	}
+	return nil, true, false
+}
+
+// evalVmap return the verdict of the first element matched, possible is true if the element possibly matches
+// the unknown interface of packet.
+func (t *Nftables) evalVmap(r *nftRule, p Packet, key json.RawMessage, data interface{}) (verdict *nftVerdict, supported, possible bool) {
+	left, ok, supported := evalExpr(key, p)
+	if !supported || !ok {
+		return nil, supported, false
+	}
+	for _, e := range t.elements(r, data) {
+		pair, isPair := e.([]interface{})
+		if !isPair || len(pair) != 2 {
+			return nil, false, false
+		}
+		matched, supported, possible := t.equal(r, left, pair[0])
+		if !supported {
+			return nil, false, false
+		}
+		if !matched && !possible {
+			continue
+		}
+		verdict, isVerdict := pair[1].(map[string]interface{})
+		if !isVerdict {
+			return nil, false, false
+		}
+		for kind, v := range verdict {
+			raw, _ := json.Marshal(v)
+			if nv := parseVerdict(kind, raw); nv != nil {
+				return nv, true, possible && !matched
+			}
+		}
+		return nil, false, false
+	}
+	return nil, true, false
+}
+
+// elements return the elements of anonymous set, named set or list.
+func (t *Nftables) elements(r *nftRule, v interface{}) []interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		return v
+	case string:
+		if strings.HasPrefix(v, "@") {
+			return t.sets[nftKey(r.Family, r.Table, v[1:])]
+		}
+	case map[string]interface{}:
+		if set, ok := v["set"].([]interface{}); ok {
+			return set
+		}
+	}
+	return []interface{}{v}
+}
+
+// evalMatch return whether the match expression matches the packet, possible is true if it is not matched
+// but possibly matches the unknown interface of packet.
+func (t *Nftables) evalMatch(r *nftRule, p Packet, op string, leftRaw json.RawMessage, right interface{}) (matched, supported, possible bool) {
+	left, ok, supported := evalExpr(leftRaw, p)
+	if !supported {
+		return false, false, false
+	}
+	if !ok {
+		// the payload of other protocol or family never matches.
+		return false, true, false
+	}
+
+	if op == "!=" {
+		if source, isNet := left.(*net.IPNet); isNet {
+			// the source not in the networks only if none of its addresses is in them.
+			for _, e := range t.elements(r, right) {
+				n, ok := parseNftNet(e)
+				if !ok {
+					return false, false, false
+				}
+				if overlapNet(n, source) {
+					return false, true, false
+				}
+			}
+			return true, true, false
+		}
+	}
+
+	switch op {
+	case "==", "in", "":
+		return t.equal(r, left, right)
+	case "!=":
+		// the unknown interface possibly matching any of the names is possibly not matching either.
+		matched, supported, possible := t.equal(r, left, right)
+		return !matched && !possible, supported, possible && !matched
+	case "<", ">", "<=", ">=":
+		port, isPort := left.(int)
+		bound, isNumber := right.(float64)
+		if !isPort || !isNumber {
+			return false, false, false
+		}
+		return compareOp(port, op, int(bound)), true, false
+	}
+	return false, false, false
+}
+
+func compareOp(a int, op string, b int) bool {
+	switch op {
+	case "<":
+		return a < b
+	case ">":
+		return a > b
+	case "<=":
+		return a <= b
+	default:
+		return a >= b
+	}
+}
+
+// equal return true if left equals to right, or any element of right if it is a set.
+// possible is true if any element is a specific interface while the interface of packet is unknown.
+func (t *Nftables) equal(r *nftRule, left, right interface{}) (matched, supported, possible bool) {
+	elements := t.elements(r, right)
+	for _, e := range elements {
+		if m, ok := e.(map[string]interface{}); ok {
+			if elem, ok := m["elem"].(map[string]interface{}); ok {
+				e = elem["val"]
+			}
+		}
+		if iface, ok := left.(interfaceValue); ok {
+			if name, ok := e.(string); ok && iface.packet.interfaceUnknown(name) {
+				possible = true
+				continue
+			}
+		}
+		matched, supported := equalValue(left, e)
+		if !supported {
+			return false, false, false
+		}
+		if matched {
+			return true, true, false
+		}
+	}
+	return false, true, possible
+}
+
+func equalValue(left, right interface{}) (matched, supported bool) {
+	switch l := left.(type) {
+	case int:
+		switch r := right.(type) {
+		case float64:
+			return l == int(r), true
+		case string:
+			port, err := strconv.Atoi(r)
+			return err == nil && port == l, err == nil
+		case map[string]interface{}:
+			bounds, ok := r["range"].([]interface{})
+			if !ok || len(bounds) != 2 {
+				return false, false
+			}
+			from, ok1 := bounds[0].(float64)
+			to, ok2 := bounds[1].(float64)
+			return ok1 && ok2 && int(from) <= l && l <= int(to), ok1 && ok2
+		}
+	case string:
+		r, ok := right.(string)
+		return ok && strings.EqualFold(l, r), ok
+	case interfaceValue:
+		r, ok := right.(string)
+		return ok && l.packet.matchInterface(r), ok
+	case *net.IPNet:
+		n, ok := parseNftNet(right)
+		return ok && containsNet(n, l), ok
+	}
+	return false, false
+}
+
+// parseNftNet parse the address or prefix like {"prefix": {"addr": "10.0.0.0", "len": 8}}.
+func parseNftNet(v interface{}) (*net.IPNet, bool) {
+	switch v := v.(type) {
+	case string:
+		n, err := ParseNet(v)
+		return n, err == nil
+	case map[string]interface{}:
+		prefix, ok := v["prefix"].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		addr, _ := prefix["addr"].(string)
+		length, _ := prefix["len"].(float64)
+		n, err := ParseNet(fmt.Sprintf("%s/%d", addr, int(length)))
+		return n, err == nil
+	}
+	return nil, false
+}
+
+// interfaceValue is the inbound interface of packet, which may be unknown.
+type interfaceValue struct {
+	packet Packet
+}
+
+// evalExpr return the value of the expression for the packet, ok is false if the expression
+// requires another protocol or family, supported is false if the expression could not be evaluated.
+func evalExpr(raw json.RawMessage, p Packet) (value interface{}, ok, supported bool) {
+	var expr struct {
+		Payload *struct {
+			Protocol string `json:"protocol"`
+			Field    string `json:"field"`
+		} `json:"payload"`
+		Meta *struct {
+			Key string `json:"key"`
+		} `json:"meta"`
+		Ct *struct {
+			Key string `json:"key"`
+		} `json:"ct"`
+		Fib *struct {
+			Result string   `json:"result"`
+			Flags  []string `json:"flags"`
+		} `json:"fib"`
+	}
+	if err := json.Unmarshal(raw, &expr); err != nil {
+		return nil, false, false
+	}
+
+	family := "ip"
+	if p.IPv6() {
+		family = "ip6"
+	}
+	switch {
+	case expr.Payload != nil:
+		switch {
+		case expr.Payload.Field == "dport" && (expr.Payload.Protocol == "th" || expr.Payload.Protocol == "tcp" || expr.Payload.Protocol == "udp"):
+			return p.Port, expr.Payload.Protocol == "th" || expr.Payload.Protocol == p.Protocol, true
+		case expr.Payload.Field == "saddr" && (expr.Payload.Protocol == "ip" || expr.Payload.Protocol == "ip6"):
+			return p.Source, expr.Payload.Protocol == family, true
+		}
+	case expr.Meta != nil:
+		switch expr.Meta.Key {
+		case "l4proto":
+			return p.Protocol, true, true
+		case "nfproto":
+			return map[string]string{"ip": "ipv4", "ip6": "ipv6"}[family], true, true
+		case "protocol":
+			return family, true, true
+		case "iif", "iifname":
+			return interfaceValue{packet: p}, true, true
+		case "pkttype":
+			return "host", true, true
+		}
+	case expr.Ct != nil && expr.Ct.Key == "state":
+		return "new", true, true
+	case expr.Fib != nil && expr.Fib.Result == "type" && len(expr.Fib.Flags) > 0 && expr.Fib.Flags[0] == "daddr":
+		return "local", true, true
+	}
+	return nil, false, false
+}
@@ -0,0 +1,373 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package proxy selects the proxy of request by the environment variables as golang.org/x/net/http/httpproxy,
+// which is used by http.ProxyFromEnvironment. It reads the environment by the getenv specified instead of
+// caching it for the process, so that the proxy used is reported for each request.
+package proxy
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Config is the proxy configuration of environment variables, it selects the proxy as the http package of Go,
+// which is used by the container runtimes and kubernetes components.
+type Config struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	// NoProxy the entries separated by comma, like "*", "10.0.0.0/8", "10.0.0.1", "example.com", ".example.com",
+	// "*.example.com" or the ones with port like "example.com:8443". example.com matches itself and its sub domains,
+	// while .example.com and *.example.com match the sub domains only.
+	NoProxy string
+	// CGI ignores HTTP_PROXY, since it may be set by the Proxy header of request.
+	CGI bool
+}
+
+// FromEnvironment return the configuration of HTTP_PROXY, HTTPS_PROXY and NO_PROXY, or their lowercase ones.
+func FromEnvironment(getenv func(string) string) Config {
+	return Config{
+		HTTPProxy:  getenvAny(getenv, "HTTP_PROXY", "http_proxy"),
+		HTTPSProxy: getenvAny(getenv, "HTTPS_PROXY", "https_proxy"),
+		NoProxy:    getenvAny(getenv, "NO_PROXY", "no_proxy"),
+		CGI:        getenv("REQUEST_METHOD") != "",
+	}
+}
+
+func getenvAny(getenv func(string) string, names ...string) string {
+	for _, n := range names {
+		if v := getenv(n); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// config is the parsed Config.
+type config struct {
+	Config
+	httpProxy  *url.URL
+	httpsProxy *url.URL
+	// ipMatchers are the IP addresses and CIDRs of NoProxy, domainMatchers are the domains.
+	ipMatchers     []matcher
+	domainMatchers []matcher
+}
+
+// ProxyFunc return the proxy of request URL, it is nil if the request is sent directly.
+// The proxy invalid is ignored, and the localhost and loopback addresses are never proxied.
+func (c Config) ProxyFunc() func(*url.URL) (*url.URL, error) {
+	cfg := &config{Config: c}
+	cfg.init()
+	return cfg.proxyForURL
+}
+
+func (c *config) proxyForURL(req *url.URL) (*url.URL, error) {
+	var proxy *url.URL
+	switch req.Scheme {
+	case "https":
+		proxy = c.httpsProxy
+	case "http":
+		proxy = c.httpProxy
+		if proxy != nil && c.CGI {
+			return nil, errors.New("refusing to use HTTP_PROXY value in CGI environment; see golang.org/s/cgihttpproxy")
+		}
+	}
+	if proxy == nil || !c.useProxy(canonicalAddr(req)) {
+		return nil, nil
+	}
+	return proxy, nil
+}
+
+// parseProxy parse the proxy like http://proxy:3128, or proxy:3128 without scheme which is regarded as http.
+func parseProxy(proxy string) (*url.URL, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+		if u, err := url.Parse("http://" + proxy); err == nil {
+			return u, nil
+		}
+	}
+	if err != nil {
+		return nil, errors.Errorf("invalid proxy address %q: %v", proxy, err)
+	}
+	return u, nil
+}
+
+func (c *config) init() {
+	if u, err := parseProxy(c.HTTPProxy); err == nil {
+		c.httpProxy = u
+	}
+	if u, err := parseProxy(c.HTTPSProxy); err == nil {
+		c.httpsProxy = u
+	}
+
+	for _, entry := range strings.Split(c.NoProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			c.ipMatchers = []matcher{allMatch{}}
+			c.domainMatchers = []matcher{allMatch{}}
+			return
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			c.ipMatchers = append(c.ipMatchers, cidrMatch{cidr: cidr})
+			continue
+		}
+
+		host, port, err := net.SplitHostPort(entry)
+		if err != nil {
+			host, port = entry, ""
+		} else if host == "" {
+			// the entry without host is malformed.
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			c.ipMatchers = append(c.ipMatchers, ipMatch{ip: ip, port: port})
+			continue
+		}
+		if host == "" {
+			continue
+		}
+
+		// .example.com and *.example.com match the sub domains only.
+		if strings.HasPrefix(host, "*.") {
+			host = host[1:]
+		}
+		matchHost := false
+		if host[0] != '.' {
+			matchHost = true
+			host = "." + host
+		}
+		if ascii, err := idnaASCII(host); err == nil {
+			host = ascii
+		}
+		c.domainMatchers = append(c.domainMatchers, domainMatch{host: host, port: port, matchHost: matchHost})
+	}
+}
+
+// useProxy return false if the address is localhost, loopback or matches an entry of NO_PROXY.
+func (c *config) useProxy(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return false
+	}
+
+	host = strings.ToLower(strings.TrimSpace(host))
+	if ip != nil {
+		for _, m := range c.ipMatchers {
+			if m.match(host, port, ip) {
+				return false
+			}
+		}
+	}
+	for _, m := range c.domainMatchers {
+		if m.match(host, port, ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// canonicalAddr return the host:port of URL with the default port of scheme, the international domain name
+// is converted to ASCII.
+func canonicalAddr(u *url.URL) string {
+	host := u.Hostname()
+	if ascii, err := idnaASCII(host); err == nil {
+		host = ascii
+	}
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443", "socks5": "1080"}[u.Scheme]
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// matcher is an entry of NO_PROXY.
+type matcher interface {
+	match(host, port string, ip net.IP) bool
+}
+
+type allMatch struct{}
+
+func (allMatch) match(host, port string, ip net.IP) bool {
+	return true
+}
+
+type cidrMatch struct {
+	cidr *net.IPNet
+}
+
+func (m cidrMatch) match(host, port string, ip net.IP) bool {
+	return m.cidr.Contains(ip)
+}
+
+type ipMatch struct {
+	ip   net.IP
+	port string
+}
+
+func (m ipMatch) match(host, port string, ip net.IP) bool {
+	return m.ip.Equal(ip) && (m.port == "" || m.port == port)
+}
+
+// domainMatch matches the sub domains of host prefixed with ".", and the host itself if matchHost.
+type domainMatch struct {
+	host      string
+	port      string
+	matchHost bool
+}
+
+func (m domainMatch) match(host, port string, ip net.IP) bool {
+	if strings.HasSuffix(host, m.host) || (m.matchHost && host == m.host[1:]) {
+		return m.port == "" || m.port == port
+	}
+	return false
+}
+
+// idnaASCII convert the international domain name to ASCII by punycode, the labels are lowercased instead of
+// the full mapping of UTS #46, which is enough to match the domains of NO_PROXY.
+func idnaASCII(host string) (string, error) {
+	if isASCII(host) {
+		return host, nil
+	}
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		encoded, err := punycode(strings.ToLower(label))
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid domain name %q", host)
+		}
+		labels[i] = "xn--" + encoded
+	}
+	return strings.Join(labels, "."), nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// parameters of punycode, see RFC 3492.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+// punycode encode the label by RFC 3492 without the prefix xn--.
+func punycode(label string) (string, error) {
+	runes := []rune(label)
+	var out []byte
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+		}
+	}
+	basic := len(out)
+	if basic > 0 {
+		out = append(out, '-')
+	}
+
+	n, delta, bias := rune(punyInitialN), 0, punyInitialBias
+	for handled := basic; handled < len(runes); {
+		// the smallest code point not handled yet.
+		m := rune(utf8.MaxRune)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		delta += int(m-n) * (handled + 1)
+		if delta < 0 {
+			return "", errors.New("punycode overflow")
+		}
+		n = m
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := k - bias
+				if t < punyTMin {
+					t = punyTMin
+				} else if t > punyTMax {
+					t = punyTMax
+				}
+				if q < t {
+					break
+				}
+				out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punyDigit(q))
+			bias = punyAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return string(out), nil
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punyAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
@@ -0,0 +1,208 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package system
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shirou/gopsutil/disk"
+)
+
+// MountInfoPath is the mountinfo of current process.
+const MountInfoPath = "/proc/self/mountinfo"
+
+// Mount is a line of mountinfo, see proc(5).
+type Mount struct {
+	ID           int
+	ParentID     int
+	MajorMinor   string
+	Root         string
+	MountPoint   string
+	Options      string
+	Optional     []string
+	FsType       string
+	Source       string
+	SuperOptions string
+}
+
+type DiskUsage struct {
+	// Path is the existing path used to stat, it is the nearest existing parent if the path required not exists.
+	Path              string
+	Device            string
+	MountPoint        string
+	FsType            string
+	Total             uint64
+	Free              uint64
+	Used              uint64
+	UsedPercent       float64
+	InodesTotal       uint64
+	InodesFree        uint64
+	InodesUsed        uint64
+	InodesUsedPercent float64
+}
+
+// GetMountInfo return the mounts of current process.
+func GetMountInfo() ([]Mount, error) {
+	f, err := os.Open(MountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseMountInfo(f)
+}
+
+// ParseMountInfo parse mounts in the format of /proc/self/mountinfo, a line is like:
+// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+func ParseMountInfo(r io.Reader) ([]Mount, error) {
+	var mounts []Mount
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		parts := strings.SplitN(line, " - ", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid mountinfo line: %s", line)
+		}
+		fields, super := strings.Fields(parts[0]), strings.Fields(parts[1])
+		if len(fields) < 6 || len(super) < 2 {
+			return nil, errors.Errorf("invalid mountinfo line: %s", line)
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, errors.Errorf("invalid mount id of mountinfo line: %s", line)
+		}
+		parentID, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, errors.Errorf("invalid parent id of mountinfo line: %s", line)
+		}
+		m := Mount{
+			ID:         id,
+			ParentID:   parentID,
+			MajorMinor: fields[2],
+			Root:       unescapeMountPath(fields[3]),
+			MountPoint: unescapeMountPath(fields[4]),
+			Options:    fields[5],
+			Optional:   fields[6:],
+			FsType:     super[0],
+			Source:     unescapeMountPath(super[1]),
+		}
+		if len(super) > 2 {
+			m.SuperOptions = super[2]
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPath unescape the octal chars like space \040 of mountinfo.
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if v, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// FindMount return the mount which backs the absolute path, the last one wins if mounts are stacked on the same point.
+func FindMount(mounts []Mount, path string) (Mount, bool) {
+	var (
+		found Mount
+		ok    bool
+	)
+	for _, m := range mounts {
+		if !underPath(path, m.MountPoint) {
+			continue
+		}
+		if !ok || len(m.MountPoint) >= len(found.MountPoint) {
+			found, ok = m, true
+		}
+	}
+	return found, ok
+}
+
+func underPath(path, dir string) bool {
+	return dir == "/" || path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// ExistingPath return the absolute path with symlinks evaluated, or its nearest existing parent if not exists.
+func ExistingPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return resolved, nil
+		}
+		if !os.IsNotExist(err) || path == filepath.Dir(path) {
+			return "", err
+		}
+		path = filepath.Dir(path)
+	}
+}
+
+// GetDiskUsage return the usage of the file system which backs path.
+func GetDiskUsage(path string) (DiskUsage, error) {
+	existing, err := ExistingPath(path)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+	mounts, err := GetMountInfo()
+	if err != nil {
+		return DiskUsage{}, err
+	}
+	mount, ok := FindMount(mounts, existing)
+	if !ok {
+		return DiskUsage{}, errors.Errorf("mount of %s not found", existing)
+	}
+
+	usage, err := disk.Usage(existing)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+	return DiskUsage{
+		Path:              existing,
+		Device:            mount.Source,
+		MountPoint:        mount.MountPoint,
+		FsType:            mount.FsType,
+		Total:             usage.Total,
+		Free:              usage.Free,
+		Used:              usage.Used,
+		UsedPercent:       usage.UsedPercent,
+		InodesTotal:       usage.InodesTotal,
+		InodesFree:        usage.InodesFree,
+		InodesUsed:        usage.InodesUsed,
+		InodesUsedPercent: usage.InodesUsedPercent,
+	}, nil
+}
@@ -0,0 +1,84 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// UnitProperties are the properties of a unit printed by systemctl show, like ActiveState=active.
+type UnitProperties map[string]string
+
+// HasName return true if the name is the id or one of the aliases of the unit.
+func (p UnitProperties) HasName(name string) bool {
+	if p["Id"] == name {
+		return true
+	}
+	for _, n := range strings.Fields(p["Names"]) {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ShowUnits run systemctl show with the properties of the units.
+func ShowUnits(ctx context.Context, properties []string, units ...string) ([]UnitProperties, error) {
+	args := []string{"show", "--property=" + strings.Join(properties, ","), "--"}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "systemctl", append(args, units...)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, errors.Wrapf(err, "failed to run systemctl show: %s", strings.TrimSpace(stderr.String()))
+	}
+	return ParseSystemctlShow(bytes.NewReader(out))
+}
+
+// ParseSystemctlShow parse the output of systemctl show, a property is a line like ActiveState=active,
+// and the properties of units are separated by blank lines.
+func ParseSystemctlShow(r io.Reader) ([]UnitProperties, error) {
+	var units []UnitProperties
+	var current UnitProperties
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			current = nil
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("invalid property line of systemctl show: %s", line)
+		}
+		if current == nil {
+			current = make(UnitProperties)
+			units = append(units, current)
+		}
+		current[kv[0]] = kv[1]
+	}
+	return units, scanner.Err()
+}
@@ -0,0 +1,297 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"preflight/checker"
+	"preflight/checker/cluster/api/types"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+var yamlLineRegexp = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// Load read the plan file from path, it could be written in YAML or JSON.
+func Load(path string) (*Plan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read plan file %s", path)
+	}
+	return Parse(path, data)
+}
+
+// Parse build the Plan from the content of plan file, source is only used to report errors.
+// All the problems found are returned together as ErrorList.
+func Parse(source string, data []byte) (*Plan, error) {
+	l := &loader{source: source}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		l.yamlError(nil, err)
+		return nil, l.errs
+	}
+	if len(doc.Content) == 0 {
+		l.errorf(nil, "plan is empty")
+		return nil, l.errs
+	}
+
+	p := l.load(doc.Content[0])
+	if len(l.errs) > 0 {
+		return nil, l.errs
+	}
+	return p, nil
+}
+
+type loader struct {
+	source string
+	errs   ErrorList
+}
+
+func (l *loader) load(root *yaml.Node) *Plan {
+	if root.Kind != yaml.MappingNode {
+		l.errorf(root, "plan must be a mapping")
+		return nil
+	}
+	l.checkFields(root, reflect.TypeOf(file{}))
+
+	p := &Plan{}
+	if node := lookup(root, "version"); node == nil {
+		l.errorf(root, "version is required")
+	} else if l.decode(node, &p.Version) && p.Version != Version {
+		l.errorf(node, "unsupported version %q, expected %q", p.Version, Version)
+	}
+
+	if node := lookup(root, "cluster"); node != nil {
+		p.Cluster = l.loadCluster(node)
+	}
+
+	node := lookup(root, "checkers")
+	if node == nil {
+		l.errorf(root, "checkers is required")
+		return p
+	}
+	if node.Kind != yaml.SequenceNode {
+		l.errorf(node, "checkers must be a list")
+		return p
+	}
+	if len(node.Content) == 0 {
+		l.errorf(node, "checkers must not be empty")
+	}
+	var dependsOnNodes []*yaml.Node
+	for _, entry := range node.Content {
+		if c := l.loadChecker(entry, p.Cluster); c != nil {
+			p.Checkers = append(p.Checkers, c)
+			dependsOnNodes = append(dependsOnNodes, lookup(entry, "dependsOn"))
+		}
+	}
+	l.checkDependencies(p.Checkers, dependsOnNodes)
+
+	return p
+}
+
+// checkDependencies report the dependencies referring to unknown checker IDs.
+func (l *loader) checkDependencies(checks []checker.Interface, dependsOnNodes []*yaml.Node) {
+	ids := make(map[string]bool, len(checks))
+	for _, c := range checks {
+		ids[checker.ID(c)] = true
+	}
+
+	for _, node := range dependsOnNodes {
+		if node == nil {
+			continue
+		}
+		for _, dep := range node.Content {
+			if !ids[dep.Value] {
+				l.errorf(dep, "depends on unknown checker %q", dep.Value)
+			}
+		}
+	}
+}
+
+func (l *loader) loadCluster(node *yaml.Node) *types.ClusterInfoBrief {
+	if node.Kind != yaml.MappingNode {
+		l.errorf(node, "cluster must be a mapping")
+		return nil
+	}
+	l.checkFields(node, reflect.TypeOf(types.ClusterInfoBrief{}))
+
+	cluster := &types.ClusterInfoBrief{}
+	if !l.decode(node, cluster) {
+		return nil
+	}
+	if len(cluster.Hosts) == 0 {
+		l.errorf(node, "cluster hosts must not be empty")
+	}
+	if cluster.SshUser == "" {
+		cluster.SshUser = "root"
+	}
+	return cluster
+}
+
+func (l *loader) loadChecker(node *yaml.Node, cluster *types.ClusterInfoBrief) checker.Interface {
+	if node.Kind != yaml.MappingNode {
+		l.errorf(node, "checker must be a mapping")
+		return nil
+	}
+	l.checkFields(node, reflect.TypeOf(checkerEntry{}))
+
+	var (
+		checkType, level, id string
+		dependsOn            []string
+	)
+	typeNode := lookup(node, "type")
+	if typeNode == nil {
+		l.errorf(node, "checker type is required")
+		return nil
+	}
+	if !l.decode(typeNode, &checkType) {
+		return nil
+	}
+
+	prototype, err := checker.GetCheckersByType(strings.ToLower(checkType))
+	if err != nil {
+		l.errorf(typeNode, "unknown checker type %q, run `preflight list` to see all types", checkType)
+		return nil
+	}
+
+	if levelNode := lookup(node, "level"); levelNode != nil && l.decode(levelNode, &level) {
+		if !checker.IsValidLevel(level) {
+			l.errorf(levelNode, "unknown level %q, expected one of %s", level, strings.Join(checker.Levels, ","))
+		}
+	}
+
+	if idNode := lookup(node, "id"); idNode != nil {
+		l.decode(idNode, &id)
+	}
+	if dependsOnNode := lookup(node, "dependsOn"); dependsOnNode != nil {
+		l.decode(dependsOnNode, &dependsOn)
+	}
+
+	t := reflect.TypeOf(prototype)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	v := reflect.New(t)
+	argsNode := lookup(node, "args")
+	if argsNode != nil {
+		if argsNode.Kind != yaml.MappingNode {
+			l.errorf(argsNode, "args of checker %s must be a mapping", checkType)
+			return nil
+		}
+		l.checkFields(argsNode, t)
+		if !l.decode(argsNode, v.Interface()) {
+			return nil
+		}
+	}
+
+	c := v.Elem().Interface().(checker.Interface)
+	if cc, ok := c.(checker.ClusterCheck); ok && len(cc.AuthInfo.Hosts) == 0 {
+		if cluster == nil {
+			l.errorf(node, "checker %s requires the cluster inventory", checkType)
+			return nil
+		}
+		cc.AuthInfo = *cluster
+		c = cc
+	}
+	if err := checker.ValidateArgs(c); err != nil {
+		if argsNode == nil {
+			argsNode = node
+		}
+		l.errorf(argsNode, "invalid args of checker %s: %v", checkType, err)
+		return nil
+	}
+
+	if level != "" || id != "" || len(dependsOn) > 0 {
+		return checker.Customized{Interface: c, Level: level, CheckerID: id, DependsOn: dependsOn}
+	}
+	return c
+}
+
+// checkFields report the keys of mapping node that are not fields of struct t.
+func (l *loader) checkFields(node *yaml.Node, t reflect.Type) {
+	known := make(map[string]bool, t.NumField())
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(t.Field(i).Name)
+		}
+		known[name] = true
+		names = append(names, name)
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if !known[key.Value] {
+			l.errorf(key, "unknown field %q, expected one of %s", key.Value, strings.Join(names, ","))
+		}
+	}
+}
+
+// decode the node into out, return false if any error reported.
+func (l *loader) decode(node *yaml.Node, out interface{}) bool {
+	if err := node.Decode(out); err != nil {
+		l.yamlError(node, err)
+		return false
+	}
+	return true
+}
+
+// yamlError convert the error of yaml package, which only contains line number in its message.
+func (l *loader) yamlError(node *yaml.Node, err error) {
+	msgs := []string{err.Error()}
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		msgs = typeErr.Errors
+	}
+
+	for _, msg := range msgs {
+		m := yamlLineRegexp.FindStringSubmatch(msg)
+		if m == nil {
+			l.errorf(node, "%s", strings.TrimPrefix(msg, "yaml: "))
+			continue
+		}
+		line, _ := strconv.Atoi(m[1])
+		l.errs = append(l.errs, &Error{Source: l.source, Line: line, Msg: m[2]})
+	}
+}
+
+func (l *loader) errorf(node *yaml.Node, format string, args ...interface{}) {
+	e := &Error{Source: l.source, Msg: fmt.Sprintf(format, args...)}
+	if node != nil {
+		e.Line, e.Column = node.Line, node.Column
+	}
+	l.errs = append(l.errs, e)
+}
+
+// lookup return the value node of key in mapping node.
+func lookup(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
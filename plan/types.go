@@ -0,0 +1,54 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"preflight/checker"
+	"preflight/checker/cluster/api/types"
+)
+
+// Version the current version of plan file.
+const Version = "v1"
+
+// Plan describes which checkers to run and how to run them.
+type Plan struct {
+	// Version of plan file, only v1 is supported now.
+	Version string
+	// Cluster the inventory used by cluster checker.
+	Cluster *types.ClusterInfoBrief
+	// Checkers built from the checker list of plan file, in declared order.
+	Checkers []checker.Interface
+}
+
+// file is the raw layout of plan file.
+type file struct {
+	Version  string                  `json:"version" yaml:"version"`
+	Cluster  *types.ClusterInfoBrief `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+	Checkers []checkerEntry          `json:"checkers" yaml:"checkers"`
+}
+
+// checkerEntry is one checker instance of plan file.
+type checkerEntry struct {
+	// Type of checker, see `preflight list`.
+	Type string `json:"type" yaml:"type"`
+	// ID of checker referred by dependsOn of other checkers, default is the checker name.
+	ID string `json:"id,omitempty" yaml:"id,omitempty"`
+	// Level overrides the default level of checker.
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+	// DependsOn the IDs of checkers which should pass before running this checker.
+	DependsOn []string `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`
+	// Args the arguments of checker, decoded into the checker struct.
+	Args map[string]interface{} `json:"args,omitempty" yaml:"args,omitempty"`
+}
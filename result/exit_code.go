@@ -0,0 +1,65 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package result
+
+import "preflight/checker"
+
+// Exit codes of preflight run, derived from the highest level of failed checkers.
+const (
+	// ExitOK no checker failed at or above the blocking level.
+	ExitOK = 0
+	// ExitError preflight itself failed, like invalid arguments or plan file.
+	ExitError = 1
+	ExitInfo  = 2
+	ExitWarn  = 3
+	ExitFatal = 4
+	ExitPanic = 5
+	// ExitInterrupted the run was canceled or reached --timeout before all checkers finished,
+	// and no checker failed at or above the blocking level among the finished ones.
+	ExitInterrupted = 6
+)
+
+var levelExitCodes = map[string]int{
+	checker.InfoLevel:  ExitInfo,
+	checker.WarnLevel:  ExitWarn,
+	checker.FatalLevel: ExitFatal,
+	checker.PanicLevel: ExitPanic,
+}
+
+// ExitCode return the exit code of the highest level of failed, error and timed out checkers,
+// if it is lower than failOn, return ExitInterrupted for the partial result or else ExitOK.
+// ignored checkers in Warnings are not counted.
+func ExitCode(resp Response, failOn string) int {
+	highest := ""
+	for _, descs := range [][]Descriptor{resp.Failed, resp.Errors, resp.TimedOut} {
+		for _, d := range descs {
+			level := checker.FatalLevel
+			if d.Metadata != nil && checker.IsValidLevel(d.Metadata.Level) {
+				level = d.Metadata.Level
+			}
+			if highest == "" || checker.Severity(level) > checker.Severity(highest) {
+				highest = level
+			}
+		}
+	}
+
+	if highest == "" || checker.Severity(highest) < checker.Severity(failOn) {
+		if resp.Interrupted {
+			return ExitInterrupted
+		}
+		return ExitOK
+	}
+	return levelExitCodes[highest]
+}
@@ -14,6 +14,8 @@
 
 package result
 
+import "preflight/checker"
+
 type Formatter interface {
 	Format(opts ...Option) Response
 }
@@ -31,43 +33,71 @@ func (d defaultFormatter) Format(opts ...Option) Response {
 }
 
 func (d defaultFormatter) ParesToResponse(ignores []string) Response {
-	var passedList, failedList, warnings []Descriptor
+	var resp Response
+
+	for _, result := range d.result.Passed {
+		resp.Passed = append(resp.Passed, NewDescriptor(result))
+	}
 
-	if len(d.result.Passed) > 0 {
-		for _, result := range d.result.Passed {
-			desc := Descriptor{
-				CheckerType: result.Checker.Type(),
-				CheckerName: result.Checker.PrettyName(),
-			}
-			passedList = append(passedList, desc)
+	// if user ignore the checker result will downgrade the level to Warnings result.
+	for _, result := range d.result.Failed {
+		desc := NewDescriptor(result)
+		if NotIn(desc.CheckerType, ignores) {
+			resp.Failed = append(resp.Failed, desc)
+		} else {
+			desc.Status = checker.StatusWarn
+			resp.Warnings = append(resp.Warnings, desc)
 		}
 	}
 
-	if len(d.result.Failed) > 0 {
-		for _, result := range d.result.Failed {
-			t := result.Checker.Type()
-			m := result.Checker.Metadata()
-			name := result.Checker.PrettyName()
-			desc := Descriptor{
-				CheckerType:  t,
-				CheckerName:  name,
-				IsPassed:     result.Passed,
-				Metadata:     &m,
-				ErrorMessage: result.ErrorMessage,
-			}
-			if NotIn(t, ignores) {
-				failedList = append(failedList, desc)
-			} else {
-				warnings = append(warnings, desc)
-			}
+	for _, result := range d.result.Errors {
+		desc := NewDescriptor(result)
+		if NotIn(desc.CheckerType, ignores) {
+			resp.Errors = append(resp.Errors, desc)
+		} else {
+			desc.Status = checker.StatusWarn
+			resp.Warnings = append(resp.Warnings, desc)
 		}
 	}
 
-	return Response{
-		Passed:   passedList,
-		Failed:   failedList,
-		Warnings: warnings,
+	for _, result := range d.result.Warnings {
+		resp.Warnings = append(resp.Warnings, NewDescriptor(result))
+	}
+
+	for _, result := range d.result.TimedOut {
+		resp.TimedOut = append(resp.TimedOut, NewDescriptor(result))
+	}
+
+	for _, result := range d.result.Skipped {
+		resp.Skipped = append(resp.Skipped, NewDescriptor(result))
+	}
+
+	resp.Interrupted = d.result.Interrupted
+	return resp
+}
+
+// NewDescriptor convert the check result to Descriptor.
+func NewDescriptor(result CheckResult) Descriptor {
+	m := result.Checker.Metadata()
+	desc := Descriptor{
+		CheckerType:     result.Checker.Type(),
+		CheckerName:     result.Checker.PrettyName(),
+		Status:          result.Status,
+		IsPassed:        result.Passed,
+		ErrorMessage:    result.ErrorMessage,
+		Reason:          result.Reason,
+		DurationSeconds: result.Duration.Seconds(),
+		Host:            result.Host,
+		Observed:        result.Observed,
+		Expected:        result.Expected,
+		Details:         result.Details,
+		Metadata:        &m,
+	}
+	if !result.StartTime.IsZero() {
+		startTime := result.StartTime
+		desc.StartTime = &startTime
 	}
+	return desc
 }
 
 func NewDefaultFormatter(r RunnerResult) Formatter {
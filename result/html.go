@@ -0,0 +1,153 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package result
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"preflight/checker"
+)
+
+//go:embed report.html.tmpl
+var htmlTemplate string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"duration": formatDuration,
+}).Parse(htmlTemplate))
+
+// localHost is the section name of checkers evaluated on local host.
+const localHost = "localhost"
+
+var htmlResults = []string{resultFailed, resultError, resultTimedOut, resultWarning, resultSkipped, resultPassed}
+
+type htmlReport struct {
+	GeneratedAt string
+	Interrupted bool
+	Results     []string
+	Levels      []htmlLevel
+	Total       htmlLevel
+	Entries     []entry
+	Hosts       []htmlHost
+}
+
+// htmlLevel counts checkers of a level by result.
+type htmlLevel struct {
+	Level  string
+	Counts []int
+}
+
+// htmlHost is the section of checkers or details evaluated on the host.
+type htmlHost struct {
+	Name string
+	Rows []htmlHostRow
+}
+
+type htmlHostRow struct {
+	CheckerName string
+	Item        string
+	Status      string
+	Observed    string
+	Expected    string
+	Message     string
+}
+
+// writeHTML write a single HTML file which could be viewed offline, all styles and scripts are inlined.
+func writeHTML(w io.Writer, resp Response) error {
+	es := entries(resp)
+	report := htmlReport{
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		Interrupted: resp.Interrupted,
+		Results:     htmlResults,
+		Entries:     es,
+		Levels:      htmlLevels(es),
+		Hosts:       htmlHosts(es),
+	}
+	report.Total = htmlLevel{Level: "total", Counts: make([]int, len(htmlResults))}
+	for _, l := range report.Levels {
+		for i, n := range l.Counts {
+			report.Total.Counts[i] += n
+		}
+	}
+	return reportTemplate.Execute(w, report)
+}
+
+func htmlLevels(es []entry) []htmlLevel {
+	levels := make([]htmlLevel, 0, len(checker.Levels))
+	for _, level := range checker.Levels {
+		l := htmlLevel{Level: level, Counts: make([]int, len(htmlResults))}
+		for _, e := range es {
+			if e.level() != level {
+				continue
+			}
+			for i, r := range htmlResults {
+				if e.Result == r {
+					l.Counts[i]++
+				}
+			}
+		}
+		levels = append(levels, l)
+	}
+	return levels
+}
+
+// htmlHosts group checkers by the host evaluated on, the details reported with host are grouped
+// to the host of the detail, like the ones of cluster checker.
+func htmlHosts(es []entry) []htmlHost {
+	rows := make(map[string][]htmlHostRow)
+	for _, e := range es {
+		host := e.Host
+		if host == "" {
+			host = localHost
+		}
+		rows[host] = append(rows[host], htmlHostRow{
+			CheckerName: e.CheckerName,
+			Status:      e.Result,
+			Observed:    e.Observed,
+			Expected:    e.Expected,
+			Message:     e.message(),
+		})
+		for _, d := range e.Details {
+			if d.Host == "" || d.Host == e.Host {
+				continue
+			}
+			rows[d.Host] = append(rows[d.Host], htmlHostRow{
+				CheckerName: e.CheckerName,
+				Item:        d.Name,
+				Status:      string(d.Status),
+				Observed:    d.Observed,
+				Expected:    d.Expected,
+				Message:     d.Message,
+			})
+		}
+	}
+
+	hosts := make([]htmlHost, 0, len(rows))
+	for name, r := range rows {
+		hosts = append(hosts, htmlHost{Name: name, Rows: r})
+	}
+	// local host first, then the remote hosts by name.
+	sort.Slice(hosts, func(i, j int) bool {
+		if (hosts[i].Name == localHost) != (hosts[j].Name == localHost) {
+			return hosts[i].Name == localHost
+		}
+		return strings.Compare(hosts[i].Name, hosts[j].Name) < 0
+	})
+	return hosts
+}
@@ -0,0 +1,155 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package result
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// junitTestSuites is the root of JUnit XML report, each checker is a testcase.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+func writeJUnit(w io.Writer, resp Response) error {
+	suite := junitTestSuite{Name: "preflight"}
+	var (
+		total float64
+		start time.Time
+	)
+	for _, e := range entries(resp) {
+		tc := junitTestCase{
+			Name:      e.CheckerName,
+			Classname: "preflight." + e.CheckerType,
+			Time:      formatSeconds(e.DurationSeconds),
+			SystemOut: junitOutput(e),
+		}
+		msg := &junitMessage{Message: e.message(), Type: e.level(), Text: junitText(e)}
+		switch e.Result {
+		case resultFailed:
+			tc.Failure = msg
+			suite.Failures++
+		case resultError, resultTimedOut:
+			tc.Error = msg
+			suite.Errors++
+		case resultSkipped:
+			tc.Skipped = &junitMessage{Message: e.message()}
+			suite.Skipped++
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, tc)
+
+		total += e.DurationSeconds
+		if e.StartTime != nil && (start.IsZero() || e.StartTime.Before(start)) {
+			start = *e.StartTime
+		}
+	}
+	suite.Time = formatSeconds(total)
+	if !start.IsZero() {
+		suite.Timestamp = start.Format(time.RFC3339)
+	}
+
+	report := junitTestSuites{
+		Name:     suite.Name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func formatSeconds(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
+
+// junitOutput show the observed and expected values of checker.
+func junitOutput(e entry) string {
+	var lines []string
+	if e.Host != "" {
+		lines = append(lines, "host: "+e.Host)
+	}
+	if e.Observed != "" || e.Expected != "" {
+		lines = append(lines, fmt.Sprintf("observed: %s, expected: %s", e.Observed, e.Expected))
+	}
+	for _, d := range e.Details {
+		lines = append(lines, strings.TrimSpace(fmt.Sprintf("%s [%s] observed: %s, expected: %s %s",
+			d.Name, d.Status, d.Observed, d.Expected, d.Message)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// junitText show the message with explain and suggestion of checker.
+func junitText(e entry) string {
+	lines := []string{e.message()}
+	if e.Metadata != nil {
+		if e.Metadata.Explain != "" {
+			lines = append(lines, "explain: "+e.Metadata.Explain)
+		}
+		if e.Metadata.Suggestion != "" {
+			lines = append(lines, "suggestion: "+e.Metadata.Suggestion)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,152 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package result
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"preflight/checker"
+)
+
+// the metric names and labels are part of API, alerts are built on them, do not change them.
+const (
+	MetricCheckPassed   = "preflight_check_passed"
+	MetricCheckDuration = "preflight_check_duration_seconds"
+	MetricLastRun       = "preflight_last_run_timestamp"
+)
+
+var metricLabels = []string{"type", "name", "level", "host"}
+
+type metricSample struct {
+	labels   []string
+	passed   float64
+	duration float64
+}
+
+// WritePrometheus write the response in Prometheus text format, timestamp is the time of the run.
+// checker passed or warned is regarded as passed, the duplicated checkers on the same host keep the first one.
+// the details reported with host are sampled on the host of the detail too, like the ones of cluster checker.
+func WritePrometheus(w io.Writer, resp Response, timestamp time.Time) error {
+	var samples []metricSample
+	seen := make(map[string]bool)
+	add := func(e entry, host string, passed bool) {
+		if host == "" {
+			host = localHost
+		}
+		labels := []string{e.CheckerType, e.CheckerName, e.level(), host}
+		key := strings.Join(labels, "\x00")
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		sample := metricSample{labels: labels, duration: e.DurationSeconds}
+		if passed {
+			sample.passed = 1
+		}
+		samples = append(samples, sample)
+	}
+	for _, e := range entries(resp) {
+		add(e, e.Host, e.Result == resultPassed || e.Result == resultWarning)
+		for _, d := range e.Details {
+			if d.Host != "" && d.Host != e.Host {
+				add(e, d.Host, d.Status == checker.StatusPass || d.Status == checker.StatusWarn)
+			}
+		}
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return strings.Join(samples[i].labels, "\x00") < strings.Join(samples[j].labels, "\x00")
+	})
+
+	bw := bufio.NewWriter(w)
+	writeMetricHeader(bw, MetricCheckPassed, "Whether the checker passed, 1 means passed and 0 means not.")
+	for _, s := range samples {
+		writeMetric(bw, MetricCheckPassed, s.labels, s.passed)
+	}
+	writeMetricHeader(bw, MetricCheckDuration, "Duration of the checker evaluated in seconds.")
+	for _, s := range samples {
+		writeMetric(bw, MetricCheckDuration, s.labels, s.duration)
+	}
+	writeMetricHeader(bw, MetricLastRun, "Unix timestamp of the last preflight run in seconds.")
+	writeMetric(bw, MetricLastRun, nil, float64(timestamp.UnixNano())/1e9)
+	return bw.Flush()
+}
+
+// ExportPrometheus write the response in Prometheus text format to path atomically,
+// so the textfile collector of node_exporter never reads a partial file.
+func ExportPrometheus(path string, resp Response, timestamp time.Time) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if err := WritePrometheus(f, resp, timestamp); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func writePrometheus(w io.Writer, resp Response) error {
+	return WritePrometheus(w, resp, time.Now())
+}
+
+func writeMetricHeader(w *bufio.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+}
+
+func writeMetric(w *bufio.Writer, name string, labels []string, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", metricLabels[i], escapeLabelValue(l))
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	w.WriteByte('\n')
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueReplacer.Replace(v)
+}
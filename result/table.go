@@ -0,0 +1,101 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package result
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+var tableHeader = []string{"STATUS", "CHECKER NAME", "CHECKER TYPE", "LEVEL", "HOST", "OBSERVED", "EXPECTED", "DURATION", "MESSAGE"}
+
+var statusColors = map[string]tablewriter.Colors{
+	resultPassed:   {tablewriter.Bold, tablewriter.FgGreenColor},
+	resultFailed:   {tablewriter.Bold, tablewriter.FgRedColor},
+	resultError:    {tablewriter.Bold, tablewriter.FgMagentaColor},
+	resultTimedOut: {tablewriter.Bold, tablewriter.FgMagentaColor},
+	resultWarning:  {tablewriter.Bold, tablewriter.FgYellowColor},
+	resultSkipped:  {tablewriter.FgHiBlackColor},
+}
+
+func writeTable(w io.Writer, resp Response) error {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader(tableHeader)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	appendRows(table, resp, isTerminal(w), func(s string) string { return s })
+	table.Render()
+	return writeInterrupted(w, resp)
+}
+
+func writeMarkdown(w io.Writer, resp Response) error {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader(tableHeader)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetAutoWrapText(false)
+	table.SetAutoFormatHeaders(false)
+	appendRows(table, resp, isTerminal(w), func(s string) string {
+		return strings.NewReplacer("|", `\|`, "\n", "<br>").Replace(s)
+	})
+	table.Render()
+	return writeInterrupted(w, resp)
+}
+
+// appendRows append a row for each checker, escape is applied to the text of cells.
+func appendRows(table *tablewriter.Table, resp Response, colored bool, escape func(string) string) {
+	for _, e := range entries(resp) {
+		row := []string{
+			e.Result,
+			e.CheckerName,
+			e.CheckerType,
+			e.level(),
+			e.Host,
+			e.Observed,
+			e.Expected,
+			formatDuration(e.DurationSeconds),
+			e.message(),
+		}
+		for i := range row {
+			row[i] = escape(row[i])
+		}
+		if !colored {
+			table.Append(row)
+			continue
+		}
+		colors := make([]tablewriter.Colors, len(row))
+		colors[0] = statusColors[e.Result]
+		table.Rich(row, colors)
+	}
+}
+
+func formatDuration(seconds float64) string {
+	if seconds == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(seconds, 'f', 3, 64) + "s"
+}
+
+func writeInterrupted(w io.Writer, resp Response) error {
+	if !resp.Interrupted {
+		return nil
+	}
+	_, err := fmt.Fprintln(w, "\nthe run is interrupted, not all checkers finished.")
+	return err
+}
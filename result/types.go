@@ -14,34 +14,71 @@
 
 package result
 
-import "preflight/checker"
+import (
+	"preflight/checker"
+	"time"
+)
 
 type CheckResult struct {
 	Checker      checker.Interface
-	Passed       bool   `json:"passed"`
-	ErrorMessage string `json:"error_message"`
+	Status       checker.Status `json:"status"`
+	Passed       bool           `json:"passed"`
+	ErrorMessage string         `json:"error_message"`
+	// Reason why the checker is skipped.
+	Reason string `json:"reason,omitempty"`
+	// StartTime and Duration of the checker evaluated.
+	StartTime time.Time     `json:"start_time"`
+	Duration  time.Duration `json:"duration"`
+	// Host where the checker evaluated, empty means local host.
+	Host     string           `json:"host,omitempty"`
+	Observed string           `json:"observed,omitempty"`
+	Expected string           `json:"expected,omitempty"`
+	Details  []checker.Detail `json:"details,omitempty"`
 }
 
 type RunnerResult struct {
 	// result meet the required
 	Passed []CheckResult
-	// setup checker meet error
+	// result not meet the required
 	Failed []CheckResult
-	// if user ignore the checker result will downgrade the level to Warnings result.
+	// setup checker meet error, so it is unknown whether the result meet the required.
+	Errors []CheckResult
+	// result meet the required but reported warnings,
+	// if user ignore the checker result will also downgrade the level to Warnings result.
 	Warnings []CheckResult
+	// checker not finished before its deadline.
+	TimedOut []CheckResult
+	// checker not run because its dependencies not passed.
+	Skipped []CheckResult
+	// Interrupted is true if the runner stopped before all checkers finished,
+	// because of the global deadline or being canceled.
+	Interrupted bool
 }
 
 type Descriptor struct {
-	CheckerName  string            `json:"checker_name"`
-	CheckerType  string            `json:"checker_type"`
-	IsPassed     bool              `json:"is_passed,omitempty"`
-	ErrorMessage string            `json:"error_message,omitempty"`
-	Metadata     *checker.Metadata `json:"metadata,omitempty"`
+	CheckerName     string            `json:"checker_name" yaml:"checker_name"`
+	CheckerType     string            `json:"checker_type" yaml:"checker_type"`
+	Status          checker.Status    `json:"status" yaml:"status"`
+	IsPassed        bool              `json:"is_passed,omitempty" yaml:"is_passed,omitempty"`
+	ErrorMessage    string            `json:"error_message,omitempty" yaml:"error_message,omitempty"`
+	Reason          string            `json:"reason,omitempty" yaml:"reason,omitempty"`
+	StartTime       *time.Time        `json:"start_time,omitempty" yaml:"start_time,omitempty"`
+	DurationSeconds float64           `json:"duration_seconds,omitempty" yaml:"duration_seconds,omitempty"`
+	Host            string            `json:"host,omitempty" yaml:"host,omitempty"`
+	Observed        string            `json:"observed,omitempty" yaml:"observed,omitempty"`
+	Expected        string            `json:"expected,omitempty" yaml:"expected,omitempty"`
+	Details         []checker.Detail  `json:"details,omitempty" yaml:"details,omitempty"`
+	Metadata        *checker.Metadata `json:"metadata,omitempty" yaml:"metadata,omitempty"`
 }
 
 // Response used to format for show check report.
 type Response struct {
-	Passed   []Descriptor `json:"passed,omitempty"`
-	Failed   []Descriptor `json:"failed,omitempty"`
-	Warnings []Descriptor `json:"warnings,omitempty"`
+	Passed   []Descriptor `json:"passed,omitempty" yaml:"passed,omitempty"`
+	Failed   []Descriptor `json:"failed,omitempty" yaml:"failed,omitempty"`
+	Errors   []Descriptor `json:"errors,omitempty" yaml:"errors,omitempty"`
+	Warnings []Descriptor `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+	TimedOut []Descriptor `json:"timed_out,omitempty" yaml:"timed_out,omitempty"`
+	Skipped  []Descriptor `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+	// Interrupted means the report is partial, not all checkers finished.
+	Interrupted bool `json:"interrupted,omitempty" yaml:"interrupted,omitempty"`
 }
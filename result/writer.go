@@ -0,0 +1,145 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package result
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Writer write the response to w in specific format.
+type Writer func(w io.Writer, resp Response) error
+
+var writers = map[string]Writer{
+	"json":       writeJSON,
+	"yaml":       writeYAML,
+	"table":      writeTable,
+	"markdown":   writeMarkdown,
+	"junit":      writeJUnit,
+	"html":       writeHTML,
+	"prometheus": writePrometheus,
+}
+
+// RegisterWriter register the writer of format, the writer registered before is replaced.
+func RegisterWriter(format string, w Writer) {
+	writers[strings.ToLower(format)] = w
+}
+
+// GetWriter return the writer of format, or error if format not registered.
+func GetWriter(format string) (Writer, error) {
+	w, ok := writers[strings.ToLower(format)]
+	if !ok {
+		return nil, errors.Errorf("unknown output format %q, expected one of %s", format, strings.Join(GetAllFormats(), ","))
+	}
+	return w, nil
+}
+
+// GetAllFormats return all formats registered in order.
+func GetAllFormats() []string {
+	formats := make([]string, 0, len(writers))
+	for f := range writers {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+func writeJSON(w io.Writer, resp Response) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	// keep the expected values like ">=2" readable.
+	enc.SetEscapeHTML(false)
+	return enc.Encode(resp)
+}
+
+func writeYAML(w io.Writer, resp Response) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(resp); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// entry is a descriptor with the name of the result it belongs to.
+type entry struct {
+	Descriptor
+	Result string
+}
+
+const (
+	resultPassed   = "pass"
+	resultFailed   = "fail"
+	resultError    = "error"
+	resultWarning  = "warn"
+	resultTimedOut = "timeout"
+	resultSkipped  = "skip"
+)
+
+// entries return descriptors of the response, the ones need attention go first.
+func entries(resp Response) []entry {
+	var es []entry
+	for _, group := range []struct {
+		result      string
+		descriptors []Descriptor
+	}{
+		{resultFailed, resp.Failed},
+		{resultError, resp.Errors},
+		{resultTimedOut, resp.TimedOut},
+		{resultWarning, resp.Warnings},
+		{resultSkipped, resp.Skipped},
+		{resultPassed, resp.Passed},
+	} {
+		for _, d := range group.descriptors {
+			es = append(es, entry{Descriptor: d, Result: group.result})
+		}
+	}
+	return es
+}
+
+// message return why the checker not passed.
+func (e entry) message() string {
+	if e.ErrorMessage != "" {
+		return e.ErrorMessage
+	}
+	return e.Reason
+}
+
+// level return the level of checker.
+func (e entry) level() string {
+	if e.Metadata == nil {
+		return ""
+	}
+	return e.Metadata.Level
+}
+
+// isTerminal return true if w is a terminal, NO_COLOR disables it.
+func isTerminal(w io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -16,25 +16,41 @@ package runner
 
 import (
 	"preflight/checker"
-	"preflight/checker/cluster/api/types"
+	"preflight/plan"
 )
 
+// BuildInitCheckers return the build-in checkers used when no plan file specified.
+// cluster checker is not included, since it requires the cluster inventory of plan file.
 func BuildInitCheckers() []checker.Interface {
 	return []checker.Interface{
-		checker.ClusterCheck{
-			AuthInfo: types.ClusterInfoBrief{
-				SshUser:     "root",
-				SshPassword: "Seadent123",
-				Hosts:       []string{"172.16.0.198"},
-			},
-		},
 		//checker.PortCheck{Port: 6443},
 		//checker.NumCPUCheck{NumCPU: 2},
 		//checker.MemCheck{Mem: 1700},
 		checker.OsCheck{
 			OSType:         "linux",
-			OSDistribution: []string{"ubuntu", "centos"},
-			// Requires 3.10+, or newer
-			KernelVersions: []string{`^3\.[1-9][0-9].*$`, `^([4-9]|[1-9][0-9]+)\.([0-9]+)\.([0-9]+).*$`}},
+			OSDistribution: checker.DefaultOSDistributions,
+			KernelVersions: checker.DefaultKernelVersions},
 	}
 }
+
+// BuildPlanCheckers load the plan file and return the checkers declared in it.
+func BuildPlanCheckers(path string) ([]checker.Interface, error) {
+	p, err := plan.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.Checkers, nil
+}
+
+// BuildSpecCheckers build checkers from checker specs, like "memory:2048" or "port:6443".
+func BuildSpecCheckers(specs []string) ([]checker.Interface, error) {
+	var checks []checker.Interface
+	for _, spec := range specs {
+		c, err := checker.ParseSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
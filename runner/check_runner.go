@@ -15,8 +15,12 @@
 package runner
 
 import (
+	"context"
+	"fmt"
 	"preflight/checker"
 	"preflight/result"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -29,43 +33,315 @@ type CheckRunner struct {
 
 // Execute checker validate and dispatch to different results
 func (c *CheckRunner) Execute() result.RunnerResult {
-	for _, check := range c.Checks {
-		// if not tolerance failed checker,return immediately
-		if c.Options.NotTolerable && len(c.Results.Failed) > 0 {
-			return c.Results
-		}
+	return c.ExecuteContext(context.Background())
+}
+
+// outcome of one checker, bucket is nil if the checker is interrupted.
+type outcome struct {
+	bucket *[]result.CheckResult
+	result result.CheckResult
+}
+
+// ExecuteContext is the same as Execute, but stops once ctx is done or the global timeout reached.
+// checker not finished before its deadline is dispatched to TimedOut results.
+// Checkers run concurrently up to Options.Concurrency, results are kept in the order of Checks.
+func (c *CheckRunner) ExecuteContext(ctx context.Context) result.RunnerResult {
+	if c.Options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Options.Timeout)
+		defer cancel()
+	}
+
+	concurrency := c.Options.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
 
+	// dependencies are validated by NewCheckRunner.
+	deps, err := dependencyIndexes(c.Checks)
+	if err != nil {
+		deps = make([][]int, len(c.Checks))
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failed   = c.failFast(c.Results.Failed...) || c.failFast(c.Results.Errors...)
+		outcomes = make([]*outcome, len(c.Checks))
+		done     = make([]chan struct{}, len(c.Checks))
+		// exclusive checker holds the write lock, so it runs alone.
+		exclusive sync.RWMutex
+		workers   = make(chan struct{}, concurrency)
+	)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	hasFailed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return failed
+	}
+
+dispatch:
+	for i, check := range c.Checks {
 		if !NotIn(check.Type(), c.Options.Skips) {
+			close(done[i])
 			continue
 		}
 
-		// run the validation
-		passed, err := check.Validate()
-		if err != nil {
-			c.Results.Failed = append(c.Results.Failed, result.CheckResult{
-				Checker:      check,
-				Passed:       false,
-				ErrorMessage: err.Error(),
-			})
-			continue
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+			c.Results.Interrupted = true
+			break dispatch
+		}
+
+		// if not tolerance failed checker,stop running the remaining checkers
+		if c.Options.NotTolerable && hasFailed() {
+			<-workers
+			mu.Lock()
+			c.skipRemaining(c.Checks[i:], outcomes[i:])
+			mu.Unlock()
+			break
+		}
+		if ctx.Err() != nil {
+			<-workers
+			c.Results.Interrupted = true
+			break
 		}
 
-		c.Results.Passed = append(c.Results.Passed, result.CheckResult{
-			Checker: check,
-			Passed:  passed,
-		})
+		wg.Add(1)
+		go func(i int, check checker.Interface) {
+			defer wg.Done()
+			defer close(done[i])
+			defer func() { <-workers }()
+
+			// checkers depended on are dispatched before, wait for them to finish.
+			for _, dep := range deps[i] {
+				select {
+				case <-done[dep]:
+				case <-ctx.Done():
+				}
+			}
+			if ctx.Err() != nil {
+				mu.Lock()
+				outcomes[i] = &outcome{}
+				mu.Unlock()
+				return
+			}
+
+			mu.Lock()
+			reason := c.unmetDependency(deps[i], outcomes)
+			if reason != "" {
+				outcomes[i] = &outcome{
+					bucket: &c.Results.Skipped,
+					result: result.CheckResult{Checker: check, Status: checker.StatusSkip, Reason: reason},
+				}
+			}
+			mu.Unlock()
+			if reason != "" {
+				return
+			}
+
+			unlock, err := lockContext(ctx, &exclusive, checker.IsExclusive(check))
+			if err != nil {
+				mu.Lock()
+				outcomes[i] = &outcome{}
+				mu.Unlock()
+				return
+			}
+			// the checker timed out may still run in background, keep the lock until it really returns.
+			ctx, wait := checker.WithBackground(ctx)
+			defer func() {
+				go func() {
+					wait()
+					unlock()
+				}()
+			}()
+
+			// checker waiting for the lock or its dependencies is not run after fail fast.
+			if c.Options.NotTolerable && hasFailed() {
+				mu.Lock()
+				c.skipRemaining([]checker.Interface{check}, outcomes[i:i+1])
+				mu.Unlock()
+				return
+			}
+
+			o := c.run(ctx, check)
+			mu.Lock()
+			outcomes[i] = o
+			if (o.bucket == &c.Results.Failed || o.bucket == &c.Results.Errors) && c.failFast(o.result) {
+				failed = true
+			}
+			mu.Unlock()
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, o := range outcomes {
+		if o == nil {
+			continue
+		}
+		if o.bucket == nil {
+			c.Results.Interrupted = true
+			continue
+		}
+		*o.bucket = append(*o.bucket, o.result)
 	}
 
 	return c.Results
 }
 
+// lockContext acquire the lock, exclusively if the checker is exclusive. it returns ctx.Err() if ctx is done
+// before the lock acquired, the lock acquired later is released at once.
+func lockContext(ctx context.Context, l *sync.RWMutex, exclusive bool) (unlock func(), err error) {
+	lock, unlock := l.RLock, l.RUnlock
+	if exclusive {
+		lock, unlock = l.Lock, l.Unlock
+	}
+
+	locked := make(chan struct{})
+	go func() {
+		lock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		return unlock, nil
+	case <-ctx.Done():
+		go func() {
+			<-locked
+			unlock()
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// unmetDependency return the reason why the checker should be skipped if any of its dependencies not passed.
+func (c *CheckRunner) unmetDependency(deps []int, outcomes []*outcome) string {
+	for _, dep := range deps {
+		id := checker.ID(c.Checks[dep])
+		o := outcomes[dep]
+		switch {
+		case o == nil:
+			return fmt.Sprintf("dependency %s was skipped", id)
+		case o.bucket == &c.Results.Failed:
+			return fmt.Sprintf("dependency %s failed", id)
+		case o.bucket == &c.Results.Errors:
+			return fmt.Sprintf("dependency %s encountered error", id)
+		case o.bucket == &c.Results.TimedOut:
+			return fmt.Sprintf("dependency %s timed out", id)
+		case o.bucket == &c.Results.Skipped:
+			return fmt.Sprintf("dependency %s was skipped", id)
+		}
+	}
+	return ""
+}
+
+// skipRemaining dispatch the checkers not run after fail fast to Skipped results.
+func (c *CheckRunner) skipRemaining(checks []checker.Interface, outcomes []*outcome) {
+	for i, check := range checks {
+		if !NotIn(check.Type(), c.Options.Skips) {
+			continue
+		}
+		outcomes[i] = &outcome{
+			bucket: &c.Results.Skipped,
+			result: result.CheckResult{
+				Checker: check,
+				Status:  checker.StatusSkip,
+				Reason:  fmt.Sprintf("not run after a checker at or above %s level failed", c.Options.FailFastLevel),
+			},
+		}
+	}
+}
+
+// failFast return true if any of the failed results is at or above the FailFastLevel.
+func (c *CheckRunner) failFast(results ...result.CheckResult) bool {
+	for _, r := range results {
+		if checker.Severity(r.Checker.Metadata().Level) >= checker.Severity(c.Options.FailFastLevel) {
+			return true
+		}
+	}
+	return false
+}
+
+// run the evaluation and dispatch the result to its bucket by the status reported.
+func (c *CheckRunner) run(ctx context.Context, check checker.Interface) *outcome {
+	start := time.Now()
+	report, err := c.evaluate(ctx, check)
+	if err != nil && ctx.Err() == context.Canceled {
+		return &outcome{}
+	}
+
+	r := result.CheckResult{
+		Checker:   check,
+		Status:    report.Status,
+		StartTime: start,
+		Duration:  time.Since(start),
+		Host:      report.Host,
+		Observed:  report.Observed,
+		Expected:  report.Expected,
+		Details:   report.Details,
+	}
+	switch {
+	case err == context.DeadlineExceeded:
+		r.Status = checker.StatusError
+		r.ErrorMessage = "checker not finished before deadline"
+		return &outcome{bucket: &c.Results.TimedOut, result: r}
+	case err != nil:
+		r.Status = checker.StatusError
+		r.ErrorMessage = err.Error()
+		return &outcome{bucket: &c.Results.Errors, result: r}
+	}
+
+	switch report.Status {
+	case checker.StatusPass:
+		r.Passed = true
+		return &outcome{bucket: &c.Results.Passed, result: r}
+	case checker.StatusWarn:
+		r.Passed = true
+		r.ErrorMessage = report.Message
+		return &outcome{bucket: &c.Results.Warnings, result: r}
+	case checker.StatusSkip:
+		r.Reason = report.Message
+		return &outcome{bucket: &c.Results.Skipped, result: r}
+	default:
+		// unknown status is regarded as not met, the zero report must not be passed.
+		r.Status = checker.StatusFail
+		r.ErrorMessage = report.Message
+		return &outcome{bucket: &c.Results.Failed, result: r}
+	}
+}
+
+// evaluate the checker with per-check timeout.
+func (c *CheckRunner) evaluate(ctx context.Context, check checker.Interface) (checker.Report, error) {
+	if c.Options.CheckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Options.CheckTimeout)
+		defer cancel()
+	}
+
+	report, err := checker.Evaluate(ctx, check)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return checker.Report{}, context.DeadlineExceeded
+	}
+	return report, err
+}
+
 // NewCheckRunner select Checklist via build-in check map.
 // if len(Checklist)==0 ,return not specified error.
+// Checklist is ordered by dependencies, unknown dependency or dependency cycle is reported as error.
 func NewCheckRunner(checkList []checker.Interface, opts ...Option) (Runner, error) {
 	if len(checkList) == 0 {
 		return nil, errors.New("Checklist could not be nil")
 	}
 
+	checkList, err := sortByDependencies(checkList)
+	if err != nil {
+		return nil, err
+	}
+
 	options := defaultRunOptions
 	for _, opt := range opts {
 		opt(&options)
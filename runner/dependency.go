@@ -0,0 +1,99 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"strings"
+
+	"preflight/checker"
+
+	"github.com/pkg/errors"
+)
+
+// dependencyIndexes return the indexes of checkers which each checker depends on.
+func dependencyIndexes(checks []checker.Interface) ([][]int, error) {
+	ids := make(map[string][]int, len(checks))
+	for i, c := range checks {
+		id := checker.ID(c)
+		ids[id] = append(ids[id], i)
+	}
+
+	deps := make([][]int, len(checks))
+	for i, c := range checks {
+		for _, dep := range checker.Dependencies(c) {
+			indexes, exists := ids[dep]
+			if !exists {
+				return nil, errors.Errorf("checker %s depends on unknown checker %s", checker.ID(c), dep)
+			}
+			deps[i] = append(deps[i], indexes...)
+		}
+	}
+	return deps, nil
+}
+
+// sortByDependencies order checkers so that each checker comes after the checkers it depends on,
+// the declared order is kept as much as possible. dependency cycle is reported as error.
+func sortByDependencies(checks []checker.Interface) ([]checker.Interface, error) {
+	deps, err := dependencyIndexes(checks)
+	if err != nil {
+		return nil, err
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	var (
+		state  = make([]int, len(checks))
+		sorted = make([]checker.Interface, 0, len(checks))
+		path   []int
+		visit  func(i int) error
+	)
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			var cycle []string
+			for j := len(path) - 1; j >= 0; j-- {
+				cycle = append([]string{checker.ID(checks[path[j]])}, cycle...)
+				if path[j] == i {
+					break
+				}
+			}
+			return errors.Errorf("dependency cycle found: %s -> %s", strings.Join(cycle, " -> "), checker.ID(checks[i]))
+		}
+
+		state[i] = visiting
+		path = append(path, i)
+		for _, dep := range deps[i] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		sorted = append(sorted, checks[i])
+		return nil
+	}
+
+	for i := range checks {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
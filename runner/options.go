@@ -14,21 +14,35 @@
 
 package runner
 
-import "strings"
+import (
+	"preflight/checker"
+	"strings"
+	"time"
+)
 
 type RunOptions struct {
 	// Skips checker Validate by checker type,default is lowercase.
 	Skips []string
 	// IsTolerable return immediately when an error is reported.
 	NotTolerable bool
+	// FailFastLevel only the error reported by checker at or above this level makes NotTolerable return.
+	FailFastLevel string
+	// CheckTimeout limits the time of each checker Validate, zero means no limit.
+	CheckTimeout time.Duration
+	// Timeout limits the time of the whole run, zero means no limit.
+	Timeout time.Duration
+	// Concurrency the max number of checkers run at the same time.
+	Concurrency int
 }
 
 // Option configures a runner list
 type Option func(*RunOptions)
 
 var defaultRunOptions = RunOptions{
-	Skips:        []string{},
-	NotTolerable: false,
+	Skips:         []string{},
+	NotTolerable:  false,
+	FailFastLevel: checker.InfoLevel,
+	Concurrency:   1,
 }
 
 func WithSkips(skips []string) Option {
@@ -48,3 +62,28 @@ func WithToleration(it bool) Option {
 		o.NotTolerable = it
 	}
 }
+
+// WithFailFastLevel set the lowest level of failed checker which makes NotTolerable return.
+func WithFailFastLevel(level string) Option {
+	return func(o *RunOptions) {
+		o.FailFastLevel = level
+	}
+}
+
+func WithCheckTimeout(timeout time.Duration) Option {
+	return func(o *RunOptions) {
+		o.CheckTimeout = timeout
+	}
+}
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *RunOptions) {
+		o.Timeout = timeout
+	}
+}
+
+func WithConcurrency(n int) Option {
+	return func(o *RunOptions) {
+		o.Concurrency = n
+	}
+}
@@ -15,12 +15,16 @@
 package runner
 
 import (
+	"context"
 	"preflight/result"
 )
 
 type Runner interface {
 	// Execute all given Checkers.
 	Execute() result.RunnerResult
+	// ExecuteContext execute all given Checkers until ctx is done,
+	// the result only contains the checkers finished.
+	ExecuteContext(ctx context.Context) result.RunnerResult
 }
 
 func NewDefaultRunner(opts ...Option) (Runner, error) {
@@ -0,0 +1,117 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"preflight/checker"
+)
+
+func fakeBinaries(t *testing.T, scripts map[string]string) {
+	t.Helper()
+	root := fakeHost(t, scripts)
+	for name := range scripts {
+		if err := os.Chmod(filepath.Join(root, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", "/usr/local/bin:/usr/bin")
+}
+
+func TestBinaryCheck(t *testing.T) {
+	fakeBinaries(t, map[string]string{
+		"usr/sbin/iptables":      "#!/bin/sh\necho 'iptables v1.8.4 (nf_tables)'\n",
+		"usr/bin/socat":          "#!/bin/sh\n[ \"$1\" = -V ] || exit 1\necho 'socat by Gerhard Rieger and contributors - see www.dest-unreach.org'\necho 'socat version 1.7.3.2 on Apr  4 2018 08:46:01'\n",
+		"usr/bin/tar":            "#!/bin/sh\necho 'tar (GNU tar) 1.30'\n",
+		"opt/helm/bin/helm":      "#!/bin/sh\necho 'v3.9.4+gdbc6d8e'\n",
+		"usr/sbin/conntrack":     "#!/bin/sh\necho 'conntrack v1.4.4 (conntrack-tools)' >&2\nexit 1\n",
+		"usr/local/bin/ebtables": "not executable",
+	})
+	if err := os.Chmod(filepath.Join(checker.HostRoot, "usr/local/bin/ebtables"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := checker.ParseSpec("binary:iptables>=1.8.4 <2;socat>=1.7;tar;/opt/helm/bin/helm>=3.10;conntrack>=1.4;ebtables;ipset")
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	report, err := checker.Evaluate(context.Background(), c)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	expected := []struct {
+		name     string
+		status   checker.Status
+		observed string
+	}{
+		{"iptables", checker.StatusPass, "/usr/sbin/iptables 1.8.4"},
+		{"socat", checker.StatusPass, "/usr/bin/socat 1.7.3.2"},
+		{"tar", checker.StatusPass, "/usr/bin/tar"},
+		{"helm", checker.StatusFail, "/opt/helm/bin/helm 3.9.4"},
+		{"conntrack", checker.StatusPass, "/usr/sbin/conntrack 1.4.4"},
+		{"ebtables", checker.StatusFail, "not found"},
+		{"ipset", checker.StatusFail, "not found"},
+	}
+	if report.Status != checker.StatusFail || len(report.Details) != len(expected) {
+		t.Fatalf("expected failed, but got %+v", report)
+	}
+	for i, e := range expected {
+		d := report.Details[i]
+		if d.Name != e.name || d.Status != e.status || d.Observed != e.observed {
+			t.Errorf("expected %s %s observed %s, but got %+v", e.name, e.status, e.observed, d)
+		}
+	}
+	if report.Observed != "missing ebtables,ipset; version mismatched helm 3.9.4" {
+		t.Errorf("unexpected observed %q", report.Observed)
+	}
+	if msg := report.Details[3].Message; msg != "version 3.9.4 of /opt/helm/bin/helm does not match >=3.10" {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestBinaryCheckVersionCommand(t *testing.T) {
+	fakeBinaries(t, map[string]string{
+		"usr/bin/kubectl": "#!/bin/sh\n[ \"$1 $2\" = 'version --client' ] || { echo unknown flag >&2; exit 1; }\necho 'Client Version: v1.24.3'\n",
+	})
+
+	report, err := checker.Evaluate(context.Background(), checker.BinaryCheck{Binaries: []checker.Binary{
+		{Name: "kubectl", Version: ">=1.24", VersionArgs: []string{"version", "--client"}, VersionRegexp: `Client Version: (v\S+)`},
+		{Name: "kubectl", Version: ">=1.24"},
+	}})
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if report.Status != checker.StatusFail || len(report.Details) != 2 {
+		t.Fatalf("expected failed, but got %+v", report)
+	}
+	if d := report.Details[0]; d.Status != checker.StatusPass || d.Observed != "/usr/bin/kubectl v1.24.3" {
+		t.Errorf("expected kubectl passed, but got %+v", d)
+	}
+	if d := report.Details[1]; d.Status != checker.StatusError || d.Message != "failed to run /usr/bin/kubectl --version: unknown flag: exit status 1" {
+		t.Errorf("expected error of version command, but got %+v", d)
+	}
+}
+
+func TestBinaryCheckInvalidSpec(t *testing.T) {
+	for _, spec := range []string{"binary:", "binary:iptables>=x", "binary:bin/helm", "binary:>=1.0"} {
+		if _, err := checker.ParseSpec(spec); err == nil {
+			t.Errorf("expected error for %s", spec)
+		}
+	}
+}
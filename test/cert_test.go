@@ -0,0 +1,233 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"preflight/checker"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM string
+	keyPEM  string
+}
+
+// newTestCert issue the certificate valid in [notBefore, notAfter] by the parent, it is self-signed if parent is nil.
+func newTestCert(t *testing.T, cn string, parent *testCert, isCA bool, notBefore, notAfter time.Time, sans ...string) testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, san)
+		}
+	}
+	if isCA {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		keyPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+	}
+}
+
+func TestCertCheck(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+	ca := newTestCert(t, "kubernetes", nil, true, now.Add(-day), now.Add(3650*day))
+	otherCA := newTestCert(t, "etcd-ca", nil, true, now.Add(-day), now.Add(3650*day))
+	apiserver := newTestCert(t, "kube-apiserver", &ca, false, now.Add(-day), now.Add(365*day), "kubernetes.default", "node1", "10.96.0.1")
+	expiring := newTestCert(t, "front-proxy-client", &ca, false, now.Add(-day), now.Add(10*day), "kubernetes.default", "10.96.0.1")
+	expired := newTestCert(t, "kubelet", &ca, false, now.Add(-30*day), now.Add(-12*time.Hour), "kubernetes.default", "10.96.0.1")
+	etcd := newTestCert(t, "etcd-server", &otherCA, false, now.Add(-day), now.Add(365*day), "kubernetes.default")
+
+	fakeHost(t, map[string]string{
+		"etc/kubernetes/pki/ca.crt":                 ca.certPEM,
+		"etc/kubernetes/pki/ca.key":                 ca.keyPEM,
+		"etc/kubernetes/pki/apiserver.crt":          apiserver.certPEM,
+		"etc/kubernetes/pki/apiserver.key":          expiring.keyPEM,
+		"etc/kubernetes/pki/front-proxy-client.crt": expiring.certPEM,
+		"etc/kubernetes/pki/front-proxy-client.key": expiring.keyPEM,
+		"etc/kubernetes/pki/sa.pub":                 "-----BEGIN PUBLIC KEY-----\n-----END PUBLIC KEY-----\n",
+		"etc/kubernetes/pki/sa.crt":                 "not a certificate",
+		"etc/kubernetes/pki/zz-etcd.crt":            etcd.certPEM,
+		"var/lib/kubelet/pki/kubelet.pem":           expired.certPEM + expired.keyPEM,
+	})
+
+	c, err := checker.ParseSpec("cert:/etc/kubernetes/pki/*.crt|/var/lib/kubelet/pki/kubelet.pem|/etc/app/tls.crt;valid>=30d;ca=/etc/kubernetes/pki/ca.crt;san=kubernetes.default|10.96.0.1")
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	report, err := checker.Evaluate(context.Background(), c)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	expected := []struct {
+		name     string
+		status   checker.Status
+		observed string
+		message  string
+	}{
+		{"/etc/kubernetes/pki/apiserver.crt", checker.StatusFail, "expires " + apiserver.cert.NotAfter.UTC().Format(time.RFC3339) + " (364d left)", "key /etc/kubernetes/pki/apiserver.key of /etc/kubernetes/pki/apiserver.crt: tls: private key does not match public key"},
+		{"/etc/kubernetes/pki/ca.crt", checker.StatusPass, "expires " + ca.cert.NotAfter.UTC().Format(time.RFC3339) + " (3649d left), key /etc/kubernetes/pki/ca.key", ""},
+		{"/etc/kubernetes/pki/front-proxy-client.crt", checker.StatusFail, "(9d left), key /etc/kubernetes/pki/front-proxy-client.key", "expires in 9d at " + expiring.cert.NotAfter.UTC().Format(time.RFC3339) + ", less than 30d"},
+		{"/etc/kubernetes/pki/zz-etcd.crt", checker.StatusFail, "(364d left)", "misses SANs 10.96.0.1, /etc/kubernetes/pki/zz-etcd.crt is not signed by /etc/kubernetes/pki/ca.crt: x509"},
+		{"/var/lib/kubelet/pki/kubelet.pem", checker.StatusFail, "expired at " + expired.cert.NotAfter.UTC().Format(time.RFC3339) + ", key /var/lib/kubelet/pki/kubelet.pem", "expired at"},
+		{"/etc/app/tls.crt", checker.StatusFail, "not found", "no certificate file matches /etc/app/tls.crt"},
+	}
+	if report.Status != checker.StatusFail || len(report.Details) != len(expected) {
+		t.Fatalf("expected failed, but got %+v", report)
+	}
+	for i, e := range expected {
+		d := report.Details[i]
+		if d.Name != e.name || d.Status != e.status || !strings.HasSuffix(d.Observed, e.observed) || !strings.Contains(d.Message, e.message) {
+			t.Errorf("expected %s %s observed %s with message %q, but got %+v", e.name, e.status, e.observed, e.message, d)
+		}
+	}
+	if d := report.Details[4]; strings.Contains(d.Message, "not signed") {
+		t.Errorf("expected the chain of expired certificate verified, but got %+v", d)
+	}
+}
+
+func TestCertCheckBundle(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+	root := newTestCert(t, "root", nil, true, now.Add(-day), now.Add(3650*day))
+	intermediate := newTestCert(t, "intermediate", &root, true, now.Add(-day), now.Add(1000*day))
+	leaf := newTestCert(t, "app", &intermediate, false, now.Add(-day), now.Add(100*day), "app.example.test")
+
+	fakeHost(t, map[string]string{
+		"etc/app/root.pem":   root.certPEM,
+		"etc/app/server.pem": leaf.certPEM + intermediate.certPEM,
+	})
+	report, err := checker.Evaluate(context.Background(), checker.CertCheck{Paths: []string{"/etc/app/server.pem"}, MinValidity: "2160h", SANs: []string{"app.example.test"}, CA: "/etc/app/root.pem"})
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if report.Status != checker.StatusPass || len(report.Details) != 2 || report.Details[0].Name != "/etc/app/server.pem[0]" || report.Details[0].Expected != "valid>=90d,san=app.example.test,ca=/etc/app/root.pem" {
+		t.Fatalf("expected passed with a row per certificate, but got %+v", report)
+	}
+}
+
+func TestCertCheckNotYetValid(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+	future := newTestCert(t, "kube-apiserver", nil, true, now.Add(2*day), now.Add(365*day))
+
+	fakeHost(t, map[string]string{
+		"etc/kubernetes/pki/apiserver.crt": future.certPEM,
+	})
+	c := checker.CertCheck{Paths: []string{"/etc/kubernetes/pki/*.crt"}}
+	report, err := checker.Evaluate(context.Background(), c)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	observed := "not valid before " + future.cert.NotBefore.UTC().Format(time.RFC3339) + ", expires " + future.cert.NotAfter.UTC().Format(time.RFC3339)
+	if report.Status != checker.StatusFail || len(report.Details) != 1 || report.Details[0].Observed != observed {
+		t.Fatalf("expected failed with the expiry observed, but got %+v", report)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := checker.Evaluate(ctx, c); err != context.Canceled {
+		t.Errorf("expected canceled, but got %v", err)
+	}
+}
+
+func TestCertCheckUnreadable(t *testing.T) {
+	now := time.Now()
+	ca := newTestCert(t, "kubernetes", nil, true, now.Add(-time.Hour), now.Add(365*24*time.Hour))
+
+	fakeHost(t, map[string]string{
+		"etc/kubernetes/pki/ca.crt":      ca.certPEM,
+		"etc/kubernetes/pki/ca.key":      ca.keyPEM,
+		"etc/kubernetes/pki/broken.crt":  "-----BEGIN CERTIFICATE-----\nYnJva2Vu\n-----END CERTIFICATE-----\n",
+		"etc/kubernetes/pki/etcd/ca.crt": ca.certPEM,
+	})
+	report, err := checker.Evaluate(context.Background(), checker.CertCheck{Paths: []string{"/etc/kubernetes/pki/*", "/etc/kubernetes/pki/etcd"}})
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	expected := []struct {
+		name    string
+		status  checker.Status
+		message string
+	}{
+		{"/etc/kubernetes/pki/broken.crt", checker.StatusFail, "failed to read /etc/kubernetes/pki/broken.crt: invalid certificate"},
+		{"/etc/kubernetes/pki/ca.crt", checker.StatusPass, ""},
+		{"/etc/kubernetes/pki/etcd", checker.StatusFail, "failed to read /etc/kubernetes/pki/etcd"},
+	}
+	if report.Status != checker.StatusFail || len(report.Details) != len(expected) {
+		t.Fatalf("expected failed with the directory of glob skipped, but got %+v", report)
+	}
+	for i, e := range expected {
+		if d := report.Details[i]; d.Name != e.name || d.Status != e.status || !strings.Contains(d.Message, e.message) {
+			t.Errorf("expected %s %s with message %q, but got %+v", e.name, e.status, e.message, d)
+		}
+	}
+}
+
+func TestCertCheckInvalidSpec(t *testing.T) {
+	for _, spec := range []string{"cert:", "cert:etc/kubernetes/pki/ca.crt", "cert:/etc/kubernetes/pki/ca.crt;valid>=30", "cert:/etc/kubernetes/pki/[.crt", "cert:/etc/kubernetes/pki/ca.crt;expiry=30d"} {
+		if _, err := checker.ParseSpec(spec); err == nil {
+			t.Errorf("expected error for %s", spec)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"testing"
+
+	"preflight/checker"
+	"preflight/pkg/container"
+)
+
+func TestCgroupCheckV2(t *testing.T) {
+	fakeHost(t, map[string]string{
+		"proc/self/mountinfo": `25 1 259:2 / / rw,relatime shared:1 - ext4 /dev/nvme0n1p2 rw
+33 25 0:28 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:9 - cgroup2 cgroup2 rw,nsdelegate,memory_recursiveprot
+`,
+		"sys/fs/cgroup/cgroup.controllers":         "cpuset cpu io memory hugetlb pids rdma misc\n",
+		"sys/fs/cgroup/cgroup.subtree_control":     "cpuset cpu io memory pids\n",
+		"run/systemd/system/.keep":                 "",
+		"usr/lib/systemd/libsystemd-shared-249.so": "",
+		"var/lib/kubelet/config.yaml":              "kind: KubeletConfiguration\ncgroupDriver: systemd\n",
+		"etc/containerd/config.toml": `version = 2
+[plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc.options]
+  # SystemdCgroup = true
+  SystemdCgroup = false
+`,
+	})
+
+	c, err := checker.ParseSpec("cgroup:mode=v2;systemd>=250")
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	report, err := checker.Evaluate(context.Background(), c)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+
+	expected := []struct {
+		name     string
+		status   checker.Status
+		observed string
+	}{
+		{"cgroup mode", checker.StatusPass, "v2"},
+		{"controller cpu", checker.StatusPass, "delegated"},
+		{"controller memory", checker.StatusPass, "delegated"},
+		{"controller pids", checker.StatusPass, "delegated"},
+		{"controller hugetlb", checker.StatusFail, "available"},
+		{"controller io", checker.StatusPass, "delegated"},
+		{"systemd", checker.StatusFail, "249"},
+		{"kubelet cgroup driver", checker.StatusPass, "systemd"},
+		{"containerd cgroup driver", checker.StatusWarn, "cgroupfs"},
+		{"cgroup driver consistency", checker.StatusFail, "kubelet=systemd,containerd=cgroupfs"},
+	}
+	if report.Status != checker.StatusFail || report.Observed != "v2, systemd 249" || len(report.Details) != len(expected) {
+		t.Fatalf("expected failed with a detail for each requirement, but got %+v", report)
+	}
+	for i, e := range expected {
+		d := report.Details[i]
+		if d.Name != e.name || d.Status != e.status || d.Observed != e.observed {
+			t.Errorf("expected %s %s observed %s, but got %+v", e.name, e.status, e.observed, d)
+		}
+	}
+}
+
+func TestCgroupCheckHybrid(t *testing.T) {
+	fakeHost(t, map[string]string{
+		"proc/self/mountinfo": `25 1 259:2 / / rw,relatime shared:1 - ext4 /dev/nvme0n1p2 rw
+33 25 0:28 / /sys/fs/cgroup ro,nosuid,nodev,noexec shared:9 - tmpfs tmpfs ro,mode=755
+34 33 0:29 / /sys/fs/cgroup/unified rw,nosuid,nodev,noexec,relatime shared:10 - cgroup2 cgroup2 rw,nsdelegate
+35 33 0:30 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid,nodev,noexec,relatime shared:11 - cgroup cgroup rw,cpu,cpuacct
+36 33 0:31 / /sys/fs/cgroup/memory rw,nosuid,nodev,noexec,relatime shared:12 - cgroup cgroup rw,memory
+37 33 0:32 / /sys/fs/cgroup/pids rw,nosuid,nodev,noexec,relatime shared:13 - cgroup cgroup rw,pids
+38 33 0:33 / /sys/fs/cgroup/blkio rw,nosuid,nodev,noexec,relatime shared:14 - cgroup cgroup rw,blkio
+39 33 0:34 / /sys/fs/cgroup/hugetlb rw,nosuid,nodev,noexec,relatime shared:15 - cgroup cgroup rw,hugetlb
+`,
+		"proc/cgroups": `#subsys_name	hierarchy	num_cgroups	enabled
+cpu	3	90	1
+cpuacct	3	90	1
+memory	4	120	1
+pids	5	90	1
+blkio	6	90	1
+hugetlb	7	1	1
+`,
+		"etc/docker/daemon.json": `{"exec-opts": ["native.cgroupdriver=cgroupfs"]}`,
+	})
+
+	report, err := checker.CgroupCheck{Mode: "v1|hybrid", Driver: container.CgroupDriverCgroupfs}.Evaluate(context.Background())
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if report.Status != checker.StatusPass || report.Observed != checker.CgroupHybrid {
+		t.Fatalf("expected hybrid passed, but got %+v", report)
+	}
+	last := report.Details[len(report.Details)-1]
+	if last.Name != "docker cgroup driver" || last.Observed != container.CgroupDriverCgroupfs {
+		t.Errorf("expected docker cgroupfs driver, but got %+v", last)
+	}
+
+	report, err = checker.CgroupCheck{Driver: container.CgroupDriverSystemd}.Evaluate(context.Background())
+	if err != nil || report.Status != checker.StatusFail {
+		t.Errorf("expected driver mismatch failed, but got %+v, err: %v", report, err)
+	}
+}
+
+func TestParseCgroupSpecErrors(t *testing.T) {
+	for _, spec := range []string{
+		"cgroup:mode=v3",
+		"cgroup:controllers=cpu|",
+		"cgroup:driver=docker",
+		"cgroup:systemd>=abc",
+		"cgroup:systemd=244",
+		"cgroup:delegate=true",
+	} {
+		if _, err := checker.ParseSpec(spec); err == nil {
+			t.Errorf("expected error of spec %s", spec)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"preflight/checker"
+	"preflight/checker/cluster/api/types"
+	"preflight/checker/cluster/pkg/run"
+	"preflight/pkg/sshcmd/sshutil"
+	"preflight/result"
+	"preflight/runner"
+)
+
+// fakeCluster replace the inspection of hosts through ssh by the instances.
+func fakeCluster(t *testing.T, instances []types.InstanceInfo, inspectErr error) {
+	t.Helper()
+	inspect, remoteTime := run.Inspect, run.RemoteTime
+	t.Cleanup(func() { run.Inspect, run.RemoteTime = inspect, remoteTime })
+	run.Inspect = func(*types.ClusterInfoBrief, sshutil.SSH) (*types.ClusterInfoDetailed, map[string]*types.InstanceInfoExtended, error) {
+		if inspectErr != nil {
+			return nil, nil, inspectErr
+		}
+		extends := make(map[string]*types.InstanceInfoExtended)
+		for _, i := range instances {
+			extends[i.PrivateIP] = &types.InstanceInfoExtended{InstanceInfo: i, TimeSyncStatus: types.TimeSyncStatus{Chronyd: "active"}}
+		}
+		return &types.ClusterInfoDetailed{InstanceInfos: instances}, extends, nil
+	}
+	run.RemoteTime = func(sshutil.SSH, string) (int64, error) {
+		return time.Now().Unix(), nil
+	}
+}
+
+func clusterInstance(ip, hostname, osVersion string, cpu int32) types.InstanceInfo {
+	return types.InstanceInfo{PrivateIP: ip, HostName: hostname, OS: "CentOS", OSVersion: osVersion, Kernel: "3.10.0-1127", Arch: "amd64",
+		CPU: cpu, Memory: 16, SystemDisk: types.DiskSlice{{Capacity: 200}}}
+}
+
+func TestClusterCheckHosts(t *testing.T) {
+	fakeCluster(t, []types.InstanceInfo{
+		clusterInstance("10.0.0.1", "node1", "7.8.2003", 8),
+		clusterInstance("10.0.0.2", "node1", "7.8.2003", 8),
+		clusterInstance("10.0.0.3", "node3", "7.9.2009", 2),
+	}, nil)
+	c := checker.ClusterCheck{AuthInfo: types.ClusterInfoBrief{Hosts: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}}}
+
+	report, err := checker.Evaluate(context.Background(), c)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	expected := []struct {
+		status  checker.Status
+		message []string
+	}{
+		{checker.StatusPass, nil},
+		{checker.StatusFail, []string{"hostname node1 is duplicate with host 10.0.0.1"}},
+		{checker.StatusFail, []string{"validate OS failed", "cpu cores should >=4"}},
+	}
+	if report.Status != checker.StatusFail || report.Observed != "1 of 3 host(s) met the requirements" || len(report.Details) != len(expected) {
+		t.Fatalf("expected failed with a detail per host, but got %+v", report)
+	}
+	for i, e := range expected {
+		d := report.Details[i]
+		if d.Host != c.AuthInfo.Hosts[i] || d.Name != d.Host || d.Status != e.status {
+			t.Errorf("expected %s %s, but got %+v", c.AuthInfo.Hosts[i], e.status, d)
+		}
+		for _, m := range e.message {
+			if !strings.Contains(d.Message, m) {
+				t.Errorf("expected message of %s contains %q, but got %q", d.Host, m, d.Message)
+			}
+		}
+	}
+
+	r, err := runner.NewCheckRunner([]checker.Interface{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := result.NewDefaultFormatter(r.Execute()).Format()
+	var metrics bytes.Buffer
+	if err := result.WritePrometheus(&metrics, resp, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`preflight_check_passed{type="clustercheck",name="clustercheck",level="fatal",host="10.0.0.1"} 1`,
+		`preflight_check_passed{type="clustercheck",name="clustercheck",level="fatal",host="10.0.0.2"} 0`,
+		`preflight_check_passed{type="clustercheck",name="clustercheck",level="fatal",host="10.0.0.3"} 0`,
+	} {
+		if !strings.Contains(metrics.String(), s) {
+			t.Errorf("expected metrics contains %s, but got:\n%s", s, metrics.String())
+		}
+	}
+	w, _ := result.GetWriter("html")
+	var html bytes.Buffer
+	if err := w(&html, resp); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), "<h3>10.0.0.3</h3>") || !strings.Contains(html.String(), "cpu cores should &gt;=4") {
+		t.Errorf("expected the section of failed host 10.0.0.3 in html")
+	}
+}
+
+func TestClusterCheckInspectionError(t *testing.T) {
+	fakeCluster(t, nil, errors.New("ssh: handshake failed"))
+	c := checker.ClusterCheck{AuthInfo: types.ClusterInfoBrief{Hosts: []string{"10.0.0.1"}}}
+	if _, err := checker.Evaluate(context.Background(), c); err == nil || !strings.Contains(err.Error(), "ssh: handshake failed") {
+		t.Errorf("expected the error of inspection, but got %v", err)
+	}
+}
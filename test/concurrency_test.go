@@ -0,0 +1,140 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"preflight/checker"
+	"preflight/runner"
+)
+
+var running, maxRunning int32
+
+// trackedCheck records how many checkers are running at the same time.
+type trackedCheck struct {
+	fakeCheck
+	exclusive bool
+}
+
+func (t trackedCheck) Exclusive() bool {
+	return t.exclusive
+}
+
+func (t trackedCheck) Validate() (bool, error) {
+	n := atomic.AddInt32(&running, 1)
+	defer atomic.AddInt32(&running, -1)
+	if t.exclusive && n != 1 {
+		return false, errors.New("exclusive checker runs with others")
+	}
+	for {
+		m := atomic.LoadInt32(&maxRunning)
+		if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+			break
+		}
+	}
+	return t.fakeCheck.Validate()
+}
+
+func TestConcurrency(t *testing.T) {
+	atomic.StoreInt32(&maxRunning, 0)
+	var list []checker.Interface
+	for _, name := range []string{"a", "b", "c", "d"} {
+		list = append(list, trackedCheck{fakeCheck: fakeCheck{Name: name, Delay: 100 * time.Millisecond}})
+	}
+	r, err := runner.NewCheckRunner(list, runner.WithConcurrency(4))
+	if err != nil {
+		t.Fatalf("failed to init runner err: %s", err)
+	}
+
+	start := time.Now()
+	res := r.Execute()
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("expected checkers run concurrently, but took %s", elapsed)
+	}
+	if maxRunning < 2 {
+		t.Errorf("expected checkers run concurrently, but max running is %d", maxRunning)
+	}
+	for i, name := range []string{"fake:a", "fake:b", "fake:c", "fake:d"} {
+		if res.Passed[i].Checker.PrettyName() != name {
+			t.Errorf("expected result %d is %s, but got %s", i, name, res.Passed[i].Checker.PrettyName())
+		}
+	}
+}
+
+func TestConcurrencyExclusive(t *testing.T) {
+	list := []checker.Interface{
+		trackedCheck{fakeCheck: fakeCheck{Name: "a", Delay: 50 * time.Millisecond}},
+		trackedCheck{fakeCheck: fakeCheck{Name: "port", Delay: 50 * time.Millisecond}, exclusive: true},
+		trackedCheck{fakeCheck: fakeCheck{Name: "b", Delay: 50 * time.Millisecond}},
+		trackedCheck{fakeCheck: fakeCheck{Name: "c", Delay: 50 * time.Millisecond}},
+	}
+	r, err := runner.NewCheckRunner(list, runner.WithConcurrency(4))
+	if err != nil {
+		t.Fatalf("failed to init runner err: %s", err)
+	}
+
+	res := r.Execute()
+	if len(res.Passed) != 4 {
+		t.Errorf("expected exclusive checker runs alone, but got %+v", res)
+	}
+}
+
+func TestConcurrencyNotTolerable(t *testing.T) {
+	list := []checker.Interface{
+		fakeCheck{Name: "failed", Fail: true},
+		fakeCheck{Name: "a", Delay: 50 * time.Millisecond},
+		fakeCheck{Name: "b", Delay: 50 * time.Millisecond},
+		fakeCheck{Name: "c", Delay: 50 * time.Millisecond},
+	}
+	r, err := runner.NewCheckRunner(list, runner.WithConcurrency(2), runner.WithToleration(true))
+	if err != nil {
+		t.Fatalf("failed to init runner err: %s", err)
+	}
+
+	res := r.Execute()
+	if len(res.Failed) != 1 || len(res.Passed) > 1 {
+		t.Errorf("expected stop running after failed, but got %+v", res)
+	}
+	if len(res.Passed)+len(res.Skipped) != 3 {
+		t.Fatalf("expected the checkers not run reported as skipped, but got %+v", res)
+	}
+	for _, r := range res.Skipped {
+		if r.Status != checker.StatusSkip || r.Reason != "not run after a checker at or above info level failed" {
+			t.Errorf("unexpected skipped result of %s: %s %s", checker.ID(r.Checker), r.Status, r.Reason)
+		}
+	}
+}
+
+func TestConcurrencyExclusiveTimedOut(t *testing.T) {
+	list := []checker.Interface{
+		trackedCheck{fakeCheck: fakeCheck{Name: "slow", Delay: 200 * time.Millisecond}},
+		trackedCheck{fakeCheck: fakeCheck{Name: "a", Delay: 10 * time.Millisecond}},
+		// port is dispatched after the slow checker started.
+		checker.Customized{Interface: trackedCheck{fakeCheck: fakeCheck{Name: "port"}, exclusive: true}, DependsOn: []string{"fake:a"}},
+	}
+	r, err := runner.NewCheckRunner(list, runner.WithConcurrency(3), runner.WithCheckTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to init runner err: %s", err)
+	}
+
+	res := r.Execute()
+	if len(res.TimedOut) != 1 || len(res.Passed) != 2 {
+		t.Errorf("expected exclusive checker waits for the timed out checker returned, but got %+v", res)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"preflight/checker"
+)
+
+// listenUnix listen on the unix socket under the root of fake host.
+func listenUnix(t *testing.T, root, socket string) net.Listener {
+	path := filepath.Join(root, socket)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+// fakeDocker serve the docker engine API of /version and /info.
+func fakeDocker(t *testing.T, root string, version string, info map[string]string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"Version": version, "ApiVersion": "1.43"})
+	})
+	mux.HandleFunc("/info", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(info)
+	})
+	l := listenUnix(t, root, "/var/run/docker.sock")
+	go func() { _ = http.Serve(l, mux) }()
+}
+
+// fakeClients install the clients of runtimes in PATH, which print the outputs of the subcommands, the others fail.
+func fakeClients(t *testing.T, clients map[string]map[string]string) {
+	dir := t.TempDir()
+	for name, outputs := range clients {
+		// the subcommand follows the socket option.
+		script := "#!/bin/sh\ncase \"$3\" in\n"
+		for command, output := range outputs {
+			script += fmt.Sprintf("%s) printf '%%s\\n' '%s' ;;\n", command, output)
+		}
+		script += "*) echo \"unknown command $3\" >&2; exit 1 ;;\nesac\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+":/usr/bin:/bin")
+}
+
+// fakeContainerd serve the socket of containerd, and install ctr and crictl for it.
+func fakeContainerd(t *testing.T, root, version string, cri bool) {
+	listenUnix(t, root, "/run/containerd/containerd.sock")
+	crictl := map[string]string{}
+	if cri {
+		crictl["info"] = `{"config":{"containerd":{"snapshotter":"overlayfs","defaultRuntimeName":"runc","runtimes":{"runc":{"options":{"SystemdCgroup":true}}}},"containerdRootDir":"/var/lib/containerd"}}`
+	}
+	fakeClients(t, map[string]map[string]string{
+		"ctr": {"version": "Client:\n  Version:  " + version + "\n  Revision: 0cae528dd6cb557f7201036e9f43420650207b58\n\n" +
+			"Server:\n  Version:  " + version + "\n  Revision: 0cae528dd6cb557f7201036e9f43420650207b58"},
+		"crictl": crictl,
+	})
+}
+
+func TestContainerRuntimeCheck(t *testing.T) {
+	root := fakeHost(t, map[string]string{})
+	fakeDocker(t, root, "24.0.5", map[string]string{"Driver": "overlay2", "CgroupDriver": "cgroupfs", "DockerRootDir": "/var/lib/docker"})
+	fakeContainerd(t, root, "v1.7.2", true)
+
+	c, err := checker.ParseSpec("containerruntime:containerd>=1.6.0 <2;cgroup-driver=systemd")
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	report, err := checker.Evaluate(context.Background(), c)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+
+	expected := []struct {
+		name     string
+		status   checker.Status
+		observed string
+	}{
+		{"docker", checker.StatusPass, "24.0.5"},
+		{"docker storage driver", checker.StatusPass, "overlay2"},
+		{"docker root dir", checker.StatusPass, "/var/lib/docker"},
+		{"docker cgroup driver", checker.StatusPass, "cgroupfs"},
+		{"containerd", checker.StatusPass, "v1.7.2"},
+		{"containerd storage driver", checker.StatusPass, "overlayfs"},
+		{"containerd root dir", checker.StatusPass, "/var/lib/containerd"},
+		{"containerd cgroup driver", checker.StatusPass, "systemd"},
+		{"active runtimes", checker.StatusWarn, "docker,containerd"},
+	}
+	if report.Status != checker.StatusWarn || report.Observed != "docker 24.0.5, containerd v1.7.2" || len(report.Details) != len(expected) {
+		t.Fatalf("expected warned for more than one runtime, but got %+v", report)
+	}
+	for i, e := range expected {
+		d := report.Details[i]
+		if d.Name != e.name || d.Status != e.status || d.Observed != e.observed {
+			t.Errorf("expected %s %s observed %s, but got %+v", e.name, e.status, e.observed, d)
+		}
+	}
+
+	report, err = checker.ContainerRuntimeCheck{Runtime: "containerd", Version: "<1.7 || >=1.7.3"}.Evaluate(context.Background())
+	if err != nil || report.Status != checker.StatusFail || report.Details[4].Status != checker.StatusFail {
+		t.Errorf("expected version mismatch failed, but got %+v, err: %v", report, err)
+	}
+}
+
+func TestContainerRuntimeCheckDockerContainerd(t *testing.T) {
+	root := fakeHost(t, map[string]string{})
+	fakeDocker(t, root, "24.0.5", map[string]string{"Driver": "overlay2", "CgroupDriver": "systemd", "DockerRootDir": "/data/docker"})
+	fakeContainerd(t, root, "1.6.21", false)
+
+	report, err := checker.ContainerRuntimeCheck{Runtime: "docker", Version: ">=20.10", CgroupDriver: "systemd"}.Evaluate(context.Background())
+	if err != nil || report.Status != checker.StatusPass {
+		t.Fatalf("expected the containerd of docker not regarded as another runtime, but got %+v, err: %v", report, err)
+	}
+
+	report, err = checker.ContainerRuntimeCheck{Runtime: "containerd|crio"}.Evaluate(context.Background())
+	if err != nil || report.Status != checker.StatusFail {
+		t.Errorf("expected containerd without cri plugin failed, but got %+v, err: %v", report, err)
+	}
+}
+
+func TestContainerRuntimeCheckCrio(t *testing.T) {
+	root := fakeHost(t, map[string]string{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/info", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"storage_driver": "overlay", "storage_root": "/var/lib/containers/storage", "cgroup_driver": "systemd"})
+	})
+	l := listenUnix(t, root, "/var/run/crio/crio.sock")
+	go func() { _ = http.Serve(l, mux) }()
+	fakeClients(t, map[string]map[string]string{
+		"crictl": {"version": "Version:  0.1.0\nRuntimeName:  cri-o\nRuntimeVersion:  1.24.1\nRuntimeApiVersion:  v1"},
+	})
+
+	report, err := checker.ContainerRuntimeCheck{Runtime: "crio", Version: ">=1.24", CgroupDriver: "systemd"}.Evaluate(context.Background())
+	if err != nil || report.Status != checker.StatusPass || report.Observed != "crio 1.24.1" {
+		t.Errorf("expected crio passed, but got %+v, err: %v", report, err)
+	}
+}
+
+func TestContainerRuntimeCheckNone(t *testing.T) {
+	root := fakeHost(t, map[string]string{})
+	// the socket is left by the runtime stopped.
+	listenUnix(t, root, "/var/run/crio/crio.sock").Close()
+
+	report, err := checker.ContainerRuntimeCheck{}.Evaluate(context.Background())
+	if err != nil || report.Status != checker.StatusFail || len(report.Details) != 1 || report.Details[0].Name != "container runtime" {
+		t.Errorf("expected no runtime failed, but got %+v, err: %v", report, err)
+	}
+}
+
+func TestParseContainerRuntimeSpecErrors(t *testing.T) {
+	for _, spec := range []string{
+		"containerruntime:podman",
+		"containerruntime:docker|containerd>=1.6",
+		"containerruntime:containerd>=abc",
+		"containerruntime:>=1.6",
+		"containerruntime:containerd;cgroup-driver=docker",
+		"containerruntime:containerd;socket=/run/containerd.sock",
+	} {
+		if _, err := checker.ParseSpec(spec); err == nil {
+			t.Errorf("expected error of spec %s", spec)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"preflight/checker"
+	"preflight/plan"
+	"preflight/runner"
+)
+
+func TestDependencySkipped(t *testing.T) {
+	list := []checker.Interface{
+		checker.Customized{Interface: fakeCheck{Name: "kernel"}, DependsOn: []string{"fake:os"}},
+		checker.Customized{Interface: fakeCheck{Name: "module"}, DependsOn: []string{"fake:kernel"}},
+		fakeCheck{Name: "os", Fail: true},
+		fakeCheck{Name: "cpu"},
+	}
+	r, err := runner.NewCheckRunner(list, runner.WithConcurrency(2))
+	if err != nil {
+		t.Fatalf("failed to init runner err: %s", err)
+	}
+
+	res := r.Execute()
+	if len(res.Failed) != 1 || len(res.Passed) != 1 || len(res.Skipped) != 2 {
+		t.Fatalf("expected 1 failed, 1 passed and 2 skipped, but got %+v", res)
+	}
+	for _, r := range res.Skipped {
+		if r.Status != checker.StatusSkip {
+			t.Errorf("expected %s skipped, but got status %q", checker.ID(r.Checker), r.Status)
+		}
+	}
+	if res.Skipped[0].Reason != "dependency fake:os failed" {
+		t.Errorf("unexpected reason: %s", res.Skipped[0].Reason)
+	}
+	if res.Skipped[1].Reason != "dependency fake:kernel was skipped" {
+		t.Errorf("unexpected reason: %s", res.Skipped[1].Reason)
+	}
+}
+
+func TestDependencyErrors(t *testing.T) {
+	tests := map[string][]checker.Interface{
+		"dependency cycle found: a -> b -> a": {
+			fakeCheck{Name: "c"},
+			checker.Customized{Interface: fakeCheck{Name: "a"}, CheckerID: "a", DependsOn: []string{"b"}},
+			checker.Customized{Interface: fakeCheck{Name: "b"}, CheckerID: "b", DependsOn: []string{"a"}},
+		},
+		"checker fake:a depends on unknown checker fake:x": {
+			checker.Customized{Interface: fakeCheck{Name: "a"}, DependsOn: []string{"fake:x"}},
+		},
+	}
+
+	for expected, list := range tests {
+		_, err := runner.NewCheckRunner(list)
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected error %q, but got %v", expected, err)
+		}
+	}
+}
+
+func TestPlanUnknownDependency(t *testing.T) {
+	data := `version: v1
+checkers:
+  - type: cpu
+    id: cpu
+    args:
+      numCPU: 1
+  - type: memory
+    dependsOn: [cpu, os]
+    args:
+      mem: 1
+`
+	_, err := plan.Parse("plan.yaml", []byte(data))
+	if err == nil || !strings.Contains(err.Error(), `plan.yaml:8:22: depends on unknown checker "os"`) {
+		t.Errorf("expected unknown dependency error, but got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"preflight/checker"
+	"preflight/pkg/system"
+	"preflight/plan"
+)
+
+func TestParseDiskSpec(t *testing.T) {
+	c, err := checker.ParseSpec("disk:/var/lib/containerd>=50Gi;inodes>=10%;used<=80%;total>=100G;inodes-used<=90%")
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	expected := checker.DiskCheck{
+		Path:                 "/var/lib/containerd",
+		MinTotal:             "100G",
+		MinFree:              "50Gi",
+		MaxUsedPercent:       "80%",
+		MinFreeInodes:        "10%",
+		MaxInodesUsedPercent: "90%",
+	}
+	if c != expected {
+		t.Errorf("expected %+v, but got %+v", expected, c)
+	}
+
+	for _, spec := range []string{
+		"disk:/var/lib/containerd",
+		"disk:>=50Gi",
+		"disk:/var>=50Xi",
+		"disk:/var>=50Gi;inodes<=10%",
+		"disk:/var>=50Gi;size>=1Gi",
+		"disk:/var>=50Gi;used<=120%",
+	} {
+		if _, err := checker.ParseSpec(spec); err == nil {
+			t.Errorf("expected error of spec %s", spec)
+		}
+	}
+}
+
+func TestDiskCheck(t *testing.T) {
+	// the path not exists yet is checked by its nearest existing parent.
+	path := filepath.Join(t.TempDir(), "not", "exist")
+
+	report, err := checker.DiskCheck{Path: path, MinFree: "1", MaxUsedPercent: "100%"}.Evaluate(context.Background())
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if report.Status != checker.StatusPass || len(report.Details) != 2 {
+		t.Errorf("expected passed with 2 details, but got %+v", report)
+	}
+	if !strings.Contains(report.Observed, "mounted on /") {
+		t.Errorf("expected device and mount point observed, but got %s", report.Observed)
+	}
+
+	report, err = checker.DiskCheck{Path: path, MinTotal: "1000Pi"}.Evaluate(context.Background())
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if report.Status != checker.StatusFail || !strings.Contains(report.Message, "less than the required 1000Pi") {
+		t.Errorf("expected failed by total size, but got %+v", report)
+	}
+}
+
+func TestDiskPlanArgs(t *testing.T) {
+	data := `version: v1
+checkers:
+  - type: disk
+    args:
+      path: /var/lib/containerd
+      minFree: 50 GB
+`
+	_, err := plan.Parse("plan.yaml", []byte(data))
+	if err == nil || !strings.Contains(err.Error(), `plan.yaml:5:7: invalid args of checker disk: invalid size "50 GB"`) {
+		t.Errorf("expected invalid args reported with position, but got %v", err)
+	}
+}
+
+func TestFindMount(t *testing.T) {
+	data := `22 1 253:1 / / rw,relatime shared:1 - ext4 /dev/vda1 rw
+23 22 0:21 / /proc rw,nosuid - proc proc rw
+24 22 253:2 / /var/lib rw,relatime shared:2 - xfs /dev/vdb rw,attr2
+25 24 253:3 / /var/lib/my\040data rw - xfs /dev/vdc rw
+`
+	mounts, err := system.ParseMountInfo(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse mountinfo: %v", err)
+	}
+	if len(mounts) != 4 || mounts[2].FsType != "xfs" || mounts[2].Source != "/dev/vdb" || mounts[2].Optional[0] != "shared:2" {
+		t.Fatalf("unexpected mounts %+v", mounts)
+	}
+
+	tests := map[string]string{
+		"/var/lib/containerd": "/dev/vdb",
+		"/var/lib":            "/dev/vdb",
+		"/var/library":        "/dev/vda1",
+		"/var/lib/my data/a":  "/dev/vdc",
+		"/":                   "/dev/vda1",
+	}
+	for path, device := range tests {
+		m, ok := system.FindMount(mounts, path)
+		if !ok || m.Source != device {
+			t.Errorf("expected %s backed by %s, but got %+v", path, device, m)
+		}
+	}
+}
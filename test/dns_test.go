@@ -0,0 +1,205 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"preflight/checker"
+	"preflight/pkg/dns"
+)
+
+// fakeDNSServer serve the A and AAAA records on a local UDP port, the names not in records are NXDOMAIN,
+// and the queries of names in silent are never answered.
+func fakeDNSServer(t *testing.T, records map[string][]string, silent ...string) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query, err := dns.ParseMessage(buf[:n])
+			if err != nil || len(query.Questions) != 1 {
+				continue
+			}
+			q := query.Questions[0]
+			ignored := false
+			for _, s := range silent {
+				ignored = ignored || q.Name == s
+			}
+			if ignored {
+				continue
+			}
+			response := dns.Message{ID: query.ID, Response: true, RecursionDesired: true, RecursionAvailable: true, Questions: query.Questions}
+			ips, ok := records[q.Name]
+			if !ok {
+				response.Rcode = dns.RcodeNameError
+			}
+			for _, ip := range ips {
+				parsed := net.ParseIP(ip)
+				if (parsed.To4() != nil) == (q.Type == dns.TypeA) {
+					response.Answers = append(response.Answers, dns.Resource{Name: q.Name, Type: q.Type, Class: dns.ClassINET, TTL: 30, IP: parsed})
+				}
+			}
+			if msg, err := response.Pack(); err == nil {
+				_, _ = conn.WriteTo(msg, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestDNSMessage(t *testing.T) {
+	m := dns.Message{ID: 7, Response: true, Questions: []dns.Question{{Name: "www.example.test.", Type: dns.TypeA, Class: dns.ClassINET}},
+		Answers: []dns.Resource{
+			{Name: "www.example.test.", Type: dns.TypeCNAME, Class: dns.ClassINET, TTL: 60, Target: "example.test."},
+			{Name: "example.test.", Type: dns.TypeA, Class: dns.ClassINET, TTL: 60, IP: net.ParseIP("10.0.0.5")},
+		}}
+	msg, err := m.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := dns.ParseMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.ID != 7 || !parsed.Response || len(parsed.Answers) != 2 || parsed.Answers[0].Target != "example.test." || !parsed.Answers[1].IP.Equal(net.ParseIP("10.0.0.5")) {
+		t.Errorf("unexpected message %+v", parsed)
+	}
+
+	// the name of answer is compressed as a pointer to the question.
+	question := 12 + len("www.example.test.") + 1 + 4
+	compressed := append(append([]byte{}, msg[:question]...), 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 10, 0, 0, 6)
+	compressed[7] = 1
+	if parsed, err = dns.ParseMessage(compressed); err != nil || len(parsed.Answers) != 1 || parsed.Answers[0].Name != "www.example.test." {
+		t.Errorf("unexpected compressed message %+v, %v", parsed, err)
+	}
+}
+
+func TestDNSCheck(t *testing.T) {
+	server := fakeDNSServer(t, map[string][]string{
+		"kubernetes.io.":         {"147.75.40.148"},
+		"registry.cluster.test.": {"10.0.0.5", "fd00::5"},
+		"node1.cluster.test.":    {"10.0.0.11"},
+		"registry.example.test.": {"10.0.0.9"},
+	}, "slow.test.")
+	fakeHost(t, map[string]string{
+		"etc/resolv.conf": "# Generated by NetworkManager\nsearch cluster.test\nnameserver 10.0.0.2\noptions ndots:2 timeout:1\n",
+		"etc/hostname":    "node1\n",
+		"etc/hosts":       "127.0.0.1 localhost\n10.0.0.10 node0 node0.cluster.test\n",
+	})
+
+	c, err := checker.ParseSpec("dns:kubernetes.io;registry=10.0.0.5|fd00::5;registry.example.test=10.0.0.8;node0;missing.test;slow.test.;hostname;nameserver=" + server + ";timeout=200ms")
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	report, err := checker.Evaluate(context.Background(), c)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	expected := []struct {
+		name     string
+		status   checker.Status
+		observed string
+	}{
+		{"nameservers", checker.StatusPass, "10.0.0.2"},
+		{"search domains", checker.StatusPass, "cluster.test"},
+		{"ndots", checker.StatusPass, "2"},
+		{"kubernetes.io", checker.StatusPass, "147.75.40.148 by " + server},
+		{"registry", checker.StatusPass, "10.0.0.5 fd00::5 by " + server},
+		{"registry.example.test", checker.StatusFail, "10.0.0.9 by " + server},
+		{"node0", checker.StatusPass, "10.0.0.10 by /etc/hosts"},
+		{"missing.test", checker.StatusFail, "NXDOMAIN by " + server},
+		{"slow.test.", checker.StatusFail, "timed out after 200ms by " + server},
+		{"hostname node1", checker.StatusPass, "10.0.0.11 by " + server},
+	}
+	if report.Status != checker.StatusFail || len(report.Details) != len(expected) {
+		t.Fatalf("expected failed, but got %+v", report)
+	}
+	for i, e := range expected {
+		d := report.Details[i]
+		if d.Name != e.name || d.Status != e.status || d.Observed != e.observed {
+			t.Errorf("expected %s %s observed %s, but got %+v", e.name, e.status, e.observed, d)
+		}
+	}
+	if msg := report.Details[5].Message; !strings.Contains(msg, "missing 10.0.0.8") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestDNSCheckResolvConf(t *testing.T) {
+	fakeHost(t, map[string]string{
+		"etc/resolv.conf": "nameserver 127.0.0.53\nnameserver 10.0.0.2\nnameserver 10.0.0.3\nnameserver 10.0.0.4\n" +
+			"search a.test b.test\nsearch a.test b.test c.test d.test e.test f.test g.test\noptions edns0 ndots:8\n",
+	})
+
+	report, err := checker.Evaluate(context.Background(), checker.DNSCheck{})
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if report.Status != checker.StatusWarn || len(report.Details) != 3 {
+		t.Fatalf("expected warned, but got %+v", report)
+	}
+	if d := report.Details[0]; d.Status != checker.StatusWarn || !strings.Contains(d.Message, "4 nameservers") ||
+		!strings.Contains(d.Message, "nameserver 127.0.0.53 of /etc/resolv.conf is loopback like the stub of systemd-resolved, point kubelet --resolv-conf to /run/systemd/resolve/resolv.conf") {
+		t.Errorf("expected nameservers warned, but got %+v", d)
+	}
+	if d := report.Details[1]; d.Status != checker.StatusWarn || !strings.Contains(d.Message, "7 search domains") {
+		t.Errorf("expected search domains warned, but got %+v", d)
+	}
+	if d := report.Details[2]; d.Status != checker.StatusWarn || d.Observed != "8" {
+		t.Errorf("expected ndots warned, but got %+v", d)
+	}
+}
+
+func TestDNSCheckKernelHostname(t *testing.T) {
+	server := fakeDNSServer(t, map[string][]string{"node2.": {"10.0.0.12"}})
+	fakeHost(t, map[string]string{
+		"etc/resolv.conf":          "nameserver 10.0.0.2\n",
+		"proc/sys/kernel/hostname": "node2\n",
+	})
+
+	report, err := checker.Evaluate(context.Background(), checker.DNSCheck{Hostname: true, Nameservers: []string{server}})
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if d := report.Details[len(report.Details)-1]; d.Name != "hostname node2" || d.Status != checker.StatusPass {
+		t.Errorf("expected the hostname of host resolved, but got %+v", report)
+	}
+
+	fakeHost(t, map[string]string{"etc/resolv.conf": "nameserver 10.0.0.2\n"})
+	if _, err := checker.Evaluate(context.Background(), checker.DNSCheck{Hostname: true, Nameservers: []string{server}}); err == nil {
+		t.Errorf("expected error without the hostname of host")
+	}
+}
+
+func TestDNSCheckInvalidSpec(t *testing.T) {
+	for _, spec := range []string{"dns:registry=10.0.0", "dns:kubernetes.io;nameserver=dns.test", "dns:kubernetes.io;timeout=2", "dns:kubernetes.io;ndots<=x"} {
+		if _, err := checker.ParseSpec(spec); err == nil {
+			t.Errorf("expected error for %s", spec)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"testing"
+
+	"preflight/checker"
+	"preflight/result"
+	"preflight/runner"
+)
+
+func TestExitCode(t *testing.T) {
+	resp := result.Response{
+		Failed: []result.Descriptor{
+			{CheckerName: "a", Metadata: &checker.Metadata{Level: checker.WarnLevel}},
+			{CheckerName: "b", Metadata: &checker.Metadata{Level: checker.FatalLevel}},
+		},
+		Warnings: []result.Descriptor{
+			{CheckerName: "c", Metadata: &checker.Metadata{Level: checker.PanicLevel}},
+		},
+	}
+
+	tests := map[string]int{
+		checker.InfoLevel:  result.ExitFatal,
+		checker.WarnLevel:  result.ExitFatal,
+		checker.FatalLevel: result.ExitFatal,
+		checker.PanicLevel: result.ExitOK,
+	}
+	for failOn, expected := range tests {
+		if code := result.ExitCode(resp, failOn); code != expected {
+			t.Errorf("expected exit code %d with --fail-on=%s, but got %d", expected, failOn, code)
+		}
+	}
+
+	if code := result.ExitCode(result.Response{}, checker.InfoLevel); code != result.ExitOK {
+		t.Errorf("expected exit code 0 if nothing failed, but got %d", code)
+	}
+
+	// the failed checkers take precedence over the interruption.
+	resp.Interrupted = true
+	if code := result.ExitCode(resp, checker.FatalLevel); code != result.ExitFatal {
+		t.Errorf("expected exit code %d of failed checkers, but got %d", result.ExitFatal, code)
+	}
+	if code := result.ExitCode(resp, checker.PanicLevel); code != result.ExitInterrupted {
+		t.Errorf("expected exit code %d of interrupted run, but got %d", result.ExitInterrupted, code)
+	}
+	if code := result.ExitCode(result.Response{Interrupted: true}, checker.InfoLevel); code != result.ExitInterrupted {
+		t.Errorf("expected exit code %d if interrupted before anything failed, but got %d", result.ExitInterrupted, code)
+	}
+}
+
+func TestFailFastLevel(t *testing.T) {
+	list := []checker.Interface{
+		fakeCheck{Name: "warn", Fail: true, Level: checker.WarnLevel},
+		fakeCheck{Name: "fatal", Fail: true, Level: checker.FatalLevel},
+		fakeCheck{Name: "never"},
+	}
+	r, err := runner.NewCheckRunner(list, runner.WithToleration(true), runner.WithFailFastLevel(checker.FatalLevel))
+	if err != nil {
+		t.Fatalf("failed to init runner err: %s", err)
+	}
+
+	res := r.Execute()
+	if len(res.Failed) != 2 || len(res.Passed) != 0 {
+		t.Errorf("expected return after fatal checker failed, but got %+v", res)
+	}
+	if len(res.Skipped) != 1 || res.Skipped[0].Reason != "not run after a checker at or above fatal level failed" {
+		t.Errorf("expected the remaining checker skipped, but got %+v", res.Skipped)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"preflight/checker"
+)
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected checker.Interface
+	}{
+		{spec: "memory:2048", expected: checker.MemCheck{Mem: 2048}},
+		{spec: "cpu:2", expected: checker.NumCPUCheck{NumCPU: 2}},
+		{spec: "Port:6443", expected: checker.PortCheck{Port: 6443}},
+		{spec: "fileexisting:/etc/hosts", expected: checker.FileExistingCheck{Path: "/etc/hosts"}},
+	}
+
+	for _, test := range tests {
+		c, err := checker.ParseSpec(test.spec)
+		if err != nil {
+			t.Errorf("failed to parse spec %s: %v", test.spec, err)
+			continue
+		}
+		if c != test.expected {
+			t.Errorf("expected %v of spec %s, but got %v", test.expected, test.spec, c)
+		}
+	}
+}
+
+func TestParseSpecOS(t *testing.T) {
+	c, err := checker.ParseSpec("os:Ubuntu|centos")
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	osCheck := c.(checker.OsCheck)
+	if strings.Join(osCheck.OSDistribution, ",") != "ubuntu,centos" {
+		t.Errorf("expected os distributions ubuntu,centos, but got %v", osCheck.OSDistribution)
+	}
+}
+
+func TestParseSpecErrors(t *testing.T) {
+	tests := map[string]string{
+		"port:99999":    `invalid argument "99999" of checker port`,
+		"memory":        `invalid argument "" of checker memory`,
+		"cpu:two":       `invalid argument "two" of checker cpu`,
+		"unknown:1":     "checker unknown not found",
+		":1":            "invalid checker spec",
+		"clustercheck":  "could only be declared in plan file",
+		"fileexisting:": `invalid argument "" of checker fileexisting`,
+	}
+
+	for spec, expected := range tests {
+		_, err := checker.ParseSpec(spec)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error of spec %s contains %q, but got %v", spec, expected, err)
+		}
+	}
+}
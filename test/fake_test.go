@@ -0,0 +1,54 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"errors"
+	"time"
+
+	"preflight/checker"
+)
+
+// fakeCheck is a checker used to test runner, it takes Delay to validate and fails if Fail is true.
+type fakeCheck struct {
+	Name  string
+	Delay time.Duration
+	Fail  bool
+	Level string
+}
+
+func (f fakeCheck) Type() string {
+	return "fake"
+}
+
+func (f fakeCheck) PrettyName() string {
+	return "fake:" + f.Name
+}
+
+func (f fakeCheck) Metadata() checker.Metadata {
+	level := f.Level
+	if level == "" {
+		level = checker.FatalLevel
+	}
+	return checker.Metadata{Description: "fake checker for test", Level: level}
+}
+
+func (f fakeCheck) Validate() (bool, error) {
+	time.Sleep(f.Delay)
+	if f.Fail {
+		return false, errors.New("fake checker failed")
+	}
+	return true, nil
+}
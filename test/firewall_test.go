@@ -0,0 +1,210 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"preflight/checker"
+)
+
+const iptablesSave = `# Generated by iptables-save v1.4.21 on Mon Jan  1 00:00:00 2024
+*nat
+:PREROUTING ACCEPT [0:0]
+-A PREROUTING -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.0.0.1
+COMMIT
+*filter
+:INPUT ACCEPT [0:0]
+:FORWARD ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:IN_public - [0:0]
+-A INPUT -m state --state RELATED,ESTABLISHED -j ACCEPT
+-A INPUT -i lo -j ACCEPT
+-A INPUT -m comment --comment "kubernetes firewall for dropping marked packets" -m mark --mark 0x8000/0x8000 -j DROP
+-A INPUT -j IN_public
+-A INPUT -p icmp -j ACCEPT
+-A INPUT -j REJECT --reject-with icmp-host-prohibited
+-A IN_public -p tcp -m tcp --dport 22 -m conntrack --ctstate NEW -j ACCEPT
+-A IN_public -s 10.0.0.0/8 -p tcp -m multiport --dports 6443,10250:10252 -j ACCEPT
+-A IN_public ! -s 192.168.0.0/16 -p udp -m udp --dport 8472 -j DROP
+-A IN_public -p udp -m udp --dport 8472 -j ACCEPT
+-A IN_public -j RETURN
+COMMIT
+`
+
+const nftRuleset = `{"nftables": [
+{"metainfo": {"version": "1.0.1", "release_name": "Fearless Fosdick #3", "json_schema_version": 1}},
+{"table": {"family": "inet", "name": "firewalld", "handle": 1}},
+{"chain": {"family": "inet", "table": "firewalld", "name": "filter_INPUT", "handle": 1, "type": "filter", "hook": "input", "prio": 10, "policy": "accept"}},
+{"chain": {"family": "inet", "table": "firewalld", "name": "filter_INPUT_ZONES", "handle": 2}},
+{"chain": {"family": "inet", "table": "firewalld", "name": "filter_IN_public", "handle": 3}},
+{"chain": {"family": "inet", "table": "firewalld", "name": "filter_IN_public_allow", "handle": 4}},
+{"set": {"family": "inet", "table": "firewalld", "name": "trusted_nets", "type": "ipv4_addr", "handle": 5, "flags": ["interval"], "elem": [{"prefix": {"addr": "172.16.0.0", "len": 12}}]}},
+{"rule": {"family": "inet", "table": "firewalld", "chain": "filter_INPUT", "handle": 10, "expr": [{"match": {"op": "in", "left": {"ct": {"key": "state"}}, "right": ["established", "related"]}}, {"accept": null}]}},
+{"rule": {"family": "inet", "table": "firewalld", "chain": "filter_INPUT", "handle": 11, "expr": [{"match": {"op": "==", "left": {"meta": {"key": "iifname"}}, "right": "lo"}}, {"accept": null}]}},
+{"rule": {"family": "inet", "table": "firewalld", "chain": "filter_INPUT", "handle": 12, "expr": [{"jump": {"target": "filter_INPUT_ZONES"}}]}},
+{"rule": {"family": "inet", "table": "firewalld", "chain": "filter_INPUT", "handle": 13, "expr": [{"match": {"op": "in", "left": {"ct": {"key": "state"}}, "right": "invalid"}}, {"drop": null}]}},
+{"rule": {"family": "inet", "table": "firewalld", "chain": "filter_INPUT", "handle": 14, "expr": [{"reject": {"type": "icmpx", "expr": "admin-prohibited"}}]}},
+{"rule": {"family": "inet", "table": "firewalld", "chain": "filter_INPUT_ZONES", "handle": 20, "expr": [{"goto": {"target": "filter_IN_public"}}]}},
+{"rule": {"family": "inet", "table": "firewalld", "chain": "filter_IN_public", "handle": 30, "expr": [{"jump": {"target": "filter_IN_public_allow"}}]}},
+{"rule": {"family": "inet", "table": "firewalld", "chain": "filter_IN_public_allow", "handle": 40, "expr": [{"match": {"op": "==", "left": {"payload": {"protocol": "tcp", "field": "dport"}}, "right": 22}}, {"match": {"op": "in", "left": {"ct": {"key": "state"}}, "right": {"set": ["new", "untracked"]}}}, {"accept": null}]}},
+{"rule": {"family": "inet", "table": "firewalld", "chain": "filter_IN_public_allow", "handle": 41, "comment": "kubernetes", "expr": [{"match": {"op": "==", "left": {"payload": {"protocol": "ip", "field": "saddr"}}, "right": "@trusted_nets"}}, {"match": {"op": "==", "left": {"payload": {"protocol": "tcp", "field": "dport"}}, "right": {"set": [6443, {"range": [10250, 10252]}]}}}, {"counter": {"packets": 0, "bytes": 0}}, {"accept": null}]}},
+{"rule": {"family": "inet", "table": "firewalld", "chain": "filter_IN_public_allow", "handle": 42, "expr": [{"match": {"op": "==", "left": {"payload": {"protocol": "th", "field": "dport"}}, "right": 8472}}, {"xt": {"type": "match", "name": "mark"}}, {"drop": null}]}},
+{"rule": {"family": "inet", "table": "firewalld", "chain": "filter_IN_public_allow", "handle": 43, "expr": [{"match": {"op": "==", "left": {"meta": {"key": "l4proto"}}, "right": "udp"}}, {"match": {"op": "==", "left": {"payload": {"protocol": "udp", "field": "dport"}}, "right": {"range": [8472, 8473]}}}, {"accept": null}]}},
+{"table": {"family": "inet", "name": "custom", "handle": 2}},
+{"chain": {"family": "inet", "table": "custom", "name": "input", "handle": 1, "type": "filter", "hook": "input", "prio": -10, "policy": "accept"}},
+{"rule": {"family": "inet", "table": "custom", "chain": "input", "handle": 50, "expr": [{"vmap": {"key": {"payload": {"protocol": "tcp", "field": "dport"}}, "data": {"set": [[9100, {"drop": null}], [9101, {"accept": null}]]}}}]}}
+]}`
+
+func writeCaptured(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+type firewallExpectation struct {
+	name     string
+	status   checker.Status
+	observed string
+	rule     string
+}
+
+func checkFirewallDetails(t *testing.T, report checker.Report, expected []firewallExpectation) {
+	if len(report.Details) != len(expected) {
+		t.Fatalf("expected a detail for each port and source, but got %+v", report)
+	}
+	for i, e := range expected {
+		d := report.Details[i]
+		if d.Name != e.name || d.Status != e.status || d.Observed != e.observed || !strings.Contains(d.Message, e.rule) {
+			t.Errorf("expected %s %s observed %s by %q, but got %+v", e.name, e.status, e.observed, e.rule, d)
+		}
+	}
+}
+
+func TestFirewallCheckIptables(t *testing.T) {
+	c := checker.FirewallCheck{
+		Ports:        []string{"22", "10251/tcp", "8472/udp"},
+		Sources:      []string{"10.0.0.0/8", "192.168.1.0/24"},
+		IptablesSave: writeCaptured(t, "iptables-save.txt", iptablesSave),
+	}
+	report, err := c.Evaluate(context.Background())
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if report.Status != checker.StatusFail || report.Observed != "4 of 6 accepted" {
+		t.Errorf("expected failed for the ports blocked, but got %+v", report)
+	}
+	checkFirewallDetails(t, report, []firewallExpectation{
+		{"22/tcp from 10.0.0.0/8", checker.StatusWarn, "accepted", "22/tcp from 10.0.0.0/8 is accepted, skipped 1 rule(s) with unsupported matches: iptables -A INPUT -m comment --comment \"kubernetes firewall for dropping marked packets\" -m mark --mark 0x8000/0x8000 -j DROP"},
+		{"10251/tcp from 10.0.0.0/8", checker.StatusWarn, "accepted", "-m mark"},
+		{"8472/udp from 10.0.0.0/8", checker.StatusFail, "dropped", "iptables -A IN_public ! -s 192.168.0.0/16 -p udp -m udp --dport 8472 -j DROP, skipped 1 rule(s) with unsupported matches: iptables -A INPUT -m comment --comment \"kubernetes firewall for dropping marked packets\" -m mark --mark 0x8000/0x8000 -j DROP"},
+		{"22/tcp from 192.168.1.0/24", checker.StatusWarn, "accepted", "-m mark"},
+		{"10251/tcp from 192.168.1.0/24", checker.StatusFail, "rejected", "iptables -A INPUT -j REJECT --reject-with icmp-host-prohibited"},
+		{"8472/udp from 192.168.1.0/24", checker.StatusWarn, "accepted", "-m mark"},
+	})
+
+	report, err = checker.FirewallCheck{Ports: []string{"6443"}, Interface: "lo", IptablesSave: c.IptablesSave}.Evaluate(context.Background())
+	if err != nil || report.Status != checker.StatusPass {
+		t.Errorf("expected accepted from lo, but got %+v, err: %v", report, err)
+	}
+}
+
+func TestFirewallCheckNftables(t *testing.T) {
+	c := checker.FirewallCheck{
+		Ports:      []string{"22", "6443", "8472/udp", "9100"},
+		Sources:    []string{"172.16.1.0/24", "192.168.0.0/16"},
+		NftRuleset: writeCaptured(t, "ruleset.json", nftRuleset),
+	}
+	report, err := c.Evaluate(context.Background())
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	checkFirewallDetails(t, report, []firewallExpectation{
+		{"22/tcp from 172.16.1.0/24", checker.StatusPass, "accepted", ""},
+		{"6443/tcp from 172.16.1.0/24", checker.StatusPass, "accepted", ""},
+		{"8472/udp from 172.16.1.0/24", checker.StatusWarn, "accepted", "skipped 1 rule(s) with unsupported matches: nftables inet firewalld filter_IN_public_allow handle 42"},
+		{"9100/tcp from 172.16.1.0/24", checker.StatusFail, "dropped", "nftables inet custom input handle 50"},
+		{"22/tcp from 192.168.0.0/16", checker.StatusPass, "accepted", ""},
+		{"6443/tcp from 192.168.0.0/16", checker.StatusFail, "rejected", "nftables inet firewalld filter_INPUT handle 14"},
+		{"8472/udp from 192.168.0.0/16", checker.StatusWarn, "accepted", "filter_IN_public_allow handle 42"},
+		{"9100/tcp from 192.168.0.0/16", checker.StatusFail, "dropped", "nftables inet custom input handle 50"},
+	})
+}
+
+func TestFirewallCheckInterface(t *testing.T) {
+	save := writeCaptured(t, "iptables-save.txt", `*filter
+:INPUT DROP [0:0]
+-A INPUT -i lo -j ACCEPT
+-A INPUT -i eth0 -p tcp -m tcp --dport 2379 -j DROP
+-A INPUT -i eth1 -p tcp -m tcp --dport 6443 -j ACCEPT
+-A INPUT ! -i docker0 -p tcp -m tcp --dport 10250 -j ACCEPT
+-A INPUT -p tcp -m tcp --dport 2379 -j ACCEPT
+COMMIT
+`)
+	// the rules matching specific interfaces possibly match the packet from any interface.
+	report, err := checker.FirewallCheck{Ports: []string{"6443", "10250", "2379"}, IptablesSave: save}.Evaluate(context.Background())
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	checkFirewallDetails(t, report, []firewallExpectation{
+		{"6443/tcp", checker.StatusFail, "dropped", "policy DROP of chain INPUT, 1 rule(s) possibly matching since the interface is not specified: iptables -A INPUT -i eth1"},
+		{"10250/tcp", checker.StatusFail, "dropped", "possibly matching since the interface is not specified: iptables -A INPUT ! -i docker0"},
+		{"2379/tcp", checker.StatusWarn, "accepted", "2379/tcp is accepted, 1 rule(s) possibly matching since the interface is not specified: iptables -A INPUT -i eth0"},
+	})
+
+	report, err = checker.FirewallCheck{Ports: []string{"6443", "10250", "2379"}, Interface: "eth1", IptablesSave: save}.Evaluate(context.Background())
+	if err != nil || report.Status != checker.StatusPass {
+		t.Errorf("expected accepted from eth1, but got %+v, err: %v", report, err)
+	}
+
+	ruleset := writeCaptured(t, "ruleset.json", `{"nftables": [
+{"table": {"family": "inet", "name": "filter", "handle": 1}},
+{"chain": {"family": "inet", "table": "filter", "name": "input", "handle": 1, "type": "filter", "hook": "input", "prio": 0, "policy": "accept"}},
+{"rule": {"family": "inet", "table": "filter", "chain": "input", "handle": 2, "expr": [{"match": {"op": "==", "left": {"meta": {"key": "iifname"}}, "right": "eth0"}}, {"match": {"op": "==", "left": {"payload": {"protocol": "tcp", "field": "dport"}}, "right": 2379}}, {"drop": null}]}},
+{"rule": {"family": "inet", "table": "filter", "chain": "input", "handle": 3, "expr": [{"vmap": {"key": {"meta": {"key": "iifname"}}, "data": {"set": [["lo", {"accept": null}], ["eth1", {"drop": null}]]}}}]}}
+]}`)
+	report, err = checker.FirewallCheck{Ports: []string{"2379"}, NftRuleset: ruleset}.Evaluate(context.Background())
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	checkFirewallDetails(t, report, []firewallExpectation{
+		{"2379/tcp", checker.StatusWarn, "accepted", "2 rule(s) possibly matching since the interface is not specified: nftables inet filter input handle 2; nftables inet filter input handle 3"},
+	})
+
+	report, err = checker.FirewallCheck{Ports: []string{"2379"}, Interface: "eth0", NftRuleset: ruleset}.Evaluate(context.Background())
+	if err != nil || report.Status != checker.StatusFail {
+		t.Errorf("expected dropped from eth0, but got %+v, err: %v", report, err)
+	}
+}
+
+func TestParseFirewallSpecErrors(t *testing.T) {
+	for _, spec := range []string{
+		"firewall:",
+		"firewall:65536",
+		"firewall:53/icmp",
+		"firewall:22;source=10.0.0.0/33",
+		"firewall:22;zone=public",
+	} {
+		if _, err := checker.ParseSpec(spec); err == nil {
+			t.Errorf("expected error of spec %s", spec)
+		}
+	}
+}
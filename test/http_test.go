@@ -0,0 +1,150 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"preflight/checker"
+)
+
+func TestHTTPCheck(t *testing.T) {
+	for _, k := range []string{"HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy", "NO_PROXY", "no_proxy"} {
+		t.Setenv(k, "")
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	mux.HandleFunc("/repodata/repomd.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><repomd xmlns="http://linux.duke.edu/metadata/repo"></repomd>`)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	tlsServer := httptest.NewTLSServer(mux)
+	defer tlsServer.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := "http://" + listener.Addr().String() + "/"
+	listener.Close()
+
+	fakeHost(t, map[string]string{
+		"etc/pki/registry-ca.pem": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw})),
+	})
+
+	c, err := checker.ParseSpec(strings.Join([]string{
+		"http:" + server.URL + "/v2/ status=200|401",
+		server.URL + "/repodata/repomd.xml body=<repomd",
+		server.URL + "/v2/",
+		server.URL + "/repodata/repomd.xml body=<primary",
+		server.URL + "/slow latency<=100ms",
+		tlsServer.URL + "/v2/ status=401",
+		closed,
+		"ca=/etc/pki/registry-ca.pem",
+		"timeout=2s",
+	}, ";"))
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	report, err := checker.Evaluate(context.Background(), c)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	expected := []struct {
+		status   checker.Status
+		observed string
+		message  string
+	}{
+		{checker.StatusPass, "401 in ", ""},
+		{checker.StatusPass, "200 in ", ""},
+		{checker.StatusFail, "401 in ", "failed at HTTP status: 401 Unauthorized"},
+		{checker.StatusFail, "200 in ", `failed at HTTP body: body does not match "<primary"`},
+		{checker.StatusFail, "200 in ", "failed at latency: responded in "},
+		{checker.StatusPass, "401 in ", ""},
+		{checker.StatusFail, "failed at TCP", "connection refused"},
+	}
+	if report.Status != checker.StatusFail || len(report.Details) != len(expected) {
+		t.Fatalf("expected failed, but got %+v", report)
+	}
+	for i, e := range expected {
+		d := report.Details[i]
+		if d.Status != e.status || !strings.HasPrefix(d.Observed, e.observed) || !strings.Contains(d.Message, e.message) {
+			t.Errorf("expected %s %s observed %s with message %q, but got %+v", d.Name, e.status, e.observed, e.message, d)
+		}
+	}
+
+	// the certificate of server is not trusted without the CA bundle.
+	report, err = checker.Evaluate(context.Background(), checker.HTTPCheck{Endpoints: []checker.HTTPEndpoint{{URL: tlsServer.URL}}, Timeout: "2s"})
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if d := report.Details[0]; d.Status != checker.StatusFail || d.Observed != "failed at TLS handshake" || !strings.Contains(d.Message, "x509") {
+		t.Errorf("expected failed at TLS handshake, but got %+v", d)
+	}
+}
+
+func TestHTTPCheckProxy(t *testing.T) {
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			http.Error(w, "denied by policy", http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, "proxied %s", r.URL)
+	}))
+	defer proxyServer.Close()
+	t.Setenv("HTTPS_PROXY", proxyServer.URL)
+	t.Setenv("HTTP_PROXY", proxyServer.URL)
+	t.Setenv("NO_PROXY", "")
+
+	report, err := checker.Evaluate(context.Background(), checker.HTTPCheck{Endpoints: []checker.HTTPEndpoint{
+		{URL: "https://registry.example.test/v2/"},
+		{URL: "http://mirror.example.test/repodata/repomd.xml", Body: "^proxied http://mirror.example.test/"},
+	}, Timeout: "2s"})
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if report.Status != checker.StatusFail || len(report.Details) != 2 {
+		t.Fatalf("expected failed, but got %+v", report)
+	}
+	via := " via proxy " + proxyServer.URL
+	if d := report.Details[0]; d.Observed != "failed at proxy CONNECT"+via || !strings.Contains(d.Message, "Forbidden") {
+		t.Errorf("expected failed at proxy CONNECT, but got %+v", d)
+	}
+	if d := report.Details[1]; d.Status != checker.StatusPass || !strings.HasSuffix(d.Observed, via) {
+		t.Errorf("expected passed via proxy, but got %+v", d)
+	}
+}
+
+func TestHTTPCheckInvalidSpec(t *testing.T) {
+	for _, spec := range []string{"http:registry.local/v2/", "http:https://registry.local/v2/ status=ok", "http:https://registry.local/ latency<=1", "http:https://registry.local/;cert=/etc/client.pem"} {
+		if _, err := checker.ParseSpec(spec); err == nil {
+			t.Errorf("expected error for %s", spec)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"testing"
+
+	"preflight/checker"
+)
+
+func TestKernelModuleCheck(t *testing.T) {
+	fakeHost(t, map[string]string{
+		"proc/sys/kernel/osrelease": "5.15.0-91-generic\n",
+		"proc/modules": `br_netfilter 32768 0 - Live 0x0000000000000000
+ip_vs_rr 16384 0 - Live 0x0000000000000000
+ip_vs 176128 2 ip_vs_rr, Live 0x0000000000000000
+`,
+		"lib/modules/5.15.0-91-generic/modules.builtin": `kernel/fs/overlayfs/overlay.ko
+`,
+		"lib/modules/5.15.0-91-generic/modules.dep": `kernel/net/bridge/br_netfilter.ko: kernel/net/bridge/bridge.ko
+kernel/net/netfilter/nf_conntrack.ko.zst: kernel/net/netfilter/nf_defrag_ipv6.ko
+kernel/net/netfilter/ipvs/ip_vs_rr.ko:
+kernel/net/netfilter/ipvs/ip_vs.ko:
+`,
+	})
+
+	c, err := checker.ParseSpec("kernelmodule:br_netfilter|overlay|ip_vs*|nf-conntrack")
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	report, err := checker.Evaluate(context.Background(), c)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if report.Status != checker.StatusWarn || report.Observed != "kernel 5.15.0-91-generic" {
+		t.Errorf("expected warned since nf_conntrack is loadable, but got %+v", report)
+	}
+
+	expected := map[string]string{
+		"br_netfilter": checker.ModuleLoaded,
+		"overlay":      checker.ModuleBuiltin,
+		"ip_vs":        checker.ModuleLoaded,
+		"ip_vs_rr":     checker.ModuleLoaded,
+		"nf_conntrack": checker.ModuleLoadable,
+	}
+	if len(report.Details) != len(expected) {
+		t.Fatalf("expected a detail for each module, but got %+v", report.Details)
+	}
+	for _, d := range report.Details {
+		if expected[d.Name] != d.Observed {
+			t.Errorf("expected module %s %s, but got %s", d.Name, expected[d.Name], d.Observed)
+		}
+	}
+
+	report, err = checker.KernelModuleCheck{Modules: []string{"nf_conntrack", "wireguard"}, RequireLoaded: true}.Evaluate(context.Background())
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if report.Status != checker.StatusFail || report.Details[0].Status != checker.StatusFail || report.Details[1].Observed != checker.ModuleMissing {
+		t.Errorf("expected loadable and missing modules failed, but got %+v", report)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"preflight/checker"
+)
+
+func TestLSMCheckSELinux(t *testing.T) {
+	fakeHost(t, map[string]string{
+		"proc/cmdline":           "BOOT_IMAGE=/vmlinuz-4.18.0 root=/dev/mapper/rl-root ro\n",
+		"sys/fs/selinux/enforce": "0",
+		"etc/selinux/config":     "# SELINUX=disabled\nSELINUX=enforcing\nSELINUXTYPE=targeted\n",
+	})
+
+	c, err := checker.ParseSpec("lsm:selinux=permissive|disabled;selinux-policy=targeted;apparmor=disabled")
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	report, err := checker.Evaluate(context.Background(), c)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+
+	expected := []struct {
+		name     string
+		status   checker.Status
+		observed string
+	}{
+		{"selinux", checker.StatusPass, "permissive"},
+		{"selinux after reboot", checker.StatusFail, "enforcing"},
+		{"selinux policy", checker.StatusPass, "targeted"},
+		{"apparmor", checker.StatusPass, "disabled"},
+	}
+	if report.Status != checker.StatusFail || report.Observed != "selinux permissive, apparmor disabled" || len(report.Details) != len(expected) {
+		t.Fatalf("expected failed for the persisted mode, but got %+v", report)
+	}
+	for i, e := range expected {
+		d := report.Details[i]
+		if d.Name != e.name || d.Status != e.status || d.Observed != e.observed {
+			t.Errorf("expected %s %s observed %s, but got %+v", e.name, e.status, e.observed, d)
+		}
+	}
+	if msg := report.Details[1].Message; !strings.Contains(msg, "SELINUX=enforcing of /etc/selinux/config") || !strings.Contains(msg, "setenforce 1") {
+		t.Errorf("expected the difference explained, but got %s", msg)
+	}
+}
+
+func TestLSMCheckKernelCmdline(t *testing.T) {
+	fakeHost(t, map[string]string{
+		"proc/cmdline":           "BOOT_IMAGE=/vmlinuz-5.15.0 root=/dev/sda1 ro selinux=0 apparmor=0\n",
+		"sys/fs/selinux/enforce": "1",
+		"etc/selinux/config":     "SELINUX=enforcing\nSELINUXTYPE=mls\n",
+		"sys/kernel/security/apparmor/profiles": `/usr/sbin/chronyd (enforce)
+/usr/bin/man (enforce)
+/usr/sbin/cups-browsed (complain)
+`,
+	})
+
+	report, err := checker.LSMCheck{SELinux: "enforcing", SELinuxPolicy: "targeted"}.Evaluate(context.Background())
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	expected := []struct {
+		name     string
+		status   checker.Status
+		observed string
+	}{
+		{"selinux", checker.StatusPass, "enforcing"},
+		{"selinux after reboot", checker.StatusFail, "disabled"},
+		{"selinux policy", checker.StatusFail, "mls"},
+		{"apparmor", checker.StatusPass, "enabled"},
+		{"apparmor profiles", checker.StatusPass, "3 loaded, 2 enforce, 1 complain"},
+		{"apparmor after reboot", checker.StatusWarn, "disabled"},
+	}
+	if report.Status != checker.StatusFail || len(report.Details) != len(expected) {
+		t.Fatalf("expected failed with a detail for each mode, but got %+v", report)
+	}
+	for i, e := range expected {
+		d := report.Details[i]
+		if d.Name != e.name || d.Status != e.status || d.Observed != e.observed {
+			t.Errorf("expected %s %s observed %s, but got %+v", e.name, e.status, e.observed, d)
+		}
+	}
+	if msg := report.Details[1].Message; !strings.Contains(msg, "selinux=0 of kernel cmdline") {
+		t.Errorf("expected the kernel cmdline explained, but got %s", msg)
+	}
+}
+
+func TestParseLSMSpecErrors(t *testing.T) {
+	for _, spec := range []string{
+		"lsm:selinux=on",
+		"lsm:selinux=permissive|",
+		"lsm:apparmor=enforcing",
+		"lsm:smack=enabled",
+		"lsm:selinux",
+	} {
+		if _, err := checker.ParseSpec(spec); err == nil {
+			t.Errorf("expected error of spec %s", spec)
+		}
+	}
+}
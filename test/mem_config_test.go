@@ -0,0 +1,90 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"testing"
+
+	"preflight/checker"
+)
+
+func TestMemConfigCheck(t *testing.T) {
+	fakeHost(t, map[string]string{
+		"proc/swaps": `Filename				Type		Size		Used		Priority
+/swap.img                               file		2097148		0		-2
+`,
+		"proc/meminfo": `MemTotal:       16318040 kB
+HugePages_Total:       0
+Hugepagesize:       2048 kB
+`,
+		"proc/sys/vm/overcommit_memory":                            "1\n",
+		"sys/kernel/mm/transparent_hugepage/enabled":               "always madvise [never]\n",
+		"sys/kernel/mm/transparent_hugepage/defrag":                "always defer defer+madvise [madvise] never\n",
+		"sys/kernel/mm/hugepages/hugepages-2048kB/nr_hugepages":    "1024\n",
+		"sys/kernel/mm/hugepages/hugepages-1048576kB/nr_hugepages": "0\n",
+	})
+
+	c, err := checker.ParseSpec("memconfig:swap=off;overcommit=0|1;thp=never;thp-defrag=never;hugepages-2Mi>=1024;hugepages-1Gi>=2")
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	report, err := checker.Evaluate(context.Background(), c)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+
+	expected := []struct {
+		name     string
+		status   checker.Status
+		observed string
+	}{
+		{"swap", checker.StatusFail, "2.0Gi on /swap.img"},
+		{"vm.overcommit_memory", checker.StatusPass, "1"},
+		{"transparent hugepage", checker.StatusPass, "never"},
+		{"transparent hugepage defrag", checker.StatusFail, "madvise"},
+		{"hugepages-2Mi", checker.StatusPass, "1024"},
+		{"hugepages-1Gi", checker.StatusFail, "0"},
+	}
+	if report.Status != checker.StatusFail || len(report.Details) != len(expected) {
+		t.Fatalf("expected failed with a detail for each requirement, but got %+v", report)
+	}
+	for i, e := range expected {
+		d := report.Details[i]
+		if d.Name != e.name || d.Status != e.status || d.Observed != e.observed {
+			t.Errorf("expected %s %s observed %s, but got %+v", e.name, e.status, e.observed, d)
+		}
+	}
+
+	report, err = checker.MemConfigCheck{Swap: "4Gi"}.Evaluate(context.Background())
+	if err != nil || report.Status != checker.StatusPass {
+		t.Errorf("expected swap within limit passed, but got %+v, err: %v", report, err)
+	}
+}
+
+func TestParseMemConfigSpecErrors(t *testing.T) {
+	for _, spec := range []string{
+		"memconfig:",
+		"memconfig:swap=on",
+		"memconfig:swap<=1Xi",
+		"memconfig:hugepages-2M>=1",
+		"memconfig:hugepages-2Mi<=1",
+		"memconfig:numa=off",
+	} {
+		if _, err := checker.ParseSpec(spec); err == nil {
+			t.Errorf("expected error of spec %s", spec)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"preflight/checker"
+	"preflight/plan"
+)
+
+func TestLoadPlan(t *testing.T) {
+	p, err := plan.Load("../examples/plan.yaml")
+	if err != nil {
+		t.Fatalf("failed to load plan: %v", err)
+	}
+
+	if len(p.Checkers) != 5 {
+		t.Fatalf("expected 5 checkers, but got %d", len(p.Checkers))
+	}
+	if level := p.Checkers[3].Metadata().Level; level != checker.WarnLevel {
+		t.Errorf("expected level of port checker is overridden to warn, but got %s", level)
+	}
+	cluster, ok := p.Checkers[4].(checker.ClusterCheck)
+	if !ok {
+		t.Fatalf("expected cluster checker, but got %T", p.Checkers[4])
+	}
+	if len(cluster.AuthInfo.Hosts) != 1 {
+		t.Errorf("expected cluster checker use the inventory of plan, but got %v", cluster.AuthInfo)
+	}
+}
+
+func TestParsePlanErrors(t *testing.T) {
+	data := `version: v1
+checkers:
+  - type: prot
+  - type: port
+    level: high
+    args:
+      port: abc
+      bogus: 1
+  - type: clustercheck
+`
+	_, err := plan.Parse("plan.yaml", []byte(data))
+	if err == nil {
+		t.Fatal("expected error, but got nil")
+	}
+
+	for _, expected := range []string{
+		`plan.yaml:3:11: unknown checker type "prot"`,
+		`plan.yaml:5:12: unknown level "high"`,
+		`plan.yaml:7: cannot unmarshal !!str`,
+		`plan.yaml:8:7: unknown field "bogus"`,
+		`plan.yaml:9:5: checker clustercheck requires the cluster inventory`,
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error contains %q, but got:\n%v", expected, err)
+		}
+	}
+}
+
+func TestParseJSONPlan(t *testing.T) {
+	data := `{
+  "version": "v1",
+  "checkers": [{"type": "cpu", "args": {"numCPU": 2}}]
+}`
+	p, err := plan.Parse("plan.json", []byte(data))
+	if err != nil {
+		t.Fatalf("failed to parse plan: %v", err)
+	}
+	if c, ok := p.Checkers[0].(checker.NumCPUCheck); !ok || c.NumCPU != 2 {
+		t.Errorf("expected cpu:2 checker, but got %v", p.Checkers[0])
+	}
+}
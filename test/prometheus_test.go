@@ -0,0 +1,89 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"preflight/checker"
+	"preflight/result"
+)
+
+func prometheusResponse() result.Response {
+	meta := &checker.Metadata{Level: checker.FatalLevel}
+	return result.Response{
+		Passed: []result.Descriptor{
+			{CheckerName: "cpu:2", CheckerType: "cpu", DurationSeconds: 0.5, Metadata: meta},
+		},
+		Failed: []result.Descriptor{
+			{CheckerName: `file:"a"`, CheckerType: "fileexisting", Host: "10.0.0.1", DurationSeconds: 1, Metadata: meta},
+		},
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	var buf bytes.Buffer
+	if err := result.WritePrometheus(&buf, prometheusResponse(), time.Unix(1650000000, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP preflight_check_passed Whether the checker passed, 1 means passed and 0 means not.
+# TYPE preflight_check_passed gauge
+preflight_check_passed{type="cpu",name="cpu:2",level="fatal",host="localhost"} 1
+preflight_check_passed{type="fileexisting",name="file:\"a\"",level="fatal",host="10.0.0.1"} 0
+# HELP preflight_check_duration_seconds Duration of the checker evaluated in seconds.
+# TYPE preflight_check_duration_seconds gauge
+preflight_check_duration_seconds{type="cpu",name="cpu:2",level="fatal",host="localhost"} 0.5
+preflight_check_duration_seconds{type="fileexisting",name="file:\"a\"",level="fatal",host="10.0.0.1"} 1
+# HELP preflight_last_run_timestamp Unix timestamp of the last preflight run in seconds.
+# TYPE preflight_last_run_timestamp gauge
+preflight_last_run_timestamp 1650000000
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected metrics:\n%s", buf.String())
+	}
+}
+
+func TestExportPrometheus(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "preflight.prom")
+	if err := os.WriteFile(path, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := result.ExportPrometheus(path, prometheusResponse(), time.Now()); err != nil {
+		t.Fatalf("failed to export metrics: %s", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "# HELP preflight_check_passed") {
+		t.Errorf("expected metrics replaced, but got %s", data)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected temporary file removed, but got %d files", len(entries))
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"preflight/checker"
+	"preflight/result"
+	"preflight/runner"
+)
+
+// reportCheck is a checker used to test results dispatched by report status.
+type reportCheck struct {
+	Name   string
+	Report checker.Report
+	Err    error
+	Legacy bool
+}
+
+func (r reportCheck) Type() string {
+	return "report"
+}
+
+func (r reportCheck) PrettyName() string {
+	return "report:" + r.Name
+}
+
+func (r reportCheck) Metadata() checker.Metadata {
+	return checker.Metadata{Description: "report checker for test", Level: checker.FatalLevel}
+}
+
+func (r reportCheck) Validate() (bool, error) {
+	if r.Legacy {
+		// not passed without error must not be regarded as passed.
+		return false, nil
+	}
+	return checker.ValidateReport(r.Evaluate(context.Background()))
+}
+
+func (r reportCheck) Evaluate(ctx context.Context) (checker.Report, error) {
+	return r.Report, r.Err
+}
+
+// legacyCheck hides Evaluate of reportCheck.
+type legacyCheck struct {
+	checker.Interface
+}
+
+func TestResultStatus(t *testing.T) {
+	list := []checker.Interface{
+		reportCheck{Name: "pass", Report: checker.Passed("8", ">=4")},
+		reportCheck{Name: "fail", Report: checker.Failed("2", ">=4", "cpu not enough")},
+		reportCheck{Name: "error", Err: errors.New("permission denied")},
+		reportCheck{Name: "warn", Report: checker.Report{Status: checker.StatusWarn, Message: "almost full"}},
+		legacyCheck{reportCheck{Name: "legacy", Legacy: true}},
+		reportCheck{Name: "empty"},
+	}
+	r, err := runner.NewCheckRunner(list, runner.WithConcurrency(len(list)))
+	if err != nil {
+		t.Fatalf("failed to init runner err: %s", err)
+	}
+
+	res := r.Execute()
+	if len(res.Passed) != 1 || len(res.Errors) != 1 || len(res.Warnings) != 1 || len(res.Failed) != 3 {
+		t.Fatalf("unexpected results %+v", res)
+	}
+
+	passed := res.Passed[0]
+	if passed.Status != checker.StatusPass || !passed.Passed || passed.Observed != "8" || passed.Expected != ">=4" {
+		t.Errorf("unexpected passed result %+v", passed)
+	}
+	if passed.StartTime.IsZero() || passed.Duration < 0 || time.Since(passed.StartTime) < passed.Duration {
+		t.Errorf("unexpected start time %s and duration %s", passed.StartTime, passed.Duration)
+	}
+	if res.Errors[0].Status != checker.StatusError || res.Errors[0].ErrorMessage != "permission denied" {
+		t.Errorf("unexpected error result %+v", res.Errors[0])
+	}
+	for _, f := range res.Failed {
+		if f.Status != checker.StatusFail || f.Passed {
+			t.Errorf("unexpected failed result %+v", f)
+		}
+	}
+	if res.Failed[0].ErrorMessage != "cpu not enough" {
+		t.Errorf("expected message of failed report, but got %s", res.Failed[0].ErrorMessage)
+	}
+
+	resp := result.NewDefaultFormatter(res).Format(result.WithIgnores([]string{"report"}))
+	if len(resp.Failed) != 0 || len(resp.Errors) != 0 || len(resp.Warnings) != 5 {
+		t.Errorf("expected ignored failures and errors downgraded to warnings, but got %+v", resp)
+	}
+	if code := result.ExitCode(result.NewDefaultFormatter(res).Format(), checker.InfoLevel); code != result.ExitFatal {
+		t.Errorf("expected exit code %d, but got %d", result.ExitFatal, code)
+	}
+}
+
+func TestReportDetails(t *testing.T) {
+	report := checker.ReportDetails([]checker.Detail{
+		{Name: "a", Status: checker.StatusPass},
+		{Name: "b", Status: checker.StatusWarn, Message: "b warned"},
+		{Name: "c", Status: checker.StatusFail, Message: "c failed"},
+	})
+	if report.Status != checker.StatusFail || report.Message != "b warned; c failed" {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"preflight/checker"
+	"preflight/plan"
+)
+
+// TestSchemaExamples make sure the examples declared by checkers are valid, since they are shown by preflight explain.
+func TestSchemaExamples(t *testing.T) {
+	for _, checkType := range checker.GetAllCheckerTypes() {
+		c, err := checker.GetCheckersByType(checkType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		schema := checker.GetSchema(c)
+		if schema.Arg == nil && len(schema.Parameters) == 0 {
+			t.Errorf("expected checker %s declares its schema", checkType)
+			continue
+		}
+
+		if schema.Arg != nil {
+			if _, err := checker.NewChecker(checkType, schema.Arg.Example); err != nil {
+				t.Errorf("invalid example of checker %s argument: %v", checkType, err)
+			}
+		}
+
+		args := []string{"version: v1", "checkers:", "  - type: " + checkType, "    args:"}
+		for _, p := range schema.Parameters {
+			if p.Name == "" || p.Type == "" {
+				t.Errorf("expected name and type of checker %s parameter, but got %+v", checkType, p)
+			}
+			if p.Example != "" {
+				args = append(args, fmt.Sprintf("      %s: %s", p.Name, p.Example))
+			}
+		}
+		if _, err := plan.Parse("example.yaml", []byte(strings.Join(args, "\n"))); err != nil {
+			t.Errorf("invalid example of checker %s parameters: %v", checkType, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"preflight/checker"
+)
+
+func TestSysctlCheck(t *testing.T) {
+	root := fakeHost(t, map[string]string{
+		"proc/sys/net/ipv4/ip_forward":              "1\n",
+		"proc/sys/vm/max_map_count":                 "262144\n",
+		"proc/sys/fs/inotify/max_user_watches":      "524288\n",
+		"proc/sys/net/ipv4/ip_local_port_range":     "32768\t60999\n",
+		"proc/sys/net/ipv4/conf/eth0.100/rp_filter": "0\n",
+		"usr/lib/sysctl.d/50-default.conf":          "net.ipv4.ip_forward = 0\nvm.max_map_count = 65530\n",
+		"etc/sysctl.d/50-default.conf":              "# overrides the one of /usr/lib\n-net.ipv4.ip_forward = 1\n",
+		"etc/sysctl.d/60-inotify.conf":              "fs/inotify/max_user_watches=524288\n",
+		"etc/sysctl.conf":                           "; applied last\nvm.max_map_count = 262144\nfs.inotify.max_user_watches = 8192\n",
+	})
+	// systemd-sysctl applies /etc/sysctl.conf by the link of 99-sysctl.conf.
+	if err := os.Symlink("../sysctl.conf", filepath.Join(root, "etc/sysctl.d/99-sysctl.conf")); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := checker.ParseSpec("sysctl:net.ipv4.ip_forward=1;vm.max_map_count>=262144;fs.inotify.max_user_watches=524288..1048576;" +
+		"net.ipv4.ip_local_port_range=32768 60999;net.ipv4.conf.eth0/100.rp_filter!=1;net.bridge.bridge-nf-call-iptables=1")
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	report, err := checker.Evaluate(context.Background(), c)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if report.Status != checker.StatusFail || len(report.Details) != 6 {
+		t.Fatalf("expected failed with 6 details, but got %+v", report)
+	}
+
+	expected := []struct {
+		status  checker.Status
+		message string
+	}{
+		{checker.StatusPass, "persisted in /etc/sysctl.d/50-default.conf"},
+		{checker.StatusPass, "persisted in /etc/sysctl.d/99-sysctl.conf"},
+		{checker.StatusWarn, "it will be 8192 after reboot as set in /etc/sysctl.d/99-sysctl.conf"},
+		{checker.StatusWarn, "not persisted"},
+		{checker.StatusWarn, "not persisted"},
+		{checker.StatusFail, "not found"},
+	}
+	for i, e := range expected {
+		d := report.Details[i]
+		if d.Status != e.status || !strings.Contains(d.Message, e.message) {
+			t.Errorf("expected %s %s with message %q, but got %+v", d.Name, e.status, e.message, d)
+		}
+	}
+
+	report, err = checker.SysctlCheck{Params: []string{"net.ipv4.ip_local_port_range=32768 60999"}, RequirePersisted: true}.Evaluate(context.Background())
+	if err != nil || report.Status != checker.StatusFail {
+		t.Errorf("expected not persisted param failed, but got %+v, err: %v", report, err)
+	}
+}
+
+func TestParseSysctlSpecErrors(t *testing.T) {
+	for _, spec := range []string{
+		"sysctl:",
+		"sysctl:vm.max_map_count",
+		"sysctl:vm.max_map_count>=abc",
+		"sysctl:fs.inotify.max_user_watches=10..1",
+		"sysctl:=1",
+	} {
+		if _, err := checker.ParseSpec(spec); err == nil {
+			t.Errorf("expected error of spec %s", spec)
+		}
+	}
+}
+
+func TestSysctlCheckUnsigned(t *testing.T) {
+	fakeHost(t, map[string]string{
+		"proc/sys/kernel/shmmax":              "18446744073692774399\n",
+		"proc/sys/kernel/sched_rt_runtime_us": "-1\n",
+		"etc/sysctl.conf":                     "kernel.shmmax = 18446744073692774399\nkernel.sched_rt_runtime_us = -1\n",
+	})
+
+	report, err := checker.SysctlCheck{Params: []string{
+		"kernel.shmmax>=68719476736",
+		"kernel.shmmax=68719476736..18446744073709551615",
+		"kernel.sched_rt_runtime_us<0",
+	}}.Evaluate(context.Background())
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if report.Status != checker.StatusPass {
+		t.Errorf("expected unsigned 64-bit and negative values compared as integers, but got %+v", report)
+	}
+}
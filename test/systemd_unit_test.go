@@ -0,0 +1,153 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"preflight/checker"
+	"preflight/pkg/system"
+)
+
+const systemctlShow = `Id=firewalld.service
+Names=firewalld.service dbus-org.fedoraproject.FirewallD1.service
+LoadState=loaded
+ActiveState=inactive
+SubState=dead
+UnitFileState=disabled
+ActiveEnterTimestampMonotonic=0
+
+Id=chronyd.service
+Names=chronyd.service
+LoadState=loaded
+ActiveState=active
+SubState=running
+UnitFileState=enabled
+ActiveEnterTimestampMonotonic=120000000
+
+Id=ntpd.service
+Names=ntpd.service
+LoadState=not-found
+ActiveState=inactive
+SubState=dead
+UnitFileState=
+ActiveEnterTimestampMonotonic=0
+
+Id=kubelet.service
+Names=kubelet.service
+LoadState=loaded
+ActiveState=activating
+SubState=auto-restart
+UnitFileState=enabled
+ActiveEnterTimestampMonotonic=0
+
+Id=docker.service
+Names=docker.service
+LoadState=masked
+ActiveState=inactive
+SubState=dead
+UnitFileState=masked
+ActiveEnterTimestampMonotonic=0
+`
+
+func TestParseSystemctlShow(t *testing.T) {
+	units, err := system.ParseSystemctlShow(strings.NewReader(systemctlShow))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if len(units) != 5 || units[1]["ActiveState"] != "active" || units[2]["UnitFileState"] != "" {
+		t.Fatalf("unexpected units %+v", units)
+	}
+	if !units[0].HasName("dbus-org.fedoraproject.FirewallD1.service") || units[0].HasName("chronyd.service") {
+		t.Errorf("unexpected names of %+v", units[0])
+	}
+	if _, err := system.ParseSystemctlShow(strings.NewReader("Id=a.service\ninvalid\n")); err == nil {
+		t.Errorf("expected error for invalid line")
+	}
+}
+
+func TestSystemdUnitCheck(t *testing.T) {
+	fakeHost(t, map[string]string{"proc/uptime": "420.50 1600.00\n"})
+	captured := filepath.Join(t.TempDir(), "systemctl-show.txt")
+	if err := os.WriteFile(captured, []byte(systemctlShow), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := checker.ParseSpec("systemdunit:firewalld:active=inactive|failed;ntpd|chronyd:active=active file=enabled uptime>=5m;kubelet:sub=!running;docker:file=masked")
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	s := c.(checker.SystemdUnitCheck)
+	s.SystemctlShow = captured
+	report, err := checker.Evaluate(context.Background(), s)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	expected := []struct {
+		name     string
+		observed string
+	}{
+		{"firewalld", "firewalld.service inactive/dead disabled"},
+		{"ntpd|chronyd", "chronyd.service active/running enabled up 5m0s"},
+		{"kubelet", "kubelet.service activating/auto-restart enabled"},
+		{"docker", "docker.service inactive/dead masked"},
+	}
+	if report.Status != checker.StatusPass || len(report.Details) != len(expected) {
+		t.Fatalf("expected passed, but got %+v", report)
+	}
+	for i, e := range expected {
+		if d := report.Details[i]; d.Name != e.name || d.Observed != e.observed {
+			t.Errorf("expected %s observed %s, but got %+v", e.name, e.observed, d)
+		}
+	}
+
+	s.Rules = []checker.SystemdUnitRule{
+		{Units: []string{"chronyd", "ntpd"}, ActiveState: []string{"active"}, MinUptime: "10m"},
+		{Units: []string{"kubelet.service"}, ActiveState: []string{"inactive", "failed"}},
+		{Units: []string{"containerd"}, UnitFileState: []string{"enabled"}},
+	}
+	report, err = checker.Evaluate(context.Background(), s)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	messages := []string{
+		"none of chronyd|ntpd is satisfied: chronyd.service is up for 5m0s, expected 10m; ntpd.service ActiveState is inactive, expected active, ntpd.service is not up, expected up for 10m",
+		"kubelet.service ActiveState is activating, expected inactive|failed",
+		"containerd.service UnitFileState is empty, expected enabled",
+	}
+	if report.Status != checker.StatusFail || len(report.Details) != len(messages) {
+		t.Fatalf("expected failed, but got %+v", report)
+	}
+	for i, m := range messages {
+		if d := report.Details[i]; d.Status != checker.StatusFail || d.Message != m {
+			t.Errorf("expected failed with %q, but got %+v", m, d)
+		}
+	}
+	if d := report.Details[2]; d.Observed != "containerd.service inactive/dead not-found" {
+		t.Errorf("expected containerd not found, but got %+v", d)
+	}
+}
+
+func TestSystemdUnitCheckInvalidSpec(t *testing.T) {
+	for _, spec := range []string{"systemdunit:chronyd", "systemdunit:chronyd:state=active", "systemdunit:chronyd:uptime>=5", "systemdunit:chronyd:active=!"} {
+		if _, err := checker.ParseSpec(spec); err == nil {
+			t.Errorf("expected error for %s", spec)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"preflight/checker"
+	"preflight/runner"
+)
+
+func TestCheckTimeout(t *testing.T) {
+	list := []checker.Interface{
+		fakeCheck{Name: "fast"},
+		fakeCheck{Name: "slow", Delay: time.Second},
+		fakeCheck{Name: "failed", Fail: true},
+	}
+	r, err := runner.NewCheckRunner(list, runner.WithCheckTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to init runner err: %s", err)
+	}
+
+	res := r.Execute()
+	if len(res.Passed) != 1 || len(res.Failed) != 1 || len(res.TimedOut) != 1 {
+		t.Fatalf("expected 1 passed, 1 failed and 1 timed out, but got %+v", res)
+	}
+	if res.TimedOut[0].Checker.PrettyName() != "fake:slow" {
+		t.Errorf("expected fake:slow timed out, but got %s", res.TimedOut[0].Checker.PrettyName())
+	}
+	if res.Interrupted {
+		t.Error("expected runner not interrupted")
+	}
+}
+
+func TestGlobalTimeout(t *testing.T) {
+	list := []checker.Interface{
+		fakeCheck{Name: "fast"},
+		fakeCheck{Name: "slow", Delay: time.Second},
+		fakeCheck{Name: "never"},
+	}
+	r, err := runner.NewCheckRunner(list, runner.WithTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to init runner err: %s", err)
+	}
+
+	res := r.Execute()
+	if len(res.Passed) != 1 || len(res.TimedOut) != 1 || !res.Interrupted {
+		t.Errorf("expected 1 passed, 1 timed out and interrupted, but got %+v", res)
+	}
+}
+
+func TestExecuteCanceled(t *testing.T) {
+	list := []checker.Interface{
+		fakeCheck{Name: "fast"},
+		fakeCheck{Name: "slow", Delay: time.Second},
+		fakeCheck{Name: "never"},
+	}
+	r, err := runner.NewCheckRunner(list)
+	if err != nil {
+		t.Fatalf("failed to init runner err: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	res := r.ExecuteContext(ctx)
+	if len(res.Passed) != 1 || len(res.TimedOut) != 0 || !res.Interrupted {
+		t.Errorf("expected 1 passed and interrupted, but got %+v", res)
+	}
+}
+
+// blockingEvaluator ignores ctx like the checkers stat a stale mount.
+type blockingEvaluator struct {
+	fakeCheck
+}
+
+func (b blockingEvaluator) Evaluate(ctx context.Context) (checker.Report, error) {
+	time.Sleep(b.Delay)
+	return checker.Passed("done", "done"), nil
+}
+
+func TestEvaluatorTimeout(t *testing.T) {
+	list := []checker.Interface{
+		blockingEvaluator{fakeCheck{Name: "fast"}},
+		blockingEvaluator{fakeCheck{Name: "hung", Delay: 10 * time.Second}},
+	}
+	r, err := runner.NewCheckRunner(list, runner.WithCheckTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to init runner err: %s", err)
+	}
+
+	start := time.Now()
+	res := r.Execute()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected the hung evaluator stopped by timeout, but it took %s", elapsed)
+	}
+	if len(res.Passed) != 1 || len(res.TimedOut) != 1 || res.TimedOut[0].Checker.PrettyName() != "fake:hung" {
+		t.Errorf("expected 1 passed and fake:hung timed out, but got %+v", res)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"preflight/checker"
+)
+
+var ulimitHostFiles = map[string]string{
+	"proc/sys/fs/nr_open":           "1048576",
+	"proc/sys/fs/file-max":          "9223372036854775807",
+	"proc/sys/kernel/threads-max":   "127454",
+	"etc/passwd":                    "root:x:0:0:root:/root:/bin/bash\nmysql:x:27:27:MySQL Server:/var/lib/mysql:/bin/false\n",
+	"etc/group":                     "root:x:0:\nmysql:x:27:\ndba:x:1001:mysql\n",
+	"etc/security/limits.conf":      "# <domain> <type> <item> <value>\n*    soft    nofile    4096\n*    hard    nofile    65536\n",
+	"etc/security/limits.d/90.conf": "@dba  -  nofile  65536\nmysql  soft  nproc  unlimited\nroot  -  nofile  2097152\n",
+}
+
+func TestUlimitCheckUser(t *testing.T) {
+	fakeHost(t, ulimitHostFiles)
+
+	c, err := checker.ParseSpec("ulimit:nofile>=65536;nproc.soft>=4096;user=mysql")
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	report, err := checker.Evaluate(context.Background(), c)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	expected := []struct {
+		name     string
+		status   checker.Status
+		observed string
+	}{
+		{"nofile soft", checker.StatusPass, "65536 by /etc/security/limits.d/90.conf"},
+		{"nofile hard", checker.StatusPass, "65536 by /etc/security/limits.d/90.conf"},
+		{"nproc soft", checker.StatusPass, "unlimited by /etc/security/limits.d/90.conf"},
+		{"fs.nr_open", checker.StatusPass, "1048576"},
+		{"fs.file-max", checker.StatusPass, "9223372036854775807"},
+	}
+	if report.Status != checker.StatusPass || len(report.Details) != len(expected) {
+		t.Fatalf("expected passed, but got %+v", report)
+	}
+	for i, e := range expected {
+		d := report.Details[i]
+		if d.Name != e.name || d.Status != e.status || d.Observed != e.observed {
+			t.Errorf("expected %s %s observed %s, but got %+v", e.name, e.status, e.observed, d)
+		}
+	}
+}
+
+func TestUlimitCheckRoot(t *testing.T) {
+	fakeHost(t, ulimitHostFiles)
+
+	// the wildcard does not apply to root, and the limit above fs.nr_open could not be set.
+	report, err := checker.Evaluate(context.Background(), checker.UlimitCheck{Limits: []string{"nofile>=65536", "core.soft>=unlimited"}})
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if report.Status != checker.StatusFail || len(report.Details) != 5 {
+		t.Fatalf("expected failed, but got %+v", report)
+	}
+	if d := report.Details[0]; d.Status != checker.StatusFail || !strings.Contains(d.Message, "exceeds fs.nr_open 1048576") {
+		t.Errorf("expected nofile soft failed for fs.nr_open, but got %+v", d)
+	}
+	if d := report.Details[2]; d.Name != "core soft" || d.Status != checker.StatusFail || d.Observed != "0 by default" {
+		t.Errorf("expected core soft failed by default, but got %+v", d)
+	}
+}
+
+func TestUlimitCheckService(t *testing.T) {
+	files := map[string]string{
+		"etc/systemd/system.conf":                                   "[Manager]\n#DefaultLimitNOFILE=1024:524288\nDefaultLimitMEMLOCK=64M\n",
+		"usr/lib/systemd/system/containerd.service":                 "[Unit]\nDescription=containerd\n[Service]\nLimitNOFILE=infinity\nLimitNPROC=infinity\nLimitCORE=infinity\n",
+		"usr/lib/systemd/system/containerd.service.d/05-limit.conf": "[Service]\nLimitNOFILE=1048576\nLimitCORE=\n",
+		// masked by the drop-in with the same name in /etc/systemd/system.
+		"usr/lib/systemd/system/containerd.service.d/10-limit.conf": "[Service]\nLimitNOFILE=1024\n",
+		"etc/systemd/system/containerd.service.d/10-limit.conf":     "[Service]\nLimitNOFILE=65536:1048576\n",
+	}
+	for k, v := range ulimitHostFiles {
+		files[k] = v
+	}
+	fakeHost(t, files)
+
+	c, err := checker.ParseSpec("ulimit:nofile>=1048576;nproc>=65535;memlock>=65536;core.hard>=unlimited;service=containerd")
+	if err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+	report, err := checker.Evaluate(context.Background(), c)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	expected := []struct {
+		name     string
+		status   checker.Status
+		observed string
+	}{
+		{"nofile soft", checker.StatusFail, "65536 by /etc/systemd/system/containerd.service.d/10-limit.conf"},
+		{"nofile hard", checker.StatusPass, "1048576 by /etc/systemd/system/containerd.service.d/10-limit.conf"},
+		{"nproc soft", checker.StatusPass, "unlimited by /usr/lib/systemd/system/containerd.service"},
+		{"nproc hard", checker.StatusPass, "unlimited by /usr/lib/systemd/system/containerd.service"},
+		{"memlock soft", checker.StatusPass, "65536 by /etc/systemd/system.conf"},
+		{"memlock hard", checker.StatusPass, "65536 by /etc/systemd/system.conf"},
+		{"core hard", checker.StatusPass, "unlimited by /usr/lib/systemd/system/containerd.service.d/05-limit.conf"},
+		{"fs.nr_open", checker.StatusPass, "1048576"},
+		{"fs.file-max", checker.StatusPass, "9223372036854775807"},
+	}
+	if report.Status != checker.StatusFail || len(report.Details) != len(expected) {
+		t.Fatalf("expected failed, but got %+v", report)
+	}
+	for i, e := range expected {
+		d := report.Details[i]
+		if d.Name != e.name || d.Status != e.status || d.Observed != e.observed {
+			t.Errorf("expected %s %s observed %s, but got %+v", e.name, e.status, e.observed, d)
+		}
+	}
+	if msg := report.Details[0].Message; !strings.Contains(msg, "service containerd.service") || !strings.Contains(msg, "less than the required 1048576") {
+		t.Errorf("unexpected message %q", msg)
+	}
+
+	report, err = checker.Evaluate(context.Background(), checker.UlimitCheck{Limits: []string{"nofile>=1024"}, Service: "docker"})
+	if err != nil || report.Status != checker.StatusFail || report.Details[0].Observed != "not found" {
+		t.Errorf("expected failed for the missing unit, but got %+v, %v", report, err)
+	}
+}
+
+func TestUlimitCheckInvalidSpec(t *testing.T) {
+	for _, spec := range []string{"ulimit:nofile>65536", "ulimit:files>=1", "ulimit:user=mysql", "ulimit:nofile>=1;user=mysql;service=mysqld"} {
+		if _, err := checker.ParseSpec(spec); err == nil {
+			t.Errorf("expected error for %s", spec)
+		}
+	}
+}
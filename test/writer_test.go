@@ -0,0 +1,183 @@
+// Copyright © 2022 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"preflight/checker"
+	"preflight/result"
+)
+
+func writerResponse() result.Response {
+	meta := &checker.Metadata{Level: checker.FatalLevel, Explain: "explain", Suggestion: "suggestion"}
+	return result.Response{
+		Passed: []result.Descriptor{
+			{CheckerName: "cpu:2", CheckerType: "cpu", Status: checker.StatusPass, IsPassed: true, Observed: "4", Expected: ">=2", Metadata: meta},
+		},
+		Failed: []result.Descriptor{
+			{CheckerName: "port:6443", CheckerType: "port", Status: checker.StatusFail, ErrorMessage: "Port 6443 | in use", Metadata: meta},
+		},
+		Errors: []result.Descriptor{
+			{CheckerName: "memory:1700", CheckerType: "memory", Status: checker.StatusError, ErrorMessage: "permission denied", Metadata: meta},
+		},
+		Skipped: []result.Descriptor{
+			{CheckerName: "fake:b", CheckerType: "fake", Status: checker.StatusSkip, Reason: "dependency a failed", Metadata: meta},
+		},
+	}
+}
+
+func TestWriterFormats(t *testing.T) {
+	for _, format := range []string{"json", "yaml", "table", "markdown", "junit", "html"} {
+		w, err := result.GetWriter(format)
+		if err != nil {
+			t.Fatalf("expected writer of %s, but got error: %s", format, err)
+		}
+		var buf bytes.Buffer
+		if err := w(&buf, writerResponse()); err != nil {
+			t.Errorf("failed to write %s: %s", format, err)
+		}
+		if !strings.Contains(buf.String(), "port:6443") {
+			t.Errorf("expected %s output contains checker name, but got %s", format, buf.String())
+		}
+	}
+
+	if _, err := result.GetWriter("csv"); err == nil {
+		t.Error("expected error of unknown format")
+	}
+}
+
+func TestJSONWriter(t *testing.T) {
+	w, _ := result.GetWriter("json")
+	var buf bytes.Buffer
+	if err := w(&buf, writerResponse()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"expected": ">=2"`) {
+		t.Errorf("expected json not escaped, but got %s", buf.String())
+	}
+
+	var resp result.Response
+	if err := json.Unmarshal(buf.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse json output: %s", err)
+	}
+	if len(resp.Failed) != 1 || len(resp.Errors) != 1 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestMarkdownWriter(t *testing.T) {
+	w, _ := result.GetWriter("markdown")
+	var buf bytes.Buffer
+	if err := w(&buf, writerResponse()); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 6 || !strings.HasPrefix(lines[1], "|---") {
+		t.Fatalf("unexpected markdown table %s", buf.String())
+	}
+	if !strings.Contains(lines[2], `Port 6443 \| in use`) || !strings.HasPrefix(lines[2], "| fail") {
+		t.Errorf("expected failed checker escaped in first row, but got %s", lines[2])
+	}
+}
+
+func TestJUnitWriter(t *testing.T) {
+	w, _ := result.GetWriter("junit")
+	var buf bytes.Buffer
+	if err := w(&buf, writerResponse()); err != nil {
+		t.Fatal(err)
+	}
+
+	var report struct {
+		Tests    int `xml:"tests,attr"`
+		Failures int `xml:"failures,attr"`
+		Errors   int `xml:"errors,attr"`
+		Skipped  int `xml:"skipped,attr"`
+		Suites   []struct {
+			Cases []struct {
+				Name    string    `xml:"name,attr"`
+				Failure *struct{} `xml:"failure"`
+				Error   *struct{} `xml:"error"`
+				Skipped *struct{} `xml:"skipped"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("failed to parse junit output: %s", err)
+	}
+	if report.Tests != 4 || report.Failures != 1 || report.Errors != 1 || report.Skipped != 1 {
+		t.Errorf("unexpected junit counters %+v", report)
+	}
+	if len(report.Suites) != 1 || len(report.Suites[0].Cases) != 4 {
+		t.Fatalf("expected a testcase for each checker, but got %+v", report.Suites)
+	}
+	if c := report.Suites[0].Cases[0]; c.Name != "port:6443" || c.Failure == nil {
+		t.Errorf("expected failure of port:6443, but got %+v", c)
+	}
+}
+
+func TestHTMLWriter(t *testing.T) {
+	resp := writerResponse()
+	resp.Failed = append(resp.Failed, result.Descriptor{
+		CheckerName: "clustercheck", CheckerType: "clustercheck", Status: checker.StatusFail,
+		ErrorMessage: "<script>alert(1)</script>",
+		Details: []checker.Detail{
+			{Name: "10.0.0.1", Status: checker.StatusPass, Host: "10.0.0.1"},
+			{Name: "10.0.0.2", Status: checker.StatusFail, Host: "10.0.0.2", Message: "10.0.0.2: hostname node1 is duplicate with host 10.0.0.1"},
+		},
+	})
+
+	w, _ := result.GetWriter("html")
+	var buf bytes.Buffer
+	if err := w(&buf, resp); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "<script>alert(1)</script>") {
+		t.Error("expected message escaped in html")
+	}
+	if strings.Contains(out, "src=") || strings.Contains(out, "<link") {
+		t.Error("expected no external assets in html")
+	}
+	for _, s := range []string{"<details><summary>explain</summary>explain</details>", "<h3>localhost</h3>", "<h3>10.0.0.1</h3>", "<h3>10.0.0.2</h3>", "hostname node1 is duplicate"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expected html contains %s", s)
+		}
+	}
+}
+
+func TestRegisterWriter(t *testing.T) {
+	result.RegisterWriter("names", func(w io.Writer, resp result.Response) error {
+		for _, d := range resp.Failed {
+			if _, err := io.WriteString(w, d.CheckerName+"\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	w, err := result.GetWriter("names")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := w(&buf, writerResponse()); err != nil || buf.String() != "port:6443\n" {
+		t.Errorf("unexpected output of registered writer %q, err: %v", buf.String(), err)
+	}
+}